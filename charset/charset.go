@@ -0,0 +1,240 @@
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Supported encodings
+const (
+	Auto    = "auto"
+	UTF8    = "utf-8"
+	GBK     = "gbk"
+	GB18030 = "gb18030"
+	Big5    = "big5"
+	UTF16LE = "utf-16le"
+	UTF16BE = "utf-16be"
+)
+
+// SupportedEncodings is the encodings supported for input files, auto is only for input
+var SupportedEncodings = []string{Auto, UTF8, GBK, GB18030, Big5, UTF16LE, UTF16BE}
+
+// sniffSize is the size of sample used for detecting encoding
+const sniffSize = 64 * 1024
+
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// lookup return the encoding by name, nil is returned for utf-8
+func lookup(name string) (encoding.Encoding, error) {
+	switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-") {
+	case "", UTF8, "utf8":
+		return nil, nil
+	case GBK, "cp936":
+		return simplifiedchinese.GBK, nil
+	case GB18030:
+		return simplifiedchinese.GB18030, nil
+	case Big5:
+		return traditionalchinese.Big5, nil
+	case UTF16LE, "utf16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	case UTF16BE, "utf16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM), nil
+	}
+
+	return nil, fmt.Errorf("unsupported encoding %s, support %s", name, strings.Join(SupportedEncodings, ", "))
+}
+
+// NewReader create a reader which decodes r from the encoding to utf-8 in streaming mode,
+// the encoding is detected from BOM and content when it is auto, and the detected encoding is returned
+func NewReader(r io.Reader, name string) (io.Reader, string, error) {
+	if strings.EqualFold(strings.TrimSpace(name), Auto) {
+		br := bufio.NewReaderSize(r, sniffSize)
+		sample, err := br.Peek(sniffSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, "", err
+		}
+
+		name = Detect(sample, len(sample) < sniffSize)
+		r = br
+	}
+
+	enc, err := lookup(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if enc == nil {
+		return r, UTF8, nil
+	}
+
+	return transform.NewReader(r, enc.NewDecoder()), name, nil
+}
+
+// NewWriter create a writer which encodes utf-8 content to the encoding in streaming mode, characters not supported
+// by the encoding are replaced, the leading utf-8 BOM is dropped for other encodings, Close must be called to flush
+// the content, and the underlying writer is not closed
+func NewWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	enc, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if enc == nil {
+		return nopCloser{Writer: w}, nil
+	}
+
+	return &encodingWriter{w: transform.NewWriter(w, encoding.ReplaceUnsupported(enc.NewEncoder()))}, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+type encodingWriter struct {
+	w       io.WriteCloser
+	head    []byte
+	started bool
+}
+
+func (ew *encodingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if !ew.started {
+		// BOM 可能被拆分为多次写入
+		ew.head = append(ew.head, p...)
+		if len(ew.head) < len(utf8BOM) && bytes.HasPrefix(utf8BOM, ew.head) {
+			return n, nil
+		}
+
+		p = bytes.TrimPrefix(ew.head, utf8BOM)
+		ew.started, ew.head = true, nil
+	}
+
+	if _, err := ew.w.Write(p); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (ew *encodingWriter) Close() error {
+	return ew.w.Close()
+}
+
+// Detect detect the encoding of sample, complete means the sample is the whole content,
+// otherwise the last rune of sample may be truncated
+func Detect(sample []byte, complete bool) string {
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		return UTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+
+	// 没有 BOM 的 UTF-16 文本中，ASCII 字符的高位字节为 0，需要在 UTF-8 之前判断（0 也是合法的 UTF-8 字符）
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b == 0 {
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+	}
+
+	if oddZeros > len(sample)/8 && oddZeros > evenZeros*4 {
+		return UTF16LE
+	}
+
+	if evenZeros > len(sample)/8 && evenZeros > oddZeros*4 {
+		return UTF16BE
+	}
+
+	if isUTF8(sample, complete) {
+		return UTF8
+	}
+
+	// GB18030 与 Big5 的编码范围大量重叠，按照解码后的无效字符以及常用汉字的数量来判断
+	if score(sample, traditionalchinese.Big5) > score(sample, simplifiedchinese.GB18030) {
+		return Big5
+	}
+
+	return GB18030
+}
+
+// isUTF8 check whether sample is valid utf-8, the truncated rune at the end is ignored if sample is not complete
+func isUTF8(sample []byte, complete bool) bool {
+	if utf8.Valid(sample) {
+		return true
+	}
+
+	if complete {
+		return false
+	}
+
+	for i := 1; i < utf8.UTFMax && i < len(sample); i++ {
+		if utf8.Valid(sample[:len(sample)-i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// score return the likelihood of sample encoded by enc, the higher the more likely
+func score(sample []byte, enc encoding.Encoding) int {
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), sample)
+	if err != nil {
+		return -len(sample)
+	}
+
+	var total int
+	for _, r := range string(decoded) {
+		switch {
+		case r == utf8.RuneError, r >= 0xE000 && r <= 0xF8FF:
+			// 无效字符以及私有区字符
+			total -= 10
+		case r >= 0x4E00 && r <= 0x9FFF:
+			total += commonHanBonus(r)
+		case r >= 0x3400 && r <= 0x4DBF, r >= 0x20000:
+			// 扩展区的生僻字在正常文本中很少出现
+			total -= 2
+		}
+	}
+
+	return total
+}
+
+// commonHanBonus return the bonus of common chinese characters, the characters shared by simplified and traditional
+// chinese are the majority of the most frequently used characters, so it works for both GB18030 and Big5
+func commonHanBonus(r rune) int {
+	if commonHan[r] {
+		return 3
+	}
+
+	return 1
+}
+
+// commonHan is the most frequently used chinese characters
+var commonHan = func() map[rune]bool {
+	chars := make(map[rune]bool)
+	for _, r := range "的一是不了在人有我他这个们中来上大为和国地到以说时要就出会可也你对生能而子那得于着下自之年过发后作里用道行所然家种事成方多经么去法学如都同现当没动面起看定天分还进好小部其些主样理心她本前开但因只从想实日军者意无力它与长把机十民第公此已工使情明性知全三又关点正业外将两高间由问很最重并物手应战向头文体政美相见被利什二等产或新己制身果加西斯月话合回特代内信表化老给世位次度门任常先海通教儿原东声提立及比员解水名真论处走义各入几口认条平系气题活尔更别打女变四神总何电数安少报才结反受目太量再感建务做接必场件计管期市直德资命山金指克许统区保至队形社便空决治展马科司五基眼书非则听白却界达光放强即像难且权思王象完设式色路记南品住告类求据程北边死张该交规万取拉格望觉术领共确传师观清今切院让识候带导争运笑飞风步改收根干造言联持组每济车亲极林服快办议往元英士证近失转夫令准布始怎呢存未远叫台单影具罗字爱击流备兵连调深商算质团集百需价花党华城石级整府离况亚请技际约示复病息究线似官火断精满支视消越器容照须九增研写称企八功吗包片史委乎查轻易早曾除农找装广显吧阿李标谈吃图念六引历首医局突专费号尽另周较注语仅考落青随选列" {
+		chars[r] = true
+	}
+
+	return chars
+}()
@@ -0,0 +1,53 @@
+package charset
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+func TestDetect(t *testing.T) {
+	simplified := "编号,姓名,部门,入职日期\n1,张三,技术部,2022-01-02\n2,李四,市场部,2021-12-31\n"
+	traditional := "編號,姓名,部門,入職日期\n1,張三,技術部,2022-01-02\n2,李四,市場部,2021-12-31\n"
+
+	encode := func(enc transform.Transformer, s string) []byte {
+		data, _, err := transform.Bytes(enc, []byte(s))
+		assert.NoError(t, err)
+		return data
+	}
+
+	assert.Equal(t, UTF8, Detect([]byte(simplified), true))
+	assert.Equal(t, UTF8, Detect([]byte(simplified)[:len(simplified)-len("31\n")-2], false))
+	assert.Equal(t, GB18030, Detect(encode(simplifiedchinese.GBK.NewEncoder(), simplified), true))
+	assert.Equal(t, Big5, Detect(encode(traditionalchinese.Big5.NewEncoder(), traditional), true))
+	assert.Equal(t, UTF16LE, Detect(encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder(), simplified), true))
+	assert.Equal(t, UTF16LE, Detect(encode(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder(), "id,name\n1,foo\n"), true))
+
+	for _, enc := range []string{Auto, GBK} {
+		r, name, err := NewReader(bytes.NewReader(encode(simplifiedchinese.GBK.NewEncoder(), simplified)), enc)
+		assert.NoError(t, err)
+		assert.True(t, name == GB18030 || name == GBK)
+
+		data, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, simplified, string(data))
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, GBK)
+	assert.NoError(t, err)
+	_, err = io.Copy(w, strings.NewReader("\xEF\xBB\xBF"+simplified))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, encode(simplifiedchinese.GBK.NewEncoder(), simplified), buf.Bytes())
+
+	_, _, err = NewReader(bytes.NewReader(nil), "latin1")
+	assert.True(t, err != nil)
+}
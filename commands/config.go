@@ -0,0 +1,329 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the heimdall config file, default located at ~/.config/heimdall/config.yaml
+//
+//	profiles:
+//	  prod-ro:
+//	    host: 10.0.0.1
+//	    port: 3306
+//	    user: readonly
+//	    database: example
+//	    password_cmd: pass show mysql/prod-ro
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a named database connection
+type Profile struct {
+	Host           string        `yaml:"host"`
+	Port           int           `yaml:"port"`
+	User           string        `yaml:"user"`
+	Database       string        `yaml:"database"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	Socket         string        `yaml:"socket"`
+
+	SSLMode string `yaml:"ssl_mode"`
+	SSLCA   string `yaml:"ssl_ca"`
+	SSLCert string `yaml:"ssl_cert"`
+	SSLKey  string `yaml:"ssl_key"`
+
+	SSH         string `yaml:"ssh"`
+	SSHKey      string `yaml:"ssh_key"`
+	SSHInsecure bool   `yaml:"ssh_insecure"`
+
+	// Params are extra MySQL DSN params, such as charset, collation, timeout
+	Params map[string]string `yaml:"params"`
+
+	// Password is the plain password, it is recommended to use other password sources instead
+	Password string `yaml:"password"`
+	// PasswordEnv read password from the specified environment variable
+	PasswordEnv string `yaml:"password_env"`
+	// PasswordCmd read password from the output of the command
+	PasswordCmd string `yaml:"password_cmd"`
+	// PasswordPrompt ask for password interactively
+	PasswordPrompt bool `yaml:"password_prompt"`
+	// DefaultsFile read connection options from MySQL option file, such as ~/.my.cnf
+	DefaultsFile string `yaml:"defaults_file"`
+}
+
+// defaultConfigPath return the default config file path
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "heimdall", "config.yaml")
+}
+
+// loadConfig load config from file
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("read config file %s failed: %w", path, err)
+	}
+
+	var conf Config
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("parse config file %s failed: %w", path, err)
+	}
+
+	return &conf, nil
+}
+
+// loadProfile load the named profile from config file
+func loadProfile(configPath string, name string) (*Profile, error) {
+	conf, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, ok := conf.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %s not found in %s", name, configPath)
+	}
+
+	return &profile, nil
+}
+
+// apply override the connection options with values in profile
+func (profile Profile) apply(opt GlobalOption) (GlobalOption, error) {
+	if profile.DefaultsFile != "" {
+		var err error
+		if opt, err = applyMySQLOptionFile(opt, profile.DefaultsFile); err != nil {
+			return opt, err
+		}
+	}
+
+	if profile.Host != "" {
+		opt.Host = profile.Host
+	}
+	if profile.Port > 0 {
+		opt.Port = profile.Port
+	}
+	if profile.User != "" {
+		opt.User = profile.User
+	}
+	if profile.Database != "" {
+		opt.Database = profile.Database
+	}
+	if profile.ConnectTimeout > 0 {
+		opt.ConnectTimeout = profile.ConnectTimeout
+	}
+	if profile.Socket != "" {
+		opt.Socket = expandHome(profile.Socket)
+	} else if profile.Host != "" || profile.Port > 0 {
+		// 指定了 host 或 port 时使用 TCP 连接，不再使用 defaults file 中的 socket
+		opt.Socket = ""
+	}
+	if profile.SSLMode != "" {
+		opt.TLS.Mode = profile.SSLMode
+	}
+	if profile.SSLCA != "" {
+		opt.TLS.CA = expandHome(profile.SSLCA)
+	}
+	if profile.SSLCert != "" {
+		opt.TLS.Cert = expandHome(profile.SSLCert)
+	}
+	if profile.SSLKey != "" {
+		opt.TLS.Key = expandHome(profile.SSLKey)
+	}
+	if profile.SSH != "" {
+		opt.SSH.Address = profile.SSH
+	}
+	if profile.SSHKey != "" {
+		opt.SSH.KeyFile = expandHome(profile.SSHKey)
+	}
+	if profile.SSHInsecure {
+		opt.SSH.Insecure = true
+	}
+	for k, v := range profile.Params {
+		if opt.Params == nil {
+			opt.Params = make(map[string]string)
+		}
+		opt.Params[k] = v
+	}
+
+	switch {
+	case profile.Password != "":
+		opt.Password = profile.Password
+	case profile.PasswordEnv != "":
+		opt.Password = os.Getenv(profile.PasswordEnv)
+	case profile.PasswordCmd != "":
+		password, err := passwordFromCommand(profile.PasswordCmd)
+		if err != nil {
+			return opt, err
+		}
+
+		opt.Password = password
+	}
+
+	return opt, nil
+}
+
+// passwordFromCommand execute the command and use the first line of its output as password
+func passwordFromCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("execute password command failed: %w", err)
+	}
+
+	password, _, _ := strings.Cut(string(output), "\n")
+	return strings.TrimRight(password, "\r"), nil
+}
+
+// passwordFromPrompt ask for password interactively without echo
+func passwordFromPrompt(user, host string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("password prompt requires a terminal")
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter password for %s@%s: ", user, host)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password failed: %w", err)
+	}
+
+	return string(password), nil
+}
+
+// resolveDefaultsFile return the MySQL option file to read, ~/.my.cnf is used when defaultsFile
+// is not specified and the file exists, empty string means no option file should be read
+func resolveDefaultsFile(defaultsFile string, noDefaults bool) string {
+	if defaultsFile != "" || noDefaults {
+		return defaultsFile
+	}
+
+	path := expandHome("~/.my.cnf")
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return ""
+	}
+
+	return path
+}
+
+// applyMySQLOptionFile override the connection options with values in [client] section of MySQL option file
+func applyMySQLOptionFile(opt GlobalOption, path string) (GlobalOption, error) {
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		return opt, fmt.Errorf("open defaults file failed: %w", err)
+	}
+	defer f.Close()
+
+	options, err := parseMySQLOptionFile(f, "client")
+	if err != nil {
+		return opt, fmt.Errorf("parse defaults file %s failed: %w", path, err)
+	}
+
+	if v, ok := options["host"]; ok {
+		opt.Host = v
+	}
+	if v, ok := options["port"]; ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return opt, fmt.Errorf("invalid port %s in defaults file %s", v, path)
+		}
+		opt.Port = port
+	}
+	if v, ok := options["user"]; ok {
+		opt.User = v
+	}
+	if v, ok := options["password"]; ok {
+		opt.Password = v
+	}
+	if v, ok := options["database"]; ok {
+		opt.Database = v
+	}
+	if v, ok := options["socket"]; ok {
+		opt.Socket = v
+	} else if options["host"] != "" || options["port"] != "" {
+		opt.Socket = ""
+	}
+	if v, ok := options["ssl-mode"]; ok {
+		opt.TLS.Mode = v
+	}
+	if v, ok := options["ssl-ca"]; ok {
+		opt.TLS.CA = v
+	}
+	if v, ok := options["ssl-cert"]; ok {
+		opt.TLS.Cert = v
+	}
+	if v, ok := options["ssl-key"]; ok {
+		opt.TLS.Key = v
+	}
+
+	return opt, nil
+}
+
+// parseMySQLOptionFile parse options in the specified section of MySQL option file
+// https://dev.mysql.com/doc/refman/8.0/en/option-files.html
+func parseMySQLOptionFile(r io.Reader, section string) (map[string]string, error) {
+	options := make(map[string]string)
+
+	var current string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '!' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		if current != section {
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		options[key] = value
+	}
+
+	return options, scanner.Err()
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+
+	return path
+}
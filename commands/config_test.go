@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestParseMySQLOptionFile(t *testing.T) {
+	content := `
+# comment
+[mysql]
+user = ignored
+
+[client]
+user = readonly
+password = "p@ss word"
+port=3307
+!includedir /etc/mysql/conf.d/
+`
+
+	options, err := parseMySQLOptionFile(strings.NewReader(content), "client")
+	assert.NoError(t, err)
+	assert.Equal(t, "readonly", options["user"])
+	assert.Equal(t, "p@ss word", options["password"])
+	assert.Equal(t, "3307", options["port"])
+	assert.Equal(t, 3, len(options))
+}
+
+func TestResolveDefaultsFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	assert.Equal(t, "", resolveDefaultsFile("", false))
+	assert.Equal(t, "/etc/my.cnf", resolveDefaultsFile("/etc/my.cnf", false))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(home, ".my.cnf"), []byte("[client]\nuser = readonly\n"), 0600))
+	assert.Equal(t, filepath.Join(home, ".my.cnf"), resolveDefaultsFile("", false))
+	assert.Equal(t, "", resolveDefaultsFile("", true))
+	assert.Equal(t, "/etc/my.cnf", resolveDefaultsFile("/etc/my.cnf", true))
+}
+
+func TestSocketPrecedence(t *testing.T) {
+	cnf := filepath.Join(t.TempDir(), "my.cnf")
+	assert.NoError(t, os.WriteFile(cnf, []byte("[client]\nuser = local\nsocket = /var/run/mysqld/mysqld.sock\n"), 0600))
+
+	opt, err := applyMySQLOptionFile(GlobalOption{Host: "127.0.0.1", Port: 3306}, cnf)
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/run/mysqld/mysqld.sock", opt.Socket)
+
+	// profile 中指定了 host 或 port 时，不再使用 defaults file 中的 socket
+	withHost, err := Profile{Host: "db.prod", DefaultsFile: cnf}.apply(GlobalOption{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", withHost.Socket)
+	assert.Equal(t, "db.prod", withHost.Host)
+
+	withPort, err := Profile{Port: 3307, DefaultsFile: cnf}.apply(GlobalOption{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", withPort.Socket)
+
+	withSocket, err := Profile{Host: "db.prod", Socket: "/tmp/mysql.sock", DefaultsFile: cnf}.apply(GlobalOption{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/mysql.sock", withSocket.Socket)
+
+	inherited, err := Profile{User: "readonly", DefaultsFile: cnf}.apply(GlobalOption{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/run/mysqld/mysqld.sock", inherited.Socket)
+
+	// 命令行中明确指定的 host 或 port 优先于 defaults file 中的 socket
+	resolve := func(args ...string) GlobalOption {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		for _, f := range BuildGlobalFlags() {
+			assert.NoError(t, f.Apply(set))
+		}
+		assert.NoError(t, set.Parse(append([]string{"--defaults-file", cnf}, args...)))
+
+		opt, err := resolveGlobalOption(cli.NewContext(cli.NewApp(), set, nil))
+		assert.NoError(t, err)
+		return opt
+	}
+
+	assert.Equal(t, "/var/run/mysqld/mysqld.sock", resolve().Socket)
+	assert.Equal(t, "", resolve("--host", "db.prod").Socket)
+	assert.Equal(t, "", resolve("--port", "3307").Socket)
+	assert.Equal(t, "/tmp/mysql.sock", resolve("--host", "db.prod", "--socket", "/tmp/mysql.sock").Socket)
+	assert.Equal(t, "local", resolve("--host", "db.prod").User)
+}
@@ -26,6 +26,7 @@ import (
 type ConvertOption struct {
 	InputFile   string
 	CSVSepertor rune
+	Sheet       string
 	Slient      bool
 	Debug       bool
 
@@ -37,12 +38,19 @@ type ConvertOption struct {
 
 	Includes []string
 	Excludes []string
+	// Typed output the numbers and booleans of excel cells as typed values instead of the formatted text
+	Typed bool
+
+	ReaderOption   reader.Options
+	OutputEncoding string
+	CSVOutput      render.CSVOptions
 }
 
 func BuildConvertFlags() []cli.Flag {
-	return []cli.Flag{
-		&cli.StringFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path", Required: true},
-		&cli.StringFlag{Name: "csv-sepertor", Value: ",", Usage: "csv file sepertor, default is ','"},
+	return append([]cli.Flag{
+		&cli.StringFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, - for STDIN (--input-format is required), a glob pattern or a directory", Required: true},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
+		&cli.StringFlag{Name: "sheet", Usage: "the sheet name or index (start from 1) of excel file to convert, default is the first sheet"},
 		&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "table", Usage: "output format, support " + strings.Join(query.SupportedStandardFormats, ", ")},
 		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "", Usage: "write output to a file, default output directly to STDOUT"},
 		&cli.BoolFlag{Name: "no-header", Aliases: []string{"n"}, Value: false, Usage: "do not write table header"},
@@ -52,7 +60,8 @@ func BuildConvertFlags() []cli.Flag {
 		&cli.BoolFlag{Name: "debug", Aliases: []string{"D"}, Value: false, Usage: "Debug mode"},
 		&cli.StringSliceFlag{Name: "include", Aliases: []string{"I"}, Usage: "include fields, if set, only these fields will be output, this flag can be specified multiple times"},
 		&cli.StringSliceFlag{Name: "exclude", Aliases: []string{"E"}, Usage: "exclude fields, if set, these fields will be ignored, this flag can be specified multiple times"},
-	}
+		&cli.BoolFlag{Name: "typed", Value: false, Usage: "keep the types of excel cells, numbers and booleans are output as typed values (such as numbers in json), dates are output in the form of 2006-01-02 15:04:05 and formulas as their cached values"},
+	}, append(append(BuildReaderFlags(), BuildCSVOutputFlags()...), BuildMaskFlags()...)...)
 }
 
 func resolveConvertOption(c *cli.Context) ConvertOption {
@@ -67,7 +76,8 @@ func resolveConvertOption(c *cli.Context) ConvertOption {
 
 	return ConvertOption{
 		InputFile:               c.String("input"),
-		CSVSepertor:             rune(c.String("csv-sepertor")[0]),
+		CSVSepertor:             parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor")),
+		Sheet:                   c.String("sheet"),
 		Format:                  c.String("format"),
 		Output:                  c.String("output"),
 		NoHeader:                c.Bool("no-header"),
@@ -78,6 +88,11 @@ func resolveConvertOption(c *cli.Context) ConvertOption {
 
 		Includes: includes,
 		Excludes: ternary.If(len(includes) > 0, []string{}, excludes),
+		Typed:    c.Bool("typed"),
+
+		ReaderOption:   resolveReaderOption(c),
+		OutputEncoding: c.String("output-encoding"),
+		CSVOutput:      resolveCSVOutputOption(c),
 	}
 }
 
@@ -107,16 +122,26 @@ func ConvertCommand(c *cli.Context) error {
 		return fmt.Errorf("when the format is sql, the table name (--table) is required")
 	}
 
-	walker := reader.CreateFileWalker(opt.InputFile, opt.CSVSepertor, false, false)
+	masker, err := resolveMasker(c)
+	if err != nil {
+		return err
+	}
+
+	readerOpt := opt.ReaderOption
+	readerOpt.CSVSepertor, readerOpt.Sheet = opt.CSVSepertor, opt.Sheet
+
+	walker := createConvertWalker(opt.InputFile, readerOpt, opt.Typed)
 	if walker == nil {
-		return fmt.Errorf("no file avaiable: only support csv or xlsx files")
+		return fmt.Errorf("no file avaiable: only support csv, xlsx, xls or ods files")
 	}
 
+	// 输入为多个文件时，输出所有文件中的列，每个文件的数据按照列名对齐
 	cols := make([]extracter.Column, 0)
+	current := make([]extracter.Column, 0)
 	kvs := make([]map[string]interface{}, 0)
 	if err := walker(
 		func(filepath string, headers []string) error {
-			cols = array.Map(headers, func(header string, _ int) extracter.Column {
+			current = array.Map(headers, func(header string, _ int) extracter.Column {
 				if len(opt.Includes) > 0 {
 					if !str.InIgnoreCase(header, opt.Includes) {
 						return extracter.Column{}
@@ -129,15 +154,22 @@ func ConvertCommand(c *cli.Context) error {
 
 				return extracter.Column{Name: header, Type: extracter.ColumnTypeVarchar, ScanType: reflect.TypeOf("")}
 			})
+
+			for _, col := range current {
+				if col.Name != "" && !array.In(col.Name, array.Map(cols, func(c extracter.Column, _ int) string { return c.Name })) {
+					cols = append(cols, col)
+				}
+			}
+
 			return nil
 		},
-		func(filepath string, id string, data []string) error {
-			kvs = append(kvs, maps.Filter(array.BuildMap(data, func(item string, i int) (string, interface{}) {
-				if i > len(cols)-1 || cols[i].Name == "" {
+		func(filepath string, id string, data []reader.Cell) error {
+			kvs = append(kvs, maps.Filter(array.BuildMap(data, func(cell reader.Cell, i int) (string, interface{}) {
+				if i > len(current)-1 || current[i].Name == "" {
 					return "", nil
 				}
 
-				return cols[i].Name, item
+				return current[i].Name, ternary.IfElseLazy(opt.Typed, func() interface{} { return typedCellValue(cell) }, func() interface{} { return cell.Value })
 			}), func(_ interface{}, k string) bool { return k != "" }))
 			return nil
 		},
@@ -145,8 +177,10 @@ func ConvertCommand(c *cli.Context) error {
 		return err
 	}
 
-	cols = array.Filter(cols, func(col extracter.Column, _ int) bool { return col.Name != "" })
-	res, err := render.Render(opt.Format, false, cols, kvs, "", opt.TargetTableForSQLFormat)
+	rs := &extracter.Rows{Columns: cols, DataSets: kvs}
+	query.MaskRows(rs, masker)
+
+	res, err := render.Render(opt.Format, false, rs.Columns, rs.DataSets, "", opt.TargetTableForSQLFormat, opt.CSVOutput)
 	if err != nil {
 		return err
 	}
@@ -158,6 +192,44 @@ func ConvertCommand(c *cli.Context) error {
 	)
 	defer w.Close()
 
-	_, err = w.Write(res.Bytes())
-	return err
+	ew, err := wrapOutputEncoding(w, opt.Format, opt.OutputEncoding)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ew.Write(res.Bytes()); err != nil {
+		return err
+	}
+
+	return ew.Close()
+}
+
+// createConvertWalker create a walker for convert, the type information of cells is only read when typed is true,
+// because it is slower than reading the text
+func createConvertWalker(filePath string, opt reader.Options, typed bool) reader.TypedFileWalker {
+	if typed {
+		return reader.CreateTypedFileWalker(filePath, opt)
+	}
+
+	walker := reader.CreateFileWalkerWithOptions(filePath, opt)
+	if walker == nil {
+		return nil
+	}
+
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []reader.Cell) error) error {
+		return walker(headerCB, func(filepath string, id string, data []string) error {
+			return dataCB(filepath, id, reader.StringCells(data))
+		})
+	}
+}
+
+// typedCellValue return the value of cell for output: numbers and booleans are kept as typed values, and dates are
+// formatted as text, because the output formats such as csv can not represent dates
+func typedCellValue(cell reader.Cell) interface{} {
+	switch cell.Type {
+	case reader.CellTypeNumber, reader.CellTypeBool, reader.CellTypeEmpty:
+		return cell.Interface()
+	}
+
+	return cell.Normalized()
 }
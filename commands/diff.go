@@ -0,0 +1,391 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/asteria/event"
+	"github.com/mylxsw/asteria/filter"
+	"github.com/mylxsw/asteria/level"
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/go-utils/must"
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/extracter"
+	"github.com/mylxsw/heimdall/query"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	diffStatusLeftOnly  = "left_only"
+	diffStatusRightOnly = "right_only"
+	diffStatusChanged   = "changed"
+
+	// diffSourceMySQLPrefix is the prefix of dataset which is queried from MySQL
+	diffSourceMySQLPrefix = "mysql:"
+)
+
+type DiffOption struct {
+	Left         string
+	Right        string
+	Keys         []string
+	CSVSepertor  rune
+	QueryTimeout time.Duration
+	Slient       bool
+	Debug        bool
+	Beta         bool
+
+	Format                  string
+	Output                  string
+	NoHeader                bool
+	TargetTableForSQLFormat string
+}
+
+func BuildDiffFlags() []cli.Flag {
+	return append(BuildGlobalFlags(), []cli.Flag{
+		&cli.StringFlag{Name: "left", Aliases: []string{"l"}, Usage: "left dataset, a xlsx or csv file path, or a MySQL query in the form of mysql:SQL", Required: true},
+		&cli.StringFlag{Name: "right", Aliases: []string{"r"}, Usage: "right dataset, a xlsx or csv file path, or a MySQL query in the form of mysql:SQL", Required: true},
+		&cli.StringSliceFlag{Name: "key", Aliases: []string{"k"}, Usage: "the column used to match rows between datasets, this flag can be specified multiple times for composite key", Required: true},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
+		&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "table", Usage: "output format of the difference report, support " + strings.Join(query.SupportedStandardFormats, ", ")},
+		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "", Usage: "write output to a file, default output directly to STDOUT"},
+		&cli.BoolFlag{Name: "no-header", Aliases: []string{"n"}, Value: false, Usage: "do not write table header"},
+		&cli.DurationFlag{Name: "query-timeout", Aliases: []string{"t"}, Value: 120 * time.Second, Usage: "query timeout"},
+		&cli.StringFlag{Name: "table", Value: "", Usage: "when the format is sql, specify the table name"},
+		&cli.BoolFlag{Name: "slient", Value: false, Usage: "do not print warning log"},
+	}...)
+}
+
+func resolveDiffOption(c *cli.Context) DiffOption {
+	return DiffOption{
+		Left:                    c.String("left"),
+		Right:                   c.String("right"),
+		Keys:                    array.Filter(c.StringSlice("key"), func(k string, _ int) bool { return k != "" }),
+		CSVSepertor:             parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor")),
+		QueryTimeout:            c.Duration("query-timeout"),
+		Slient:                  c.Bool("slient"),
+		Debug:                   c.Bool("debug"),
+		Beta:                    c.Bool("beta"),
+		Format:                  c.String("format"),
+		Output:                  c.String("output"),
+		NoHeader:                c.Bool("no-header"),
+		TargetTableForSQLFormat: c.String("table"),
+	}
+}
+
+// DiffCommand compare two datasets by key, the exit code is 0 if no differences found,
+// 1 if differences found, and 2 if something went wrong
+func DiffCommand(c *cli.Context) error {
+	found, err := diffDatasets(c)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("😨 %s", err), 2)
+	}
+
+	if found {
+		return cli.Exit("", 1)
+	}
+
+	return nil
+}
+
+func diffDatasets(c *cli.Context) (bool, error) {
+	opt := resolveDiffOption(c)
+	if !opt.Debug {
+		log.All().LogLevel(level.Info)
+	}
+
+	if opt.Slient {
+		log.AddGlobalFilter(func(filter filter.Filter) filter.Filter {
+			return func(evt event.Event) {
+				if evt.Level == level.Warning {
+					return
+				}
+
+				filter(evt)
+			}
+		})
+	}
+
+	if len(opt.Keys) == 0 {
+		return false, fmt.Errorf("--key is required")
+	}
+
+	if opt.Format == "sql" && opt.TargetTableForSQLFormat == "" {
+		return false, fmt.Errorf("when the format is sql, the table name (--table) is required")
+	}
+
+	loader := &diffLoader{c: c, opt: opt}
+	defer loader.Close()
+
+	left, err := loader.Load("left", opt.Left)
+	if err != nil {
+		return false, fmt.Errorf("load left dataset failed: %w", err)
+	}
+
+	right, err := loader.Load("right", opt.Right)
+	if err != nil {
+		return false, fmt.Errorf("load right dataset failed: %w", err)
+	}
+
+	cols, kvs, err := compareDatasets(left, right, opt.Keys)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := render.Render(opt.Format, opt.NoHeader, cols, kvs, "", opt.TargetTableForSQLFormat, render.CSVOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	w := ternary.IfElseLazy(
+		opt.Output != "",
+		func() io.WriteCloser { return must.Must(os.Create(opt.Output)) },
+		func() io.WriteCloser { return os.Stdout },
+	)
+
+	// 命令退出时会直接调用 os.Exit，这里不能使用 defer 关闭文件
+	_, err = w.Write(res.Bytes())
+	if opt.Output != "" {
+		if err1 := w.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+
+	return len(kvs) > 0, err
+}
+
+// diffLoader load datasets from files or MySQL
+type diffLoader struct {
+	c        *cli.Context
+	opt      DiffOption
+	mysqlDB  *sql.DB
+	sqliteDB *sql.DB
+}
+
+func (loader *diffLoader) Close() {
+	if loader.mysqlDB != nil {
+		_ = loader.mysqlDB.Close()
+	}
+
+	if loader.sqliteDB != nil {
+		_ = loader.sqliteDB.Close()
+	}
+}
+
+func (loader *diffLoader) Load(name string, source string) (*extracter.Rows, error) {
+	if strings.HasPrefix(source, diffSourceMySQLPrefix) {
+		db, err := loader.mysql()
+		if err != nil {
+			return nil, err
+		}
+
+		return query.QueryDB(db, strings.TrimPrefix(source, diffSourceMySQLPrefix), nil, loader.opt.QueryTimeout)
+	}
+
+	db, err := loader.sqlite()
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := createMemoryDatabaseForFly(FlyOption{
+		InputFiles:  []string{name + ":" + source},
+		CSVSepertor: loader.opt.CSVSepertor,
+		TempDS:      ":memory:",
+		Slient:      true,
+		Beta:        loader.opt.Beta,
+	}, db)
+	if err != nil {
+		return nil, err
+	}
+
+	table, ok := array.ToMap(tables, func(t Table, _ int) string { return t.Filename })[source]
+	if !ok {
+		return nil, fmt.Errorf("no data loaded from %s", source)
+	}
+
+	// 使用文件中原始的表头作为字段名，以便与另一个数据集中的字段进行匹配
+	fields := make([]string, 0)
+	for i, original := range table.OriginalColumns {
+		if i+1 >= len(table.Columns) {
+			break
+		}
+
+		fields = append(fields, fmt.Sprintf("`%s` AS %s", table.Columns[i+1], strconv.Quote(ternary.If(original == "", table.Columns[i+1], original))))
+	}
+
+	return query.QueryDB(db, fmt.Sprintf("SELECT %s FROM `%s` ORDER BY %s", strings.Join(fields, ", "), table.Name, memoryTableIDField), nil, loader.opt.QueryTimeout)
+}
+
+func (loader *diffLoader) mysql() (*sql.DB, error) {
+	if loader.mysqlDB != nil {
+		return loader.mysqlDB, nil
+	}
+
+	gOpt, err := resolveGlobalOption(loader.c)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("mysql", gOpt.DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if gOpt.ConnectTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), gOpt.ConnectTimeout)
+		defer cancel()
+
+		if err := db.PingContext(ctx); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("database is unreached: %w", err)
+		}
+	}
+
+	loader.mysqlDB = db
+	return db, nil
+}
+
+func (loader *diffLoader) sqlite() (*sql.DB, error) {
+	if loader.sqliteDB != nil {
+		return loader.sqliteDB, nil
+	}
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("create sqlite database failed: %w", err)
+	}
+
+	// 内存数据库每个连接都是独立的，这里限制只使用一个连接
+	db.SetMaxOpenConns(1)
+
+	loader.sqliteDB = db
+	return db, nil
+}
+
+// compareDatasets compare rows in left and right by keys, and return the difference report
+func compareDatasets(left, right *extracter.Rows, keys []string) ([]extracter.Column, []map[string]interface{}, error) {
+	leftCols := array.Map(left.Columns, func(col extracter.Column, _ int) string { return col.Name })
+	rightCols := array.Map(right.Columns, func(col extracter.Column, _ int) string { return col.Name })
+
+	for _, key := range keys {
+		if !array.In(key, leftCols) {
+			return nil, nil, fmt.Errorf("key %s not found in left dataset, available columns: %s", key, strings.Join(leftCols, ", "))
+		}
+
+		if !array.In(key, rightCols) {
+			return nil, nil, fmt.Errorf("key %s not found in right dataset, available columns: %s", key, strings.Join(rightCols, ", "))
+		}
+	}
+
+	compareCols := array.Filter(leftCols, func(col string, _ int) bool { return array.In(col, rightCols) && !array.In(col, keys) })
+	if onlyLeft := array.Diff(leftCols, rightCols); len(onlyLeft) > 0 {
+		log.Warningf("columns only in left dataset will not be compared: %s", strings.Join(onlyLeft, ", "))
+	}
+	if onlyRight := array.Diff(rightCols, leftCols); len(onlyRight) > 0 {
+		log.Warningf("columns only in right dataset will not be compared: %s", strings.Join(onlyRight, ", "))
+	}
+
+	leftTypes := array.BuildMap(left.Columns, func(col extracter.Column, _ int) (string, extracter.Column) { return col.Name, col })
+	rightTypes := array.BuildMap(right.Columns, func(col extracter.Column, _ int) (string, extracter.Column) { return col.Name, col })
+
+	rowKey := func(row map[string]interface{}, types map[string]extracter.Column) string {
+		return strings.Join(array.Map(keys, func(key string, _ int) string { return comparableValue(types[key], row[key]) }), "\x00")
+	}
+
+	rightIndex := make(map[string]map[string]interface{})
+	for _, row := range right.DataSets {
+		k := rowKey(row, rightTypes)
+		if _, ok := rightIndex[k]; ok {
+			log.Warningf("duplicate key %s in right dataset, only the last one will be compared", strings.ReplaceAll(k, "\x00", ", "))
+		}
+		rightIndex[k] = row
+	}
+
+	report := make([]map[string]interface{}, 0)
+	newReportRow := func(status string, row map[string]interface{}, types map[string]extracter.Column) map[string]interface{} {
+		item := map[string]interface{}{"status": status}
+		for _, key := range keys {
+			item[key] = comparableValue(types[key], row[key])
+		}
+
+		return item
+	}
+
+	summary := func(row map[string]interface{}, cols []string, types map[string]extracter.Column) string {
+		return strings.Join(array.Map(
+			array.Filter(cols, func(col string, _ int) bool { return !array.In(col, keys) }),
+			func(col string, _ int) string {
+				return fmt.Sprintf("%s=%s", col, comparableValue(types[col], row[col]))
+			},
+		), ", ")
+	}
+
+	var leftOnly, rightOnly, changed int
+	leftKeys := make(map[string]bool)
+	for _, row := range left.DataSets {
+		k := rowKey(row, leftTypes)
+		if leftKeys[k] {
+			log.Warningf("duplicate key %s in left dataset", strings.ReplaceAll(k, "\x00", ", "))
+		}
+		leftKeys[k] = true
+
+		rightRow, ok := rightIndex[k]
+		if !ok {
+			leftOnly++
+			item := newReportRow(diffStatusLeftOnly, row, leftTypes)
+			item["left"] = summary(row, leftCols, leftTypes)
+			report = append(report, item)
+			continue
+		}
+
+		rowChanged := false
+		for _, col := range compareCols {
+			lv, rv := comparableValue(leftTypes[col], row[col]), comparableValue(rightTypes[col], rightRow[col])
+			if lv == rv {
+				continue
+			}
+
+			rowChanged = true
+			item := newReportRow(diffStatusChanged, row, leftTypes)
+			item["column"] = col
+			item["left"] = lv
+			item["right"] = rv
+			report = append(report, item)
+		}
+
+		if rowChanged {
+			changed++
+		}
+	}
+
+	for _, row := range right.DataSets {
+		if leftKeys[rowKey(row, rightTypes)] {
+			continue
+		}
+
+		rightOnly++
+		item := newReportRow(diffStatusRightOnly, row, rightTypes)
+		item["right"] = summary(row, rightCols, rightTypes)
+		report = append(report, item)
+	}
+
+	log.Debugf("left %d rows, right %d rows, left only %d, right only %d, changed %d", len(left.DataSets), len(right.DataSets), leftOnly, rightOnly, changed)
+	if leftOnly+rightOnly+changed > 0 {
+		log.Infof("differences found: left only %d rows, right only %d rows, changed %d rows", leftOnly, rightOnly, changed)
+	}
+
+	colNames := append(append([]string{"status"}, keys...), "column", "left", "right")
+	cols := array.Map(colNames, func(name string, _ int) extracter.Column {
+		return extracter.Column{Name: name, Type: extracter.ColumnTypeVarchar, ScanType: reflect.TypeOf("")}
+	})
+
+	return cols, report, nil
+}
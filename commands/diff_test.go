@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/mylxsw/heimdall/extracter"
+)
+
+func diffTestRows(cols []string, rows ...[]interface{}) *extracter.Rows {
+	rs := &extracter.Rows{DataSets: make([]map[string]interface{}, 0)}
+	for _, col := range cols {
+		rs.Columns = append(rs.Columns, extracter.Column{Name: col, Type: extracter.ColumnTypeVarchar})
+	}
+
+	for _, row := range rows {
+		data := make(map[string]interface{})
+		for i, col := range cols {
+			data[col] = row[i]
+		}
+		rs.DataSets = append(rs.DataSets, data)
+	}
+
+	return rs
+}
+
+func TestCompareDatasets(t *testing.T) {
+	testCases := []struct {
+		name   string
+		left   *extracter.Rows
+		right  *extracter.Rows
+		keys   []string
+		report []map[string]interface{}
+	}{
+		{
+			name:   "identical",
+			left:   diffTestRows([]string{"id", "name"}, []interface{}{"1", "Tom"}),
+			right:  diffTestRows([]string{"id", "name"}, []interface{}{int64(1), " Tom "}),
+			keys:   []string{"id"},
+			report: []map[string]interface{}{},
+		},
+		{
+			name:  "added",
+			left:  diffTestRows([]string{"id", "name"}, []interface{}{"1", "Tom"}),
+			right: diffTestRows([]string{"id", "name"}, []interface{}{"1", "Tom"}, []interface{}{"2", "Jerry"}),
+			keys:  []string{"id"},
+			report: []map[string]interface{}{
+				{"status": diffStatusRightOnly, "id": "2", "right": "name=Jerry"},
+			},
+		},
+		{
+			name:  "removed",
+			left:  diffTestRows([]string{"id", "name"}, []interface{}{"1", "Tom"}, []interface{}{"2", "Jerry"}),
+			right: diffTestRows([]string{"id", "name"}, []interface{}{"2", "Jerry"}),
+			keys:  []string{"id"},
+			report: []map[string]interface{}{
+				{"status": diffStatusLeftOnly, "id": "1", "left": "name=Tom"},
+			},
+		},
+		{
+			name:  "changed",
+			left:  diffTestRows([]string{"id", "name", "age", "note"}, []interface{}{"1", "Tom", "18", "a"}),
+			right: diffTestRows([]string{"id", "name", "age"}, []interface{}{"1", "Tommy", float64(19)}),
+			keys:  []string{"id"},
+			report: []map[string]interface{}{
+				{"status": diffStatusChanged, "id": "1", "column": "name", "left": "Tom", "right": "Tommy"},
+				{"status": diffStatusChanged, "id": "1", "column": "age", "left": "18", "right": "19"},
+			},
+		},
+		{
+			name:  "composite keys",
+			left:  diffTestRows([]string{"year", "id", "amount"}, []interface{}{"2022", "1", "10"}, []interface{}{"2023", "1", "20"}),
+			right: diffTestRows([]string{"year", "id", "amount"}, []interface{}{"2023", "1", "25"}, []interface{}{"2022", "1", "10"}),
+			keys:  []string{"year", "id"},
+			report: []map[string]interface{}{
+				{"status": diffStatusChanged, "year": "2023", "id": "1", "column": "amount", "left": "20", "right": "25"},
+			},
+		},
+		{
+			// 右侧重复的 key 只有最后一行参与比较，左侧重复的 key 每一行都参与比较
+			name:  "duplicate keys",
+			left:  diffTestRows([]string{"id", "name"}, []interface{}{"1", "Tom"}, []interface{}{"1", "Tommy"}),
+			right: diffTestRows([]string{"id", "name"}, []interface{}{"1", "Tom"}, []interface{}{"1", "Tommy"}),
+			keys:  []string{"id"},
+			report: []map[string]interface{}{
+				{"status": diffStatusChanged, "id": "1", "column": "name", "left": "Tom", "right": "Tommy"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cols, report, err := compareDatasets(tc.left, tc.right, tc.keys)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.keys)+4, len(cols))
+			assert.Equal(t, tc.report, report)
+		})
+	}
+}
+
+func TestCompareDatasetsMissingKey(t *testing.T) {
+	left := diffTestRows([]string{"id", "name"}, []interface{}{"1", "Tom"})
+	right := diffTestRows([]string{"uid", "name"}, []interface{}{"1", "Tom"})
+
+	_, _, err := compareDatasets(left, right, []string{"id"})
+	assert.True(t, err != nil)
+
+	_, _, err = compareDatasets(right, left, []string{"id"})
+	assert.True(t, err != nil)
+
+	_, _, err = compareDatasets(left, left, []string{"name", "age"})
+	assert.True(t, err != nil)
+}
+
+func TestComparableValue(t *testing.T) {
+	date := extracter.Column{Name: "date", Type: extracter.ColumnTypeDate}
+	datetime := extracter.Column{Name: "created_at", Type: extracter.ColumnTypeDatetime}
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	assert.Equal(t, "", comparableValue(date, nil))
+	assert.Equal(t, "abc", comparableValue(date, []byte(" abc ")))
+	assert.Equal(t, "1.5", comparableValue(date, 1.5))
+	assert.Equal(t, "2023-01-02", comparableValue(date, ts))
+	assert.Equal(t, "2023-01-02 03:04:05", comparableValue(datetime, ts))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/mylxsw/go-utils/must"
 	"github.com/mylxsw/go-utils/ternary"
 	"github.com/mylxsw/heimdall/query"
+	"github.com/mylxsw/heimdall/render"
 	"github.com/urfave/cli/v2"
 )
 
@@ -25,10 +26,12 @@ type ExportOption struct {
 	QueryTimeout            time.Duration
 	XLSXMaxRow              int
 	TargetTableForSQLFormat string
+	OutputEncoding          string
+	CSVOutput               render.CSVOptions
 }
 
 func BuildExportFlags() []cli.Flag {
-	return append(BuildGlobalFlags(), []cli.Flag{
+	return append(append(append(BuildGlobalFlags(), []cli.Flag{
 		&cli.StringFlag{Name: "sql", Aliases: []string{"s", "query"}, Value: "", Usage: "SQL statement(if not set, read from STDIN, end with ';')"},
 		&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "table", Usage: "output format, support " + strings.Join(query.SupportedStandardFormats, ", ")},
 		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "", Usage: "write output to a file, default output directly to STDOUT"},
@@ -37,7 +40,7 @@ func BuildExportFlags() []cli.Flag {
 		&cli.DurationFlag{Name: "query-timeout", Aliases: []string{"t"}, Value: 120 * time.Second, Usage: "query timeout, when the stream option is specified, this option is invalid"},
 		&cli.IntFlag{Name: "xlsx-max-row", Value: 1048576, Usage: "the maximum number of rows per sheet in an Excel file, including the row where the header is located"},
 		&cli.StringFlag{Name: "table", Value: "", Usage: "when the format is sql, specify the table name"},
-	}...)
+	}...), BuildCSVOutputFlags()...), BuildMaskFlags()...)
 }
 
 func resolveExportOption(c *cli.Context) ExportOption {
@@ -55,6 +58,8 @@ func resolveExportOption(c *cli.Context) ExportOption {
 		QueryTimeout:            c.Duration("query-timeout"),
 		XLSXMaxRow:              c.Int("xlsx-max-row"),
 		TargetTableForSQLFormat: c.String("table"),
+		OutputEncoding:          c.String("output-encoding"),
+		CSVOutput:               resolveCSVOutputOption(c),
 	}
 }
 
@@ -63,9 +68,18 @@ func ExportCommand(c *cli.Context) error {
 		log.All().LogLevel(level.Info)
 	}
 
-	gOpt := resolveGlobalOption(c)
+	gOpt, err := resolveGlobalOption(c)
+	if err != nil {
+		return err
+	}
+
 	expOpt := resolveExportOption(c)
 
+	masker, err := resolveMasker(c)
+	if err != nil {
+		return err
+	}
+
 	if expOpt.SQL == "" {
 		return fmt.Errorf("--sql or -s is required")
 	}
@@ -87,10 +101,10 @@ func ExportCommand(c *cli.Context) error {
 	handler := ternary.IfLazy(
 		expOpt.Streaming,
 		func() query.QueryWriteHandler {
-			return query.NewStreamingQueryWriter(gOpt.DSN(), expOpt.TargetTableForSQLFormat, gOpt.ConnectTimeout)
+			return query.NewStreamingQueryWriter(gOpt.DSN(), expOpt.TargetTableForSQLFormat, gOpt.ConnectTimeout, masker, expOpt.CSVOutput)
 		},
 		func() query.QueryWriteHandler {
-			return query.NewStandardQueryWriter(gOpt.DSN(), expOpt.TargetTableForSQLFormat, gOpt.ConnectTimeout, expOpt.QueryTimeout)
+			return query.NewStandardQueryWriter(gOpt.DSN(), expOpt.TargetTableForSQLFormat, gOpt.ConnectTimeout, expOpt.QueryTimeout, masker, expOpt.CSVOutput)
 		},
 	)
 
@@ -101,8 +115,14 @@ func ExportCommand(c *cli.Context) error {
 	})
 	defer w.Close()
 
+	ew, err := wrapOutputEncoding(w, expOpt.Format, expOpt.OutputEncoding)
+	if err != nil {
+		return err
+	}
+	defer ew.Close()
+
 	startTime := time.Now()
-	total := must.Must(handler(expOpt.SQL, nil, expOpt.Format, w, expOpt.NoHeader))
+	total := must.Must(handler(expOpt.SQL, nil, expOpt.Format, ew, expOpt.NoHeader))
 
 	log.Debugf("write to %s, total %d records, %s elapsed", ternary.If(expOpt.Output == "", "STDOUT", expOpt.Output), total, time.Since(startTime))
 
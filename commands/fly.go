@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"crypto/md5"
 	"database/sql"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -16,12 +19,13 @@ import (
 	"github.com/mylxsw/asteria/level"
 	"github.com/mylxsw/asteria/log"
 	"github.com/mylxsw/go-utils/array"
-	"github.com/mylxsw/go-utils/maps"
 	"github.com/mylxsw/go-utils/must"
 	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/charset"
 	"github.com/mylxsw/heimdall/extracter"
 	"github.com/mylxsw/heimdall/query"
 	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
 	"github.com/urfave/cli/v2"
 )
 
@@ -43,13 +47,19 @@ type FlyOption struct {
 	ShowTables         bool
 	TempDS             string
 	Beta               bool
+	Interactive        bool
+	Indexes            []string
+	AutoIndex          bool
+	ReaderOption       reader.Options
+	OutputEncoding     string
+	CSVOutput          render.CSVOptions
 }
 
 func BuildFlyFlags() []cli.Flag {
-	return []cli.Flag{
+	return append([]cli.Flag{
 		&cli.StringFlag{Name: "sql", Aliases: []string{"s", "query"}, Value: "", Usage: "SQL statement(if not set, read from STDIN, end with ';')"},
-		&cli.StringSliceFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, you can use the form TABLE:FILE to specify the table name corresponding to the file, this flag can be specified multiple times for importing multiple files at the same time", Required: true},
-		&cli.StringFlag{Name: "csv-sepertor", Value: ",", Usage: "csv file sepertor, default is ','"},
+		&cli.StringSliceFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, you can use the form TABLE:FILE to specify the table name corresponding to the file, and FILE#SHEET to specify the sheet, every sheet of excel file is loaded as table FILE__SHEET if not specified, FILE can also be - for STDIN (--input-format is required), a glob pattern or a directory, the matched files are loaded into one table with a _source_file column, this flag can be specified multiple times for importing multiple files at the same time", Required: true},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
 		&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "table", Usage: "output format, support " + strings.Join(query.SupportedStandardFormats, ", ")},
 		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "", Usage: "write output to a file, default output directly to STDOUT"},
 		&cli.BoolFlag{Name: "no-header", Aliases: []string{"n"}, Value: false, Usage: "do not write table header"},
@@ -62,20 +72,27 @@ func BuildFlyFlags() []cli.Flag {
 		&cli.BoolFlag{Name: "slient", Value: false, Usage: "do not print warning log"},
 		&cli.BoolFlag{Name: "debug", Aliases: []string{"D"}, Value: false, Usage: "debug mode"},
 		&cli.BoolFlag{Name: "beta", Usage: "enable beta feature, when this flag is set, the loading performance for large excel file will be improved, may be unstable, use at your own risk"},
-	}
+		&cli.BoolFlag{Name: "interactive", Usage: "load files once and start an interactive sql shell"},
+		&cli.StringSliceFlag{Name: "index", Usage: "create index for table in the form of TABLE.COLUMN[,COLUMN], the column can be the field name or the original header, this flag can be specified multiple times"},
+		&cli.BoolFlag{Name: "auto-index", Usage: "create indexes for the columns used in JOIN ON clauses automatically"},
+	}, append(append(BuildReaderFlags(), BuildCSVOutputFlags()...), BuildMaskFlags()...)...)
 }
 
 func resolveFlyOption(c *cli.Context) FlyOption {
 	showTables := c.Bool("show-tables")
+	interactive := c.Bool("interactive")
 	sqlStr := c.String("sql")
-	if sqlStr == "" && !showTables {
+	inputFiles := array.Filter(c.StringSlice("file"), func(f string, _ int) bool { return f != "" })
+	// 从 STDIN 读取输入文件时，SQL 只能通过 --sql 指定
+	readStdin := array.In(reader.Stdin, array.Map(inputFiles, func(f string, _ int) string { return parseFlyInputFilename(f) }))
+	if sqlStr == "" && !showTables && !interactive && !readStdin {
 		sqlStr = readAll(os.Stdin, ';')
 	}
 
 	return FlyOption{
-		SQL:         strings.Trim(strings.TrimSpace(sqlStr), ";"),
-		InputFiles:  array.Filter(c.StringSlice("file"), func(f string, _ int) bool { return f != "" }),
-		CSVSepertor: rune(c.String("csv-sepertor")[0]),
+		SQL:         rewriteMySQLCompatSQL(strings.Trim(strings.TrimSpace(sqlStr), ";")),
+		InputFiles:  inputFiles,
+		CSVSepertor: parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor")),
 
 		Format:                  c.String("format"),
 		Output:                  c.String("output"),
@@ -89,6 +106,12 @@ func resolveFlyOption(c *cli.Context) FlyOption {
 		Slient:                  c.Bool("slient"),
 		Debug:                   c.Bool("debug"),
 		Beta:                    c.Bool("beta"),
+		Interactive:             interactive,
+		Indexes:                 c.StringSlice("index"),
+		AutoIndex:               c.Bool("auto-index"),
+		ReaderOption:            resolveReaderOption(c),
+		OutputEncoding:          c.String("output-encoding"),
+		CSVOutput:               resolveCSVOutputOption(c),
 	}
 }
 
@@ -111,11 +134,16 @@ func FlyCommand(c *cli.Context) error {
 		})
 	}
 
-	if opt.SQL == "" && !opt.ShowTables {
+	if opt.SQL == "" && !opt.ShowTables && !opt.Interactive {
 		return fmt.Errorf("--sql or -s is required")
 	}
 
-	db, err := sql.Open("sqlite", opt.TempDS)
+	masker, err := resolveMasker(c)
+	if err != nil {
+		return err
+	}
+
+	db, err := openFlyDatabase(opt.TempDS)
 	if err != nil {
 		return fmt.Errorf("create sqlite database failed: %w", err)
 	}
@@ -126,11 +154,16 @@ func FlyCommand(c *cli.Context) error {
 		return err
 	}
 
-	handler := query.NewStandardQueryWriterWithDB(db, opt.TargetTableForSQLFormat, opt.QueryTimeout)
 	if opt.ShowTables {
-		return showTables(tables, handler)
+		return showTables(tables, query.NewStandardQueryWriterWithDB(db, opt.TargetTableForSQLFormat, opt.QueryTimeout, nil, opt.CSVOutput))
+	}
+
+	if opt.Interactive {
+		return newFlyShell(opt, db, tables, masker).Run()
 	}
 
+	handler := query.NewStandardQueryWriterWithDB(db, opt.TargetTableForSQLFormat, opt.QueryTimeout, masker, opt.CSVOutput)
+
 	w := ternary.IfElseLazy(
 		opt.Output != "",
 		func() io.WriteCloser { return must.Must(os.Create(opt.Output)) },
@@ -144,9 +177,17 @@ func FlyCommand(c *cli.Context) error {
 	if opt.Output == "" {
 		w.Write([]byte("\n\n"))
 	}
-	_, err = handler(opt.SQL, nil, opt.Format, w, opt.NoHeader, nil)
 
-	return err
+	ew, err := wrapOutputEncoding(w, opt.Format, opt.OutputEncoding)
+	if err != nil {
+		return err
+	}
+
+	if _, err := handler(opt.SQL, nil, opt.Format, ew, opt.NoHeader, nil); err != nil {
+		return err
+	}
+
+	return ew.Close()
 }
 
 func showTables(tables []Table, handler func(sqlStr string, args []interface{}, format string, output io.Writer, noHeader bool, dataProcesser func(*extracter.Rows)) (int, error)) error {
@@ -154,9 +195,8 @@ func showTables(tables []Table, handler func(sqlStr string, args []interface{},
 		return err
 	}
 
-	columnAlias := make([]string, 0)
-
 	for _, table := range tables {
+		columnAlias := make([]string, 0)
 		fmt.Printf("\n◇ Table: %s ⇢ %s\n", table.Name, table.Filename)
 
 		dataProcesser := func(r *extracter.Rows) {
@@ -195,6 +235,9 @@ func showTables(tables []Table, handler func(sqlStr string, args []interface{},
 		fmt.Println()
 
 		fmt.Printf("SQL:\tSELECT %s FROM `%s`\n", strings.Join(columnAlias, ", "), table.Name)
+		if len(table.Indexes) > 0 {
+			fmt.Printf("INDEXES:\t%s\n", strings.Join(table.Indexes, "; "))
+		}
 
 		fmt.Println()
 	}
@@ -213,6 +256,8 @@ type Table struct {
 	Hash            string
 	Columns         []string
 	OriginalColumns []string
+	// Indexes is the index definitions of table, each of them is the columns joined by comma
+	Indexes []string
 }
 
 // queryMaxMetaID query max meta id from database
@@ -228,10 +273,10 @@ func queryMaxMetaID(db *sql.DB) (int, error) {
 
 // queryMeta query meta from database
 func queryMeta(db *sql.DB, filename string) (*Table, error) {
-	row := db.QueryRow("SELECT filename, hash, name, columns, original_columns FROM meta WHERE filename = ?", filename)
+	row := db.QueryRow("SELECT filename, hash, name, columns, original_columns, COALESCE(indexes, '') FROM meta WHERE filename = ?", filename)
 	var meta Table
-	var originalColumns, columns string
-	if err := row.Scan(&meta.Filename, &meta.Hash, &meta.Name, &columns, &originalColumns); err != nil {
+	var originalColumns, columns, indexes string
+	if err := row.Scan(&meta.Filename, &meta.Hash, &meta.Name, &columns, &originalColumns, &indexes); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -241,13 +286,14 @@ func queryMeta(db *sql.DB, filename string) (*Table, error) {
 
 	meta.Columns = strings.Split(columns, ",")
 	meta.OriginalColumns = strings.Split(originalColumns, ",")
+	meta.Indexes = splitMetaIndexes(indexes)
 
 	return &meta, nil
 }
 
 // queryMetas query all metas from database
 func queryMetas(db *sql.DB) ([]Table, error) {
-	rows, err := db.Query("SELECT filename, hash, name, columns, original_columns FROM meta")
+	rows, err := db.Query("SELECT filename, hash, name, columns, original_columns, COALESCE(indexes, '') FROM meta")
 	if err != nil {
 		return nil, fmt.Errorf("query metas failed: %w", err)
 	}
@@ -256,13 +302,14 @@ func queryMetas(db *sql.DB) ([]Table, error) {
 	var metas []Table
 	for rows.Next() {
 		var meta Table
-		var originalColumns, columns string
-		if err := rows.Scan(&meta.Filename, &meta.Hash, &meta.Name, &columns, &originalColumns); err != nil {
+		var originalColumns, columns, indexes string
+		if err := rows.Scan(&meta.Filename, &meta.Hash, &meta.Name, &columns, &originalColumns, &indexes); err != nil {
 			return nil, fmt.Errorf("scan meta failed: %w", err)
 		}
 
 		meta.Columns = strings.Split(columns, ",")
 		meta.OriginalColumns = strings.Split(originalColumns, ",")
+		meta.Indexes = splitMetaIndexes(indexes)
 
 		metas = append(metas, meta)
 	}
@@ -270,99 +317,469 @@ func queryMetas(db *sql.DB) ([]Table, error) {
 	return metas, nil
 }
 
+func splitMetaIndexes(indexes string) []string {
+	if indexes == "" {
+		return nil
+	}
+
+	return strings.Split(indexes, ";")
+}
+
 // initMemoryDatabaseMeta init meta table
 func initMemoryDatabaseMeta(opt FlyOption, db *sql.DB) error {
-	createSQL := `CREATE TABLE IF NOT EXISTS meta (id int PRIMARY KEY NOT NULL, filename, hash, name, columns, original_columns, created_at);`
+	createSQL := `CREATE TABLE IF NOT EXISTS meta (id int PRIMARY KEY NOT NULL, filename, hash, name, columns, original_columns, created_at, indexes);`
 	if _, err := db.Exec(createSQL); err != nil {
 		return fmt.Errorf("create meta table failed: %w", err)
 	}
 
+	// 旧版本创建的临时数据库中，meta 表没有 indexes 字段
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('meta') WHERE name = 'indexes'").Scan(&count); err != nil {
+		return fmt.Errorf("query meta table failed: %w", err)
+	}
+
+	if count == 0 {
+		if _, err := db.Exec("ALTER TABLE meta ADD COLUMN indexes"); err != nil {
+			return fmt.Errorf("upgrade meta table failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// flyInput is an input file of fly, in the form of [TABLE:]FILE[#SHEET]
+type flyInput struct {
+	Table    string
+	Explicit bool
+	Filename string
+	Sheet    string
+	Hash     string
+	// Files is the files matched when Filename is a glob pattern or a directory, they are loaded into one table
+	Files []string
+}
+
+// Source return the filename recorded in meta table
+func (in flyInput) Source() string {
+	return ternary.If(in.Sheet == "", in.Filename, reader.SheetSource(in.Filename, in.Sheet))
+}
+
+// parseFlyInput parse input file in the form of [TABLE:]FILE[#SHEET], FILE can be - for STDIN, a glob pattern or a directory
+func parseFlyInput(val string, defaultTableName string, readerOpt reader.Options) (flyInput, error) {
+	in := flyInput{Table: defaultTableName, Filename: val}
+	if table, filename, ok := splitFlyInput(val); ok {
+		in.Table = table
+		in.Explicit = true
+		in.Filename = filename
+	}
+
+	// 文件名中本身可能包含 #，只有文件不存在时才认为 # 后面是 sheet 名称
+	if _, err := os.Stat(in.Filename); err != nil {
+		if pos := strings.LastIndex(in.Filename, "#"); pos > 0 {
+			in.Sheet = in.Filename[pos+1:]
+			in.Filename = in.Filename[:pos]
+		}
+	}
+
+	var err error
+	switch {
+	case in.Filename == reader.Stdin:
+		// STDIN 中的内容无法判断是否变化，每次都需要重新加载
+		in.Hash = fmt.Sprintf("stdin:%d", time.Now().UnixNano())
+	case reader.IsPattern(in.Filename):
+		if in.Files, err = reader.ExpandPath(in.Filename, readerOpt); err != nil {
+			return in, err
+		}
+		in.Hash, err = filesHash(in.Files)
+	default:
+		in.Hash, err = fileHash(in.Filename)
+	}
+
+	return in, err
+}
+
+// parseFlyInputFilename return the FILE[#SHEET] part of input in the form of [TABLE:]FILE[#SHEET]
+func parseFlyInputFilename(val string) string {
+	if _, filename, ok := splitFlyInput(val); ok {
+		return filename
+	}
+
+	return val
+}
+
+// splitFlyInput split the input in the form of TABLE:FILE into table name and file, the drive letter
+// of windows path such as C:\data\a.csv is treated as part of the file instead of the table name
+func splitFlyInput(val string) (string, string, bool) {
+	table, filename, ok := strings.Cut(val, ":")
+	if !ok {
+		return "", val, false
+	}
+
+	// C:\data\a.csv 中的 C 是盘符而不是表名，指定表名时使用 TABLE:C:\data\a.csv
+	if isWindowsDrive(table, filename) {
+		return "", val, false
+	}
+
+	return table, filename, true
+}
+
+// isWindowsDrive check whether the prefix before colon is a drive letter of windows absolute path
+func isWindowsDrive(prefix string, rest string) bool {
+	if len(prefix) != 1 || !(prefix[0] >= 'A' && prefix[0] <= 'Z' || prefix[0] >= 'a' && prefix[0] <= 'z') {
+		return false
+	}
+
+	// 非 Windows 系统中 t:/path 是表名 t 加绝对路径，只有 \ 分隔符能够明确表示盘符
+	return strings.HasPrefix(rest, "\\") || (runtime.GOOS == "windows" && strings.HasPrefix(rest, "/"))
+}
+
+// sheetTableName return the table name for a sheet of excel file, in the form of FILE__SHEET
+func sheetTableName(in flyInput, sheet string, index int) string {
+	base := in.Table
+	if !in.Explicit {
+		name := slugifyColumnName(strings.TrimSuffix(filepath.Base(in.Filename), filepath.Ext(in.Filename)))
+		if name != "" && unicode.IsLetter(rune(name[0])) {
+			base = name
+		}
+	}
+
+	sheetName := slugifyColumnName(sheet)
+	if sheetName == "" {
+		sheetName = fmt.Sprintf("sheet%d", index)
+	}
+
+	return base + "__" + sheetName
+}
+
+// readerOptionHash return the hash suffix of reader options which affect the content of tables,
+// so that the files are reloaded when the options changed
+func readerOptionHash(opt reader.Options) string {
+	// 这些选项由 fly 根据输入文件设置，不需要参与计算
+	opt.CSVSepertor, opt.OnlyHeader, opt.Beta, opt.Sheet, opt.AllSheets = 0, false, false, "", false
+	if opt.HeaderRows <= 1 {
+		opt.HeaderRows = 0
+	}
+
+	if strings.EqualFold(opt.Encoding, charset.Auto) {
+		opt.Encoding = ""
+	}
+
+	if opt.CSVQuote == '"' {
+		opt.CSVQuote = 0
+	}
+
+	if opt == (reader.Options{}) {
+		return ""
+	}
+
+	return fmt.Sprintf(":%x", md5.Sum([]byte(fmt.Sprintf("%+v", opt))))[:9]
+}
+
+// dropTableOrView drop the table or view with the name if exists
+func dropTableOrView(db *sql.DB, name string) error {
+	var typ string
+	if err := db.QueryRow("SELECT type FROM sqlite_master WHERE name = ? AND type IN ('table', 'view')", name).Scan(&typ); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+
+		return fmt.Errorf("query table %s failed: %w", name, err)
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("DROP %s IF EXISTS %s;", strings.ToUpper(typ), name)); err != nil {
+		return fmt.Errorf("drop %s %s failed: %w", typ, name, err)
+	}
+
+	return nil
+}
+
+// dropInputTables drop all tables loaded from the input source before, including the tables of its sheets
+func dropInputTables(db *sql.DB, source string) error {
+	metas, err := queryMetas(db)
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range metas {
+		if meta.Filename != source && !strings.HasPrefix(meta.Filename, source+"#") {
+			continue
+		}
+
+		if err := dropTableOrView(db, meta.Name); err != nil {
+			return err
+		}
+
+		if _, err := db.Exec("DELETE FROM meta WHERE filename = ?", meta.Filename); err != nil {
+			return fmt.Errorf("delete meta for %s failed: %w", meta.Name, err)
+		}
+	}
+
 	return nil
 }
 
 // createMemoryDatabaseForFly create memory database
+//
+// For excel files without sheet specified, every sheet is loaded as a table named FILE__SHEET,
+// and the first sheet is also available as TABLE (or table_N) for compatibility
 func createMemoryDatabaseForFly(opt FlyOption, db *sql.DB) ([]Table, error) {
 	if err := initMemoryDatabaseMeta(opt, db); err != nil {
 		return nil, err
 	}
 
-	tableMetas := array.BuildMap(opt.InputFiles, func(val string, i int) (string, Table) {
-		var tableName, filename string
-		segs := strings.SplitN(val, ":", 2)
-		if len(segs) == 2 {
-			tableName = segs[0]
-			filename = segs[1]
-		} else {
-			tableName = fmt.Sprintf("table_%d", must.Must(queryMaxMetaID(db))+i)
-			filename = val
-		}
+	maxMetaID, err := queryMaxMetaID(db)
+	if err != nil {
+		return nil, err
+	}
 
-		return filename, Table{
-			Name:     tableName,
-			Filename: filename,
-			Hash:     must.Must(fileHash(filename)),
+	// 过滤需要更新的文件，如果文件 hash 和数据库中原有的一致，则不需要更新
+	inputFiles := make([]flyInput, 0, len(opt.InputFiles))
+	for i, val := range opt.InputFiles {
+		in, err := parseFlyInput(val, fmt.Sprintf("table_%d", maxMetaID+i), opt.ReaderOption)
+		if err != nil {
+			return nil, fmt.Errorf("parse input %s failed: %w", val, err)
 		}
-	})
+		in.Hash += readerOptionHash(opt.ReaderOption)
 
-	// 过滤需要更新的文件，如果文件 hash 和数据库中原有的一致，则不需要更新
-	inputFiles := array.FromMap(maps.Filter(tableMetas, func(t Table, _ string) bool {
-		meta := must.Must(queryMeta(db, t.Filename))
-		if meta == nil {
-			return true
+		meta, err := queryMeta(db, in.Source())
+		if err != nil {
+			return nil, err
 		}
 
-		if meta.Hash != t.Hash {
-			return true
+		if meta == nil || meta.Hash != in.Hash {
+			inputFiles = append(inputFiles, in)
 		}
+	}
 
-		return false
-	}))
+	indexes, err := parseFlyIndexes(opt.Indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 重新加载的文件，需要重建之前创建的索引
+	previousIndexes := make([]flyIndex, 0)
 
 	if len(inputFiles) > 0 {
-		walker := reader.MergeWalkers(array.Map(
-			inputFiles,
-			func(t Table, _ int) reader.FileWalker {
-				return reader.CreateFileWalker(t.Filename, opt.CSVSepertor, opt.ShowTables && opt.TempDS == ":memory:", opt.Beta || opt.ShowTables)
-			})...,
-		)
-		if walker == nil {
-			return nil, fmt.Errorf("no file avaiable: only support csv or xlsx files")
+		metas, err := queryMetas(db)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, meta := range metas {
+			for _, in := range inputFiles {
+				if meta.Filename == in.Source() || strings.HasPrefix(meta.Filename, in.Source()+"#") {
+					for _, def := range meta.Indexes {
+						previousIndexes = append(previousIndexes, flyIndex{Table: meta.Name, Columns: strings.Split(def, ",")})
+					}
+				}
+			}
+		}
+
+		for _, in := range inputFiles {
+			if err := dropInputTables(db, in.Source()); err != nil {
+				return nil, err
+			}
+		}
+
+		if metas, err = queryMetas(db); err != nil {
+			return nil, err
+		}
+		usedNames := array.ToMap(metas, func(t Table, _ int) string { return strings.ToLower(t.Name) })
+
+		if isFileTempDS(opt.TempDS) {
+			restore, err := setLoadPragmas(db)
+			if err != nil {
+				return nil, err
+			}
+			defer restore()
 		}
 
 		var currentTableName string
 		var currentTableFields []string
 		var recordIndex = 1
+		var createTableWithFields func(source string, tableName string, fields []string, headers []string) error
+
+		loader := newFlyTableLoader(db, flyLoadBatchSize)
+		defer loader.Rollback()
 
 		bar := NewProgressbar(!opt.Slient, "Initializing ...")
 		defer bar.Clear()
 
-		if err := walker(
-			func(filepath string, headers []string) error {
-				bar.Describe("Loading ...")
-				bar.Add(1)
+		startTs := time.Now()
+
+		createTable := func(source string, tableName string, headers []string) error {
+			fields := array.Map(createDBFieldsFromHeaders(headers, opt.UseColumnNumAsName), func(h DatabaseField, i int) string {
+				return h.Field
+			})
+
+			return createTableWithFields(source, tableName, fields, headers)
+		}
+
+		createTableWithFields = func(source string, tableName string, fields []string, headers []string) error {
+			// 建表语句需要在当前表的数据提交之后执行
+			if err := loader.Flush(); err != nil {
+				return err
+			}
+
+			currentTableName = tableName
+			currentTableFields = append([]string{memoryTableIDField}, fields...)
+			createSQL := fmt.Sprintf(
+				"CREATE TABLE %s (%s int PRIMARY KEY NOT NULL, %s);",
+				currentTableName,
+				memoryTableIDField,
+				strings.Join(fields, ","),
+			)
 
-				currentTableName = tableMetas[filepath].Name
-				fields := array.Map(createDBFieldsFromHeaders(headers, opt.UseColumnNumAsName), func(h DatabaseField, i int) string {
-					return h.Field
-				})
-
-				currentTableFields = append([]string{memoryTableIDField}, fields...)
-				createSQL := fmt.Sprintf(
-					"CREATE TABLE %s (%s int PRIMARY KEY NOT NULL, %s);",
-					currentTableName,
-					memoryTableIDField,
-					strings.Join(fields, ","),
+			if err := dropTableOrView(db, currentTableName); err != nil {
+				return err
+			}
+
+			if _, err := db.Exec(createSQL); err != nil {
+				return fmt.Errorf("create table %s failed: %w", currentTableName, err)
+			}
+
+			usedNames[strings.ToLower(currentTableName)] = Table{}
+			if err := loader.Reset(currentTableName, currentTableFields); err != nil {
+				return err
+			}
+
+			// 文件 hash 在数据全部加载完成后才写入，避免加载中断时使用不完整的数据
+			return addTableMeta(db, source, currentTableName, "", currentTableFields, headers)
+		}
+
+		// 同一个表达式匹配的多个文件合并为一张表，使用 _source_file 列记录每一行所在的文件
+		createUnionWalker := func(in flyInput, readerOpt reader.Options) reader.FileWalker {
+			if len(in.Files) == 0 {
+				log.Warningf("no file matched %s", in.Filename)
+				return nil
+			}
+
+			readerOpt.Sheet, readerOpt.AllSheets = in.Sheet, false
+			walker := reader.MergeWalkers(array.Map(in.Files, func(f string, _ int) reader.FileWalker {
+				return reader.CreateFileWalkerWithOptions(f, readerOpt)
+			})...)
+			if walker == nil {
+				return nil
+			}
+
+			union := newFlyUnionTable(opt.UseColumnNumAsName)
+			return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []string) error) error {
+				var created bool
+				return walker(
+					func(source string, headers []string) error {
+						if err := headerCB(source, headers); err != nil {
+							return err
+						}
+
+						newFields, newHeaders := union.Merge(headers)
+						if !created {
+							created = true
+							return createTableWithFields(in.Source(), in.Table, union.Fields(), union.Headers())
+						}
+
+						if len(newFields) == 0 {
+							return nil
+						}
+
+						// 后续文件中新增的列追加到表的末尾
+						if err := loader.Flush(); err != nil {
+							return err
+						}
+
+						for i, field := range newFields {
+							if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", in.Table, field)); err != nil {
+								return fmt.Errorf("add column %s (%s) to table %s failed: %w", field, newHeaders[i], in.Table, err)
+							}
+						}
+
+						currentTableFields = append([]string{memoryTableIDField}, union.Fields()...)
+						if err := loader.Reset(in.Table, currentTableFields); err != nil {
+							return err
+						}
+
+						return addTableMeta(db, in.Source(), in.Table, "", currentTableFields, union.Headers())
+					},
+					func(source string, id string, data []string) error {
+						return dataCB(source, id, union.Row(source, data))
+					},
 				)
+			}
+		}
 
-				if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s;", currentTableName)); err != nil {
-					return fmt.Errorf("drop table %s failed: %w", currentTableName, err)
+		walker := reader.MergeWalkers(array.Map(
+			inputFiles,
+			func(in flyInput, _ int) reader.FileWalker {
+				readerOpt := opt.ReaderOption
+				readerOpt.CSVSepertor = opt.CSVSepertor
+				readerOpt.OnlyHeader = opt.ShowTables && opt.TempDS == ":memory:"
+				readerOpt.Beta = opt.Beta || opt.ShowTables
+				readerOpt.Sheet = in.Sheet
+				readerOpt.AllSheets = in.Sheet == ""
+
+				if in.Files != nil {
+					return createUnionWalker(in, readerOpt)
+				}
+
+				walker := reader.CreateFileWalkerWithOptions(in.Filename, readerOpt)
+				if walker == nil {
+					return nil
 				}
 
-				if _, err := db.Exec(createSQL); err != nil {
-					return fmt.Errorf("create table %s failed: %w", currentTableName, err)
+				return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []string) error) error {
+					var sheetIndex int
+					return walker(
+						func(source string, headers []string) error {
+							if err := headerCB(source, headers); err != nil {
+								return err
+							}
+
+							if source == in.Filename || source == in.Source() {
+								return createTable(in.Source(), in.Table, headers)
+							}
+
+							// 读取所有 sheet 时，每个 sheet 作为一张单独的表
+							sheetIndex++
+							sheet := strings.TrimPrefix(source, in.Filename+"#")
+
+							tableName := sheetTableName(in, sheet, sheetIndex)
+							for i := 1; ; i++ {
+								if _, ok := usedNames[strings.ToLower(tableName)]; !ok {
+									break
+								}
+								tableName = fmt.Sprintf("%s_%d", sheetTableName(in, sheet, sheetIndex), i)
+							}
+
+							if err := createTable(source, tableName, headers); err != nil {
+								return err
+							}
+
+							if sheetIndex > 1 {
+								return nil
+							}
+
+							// 第一个 sheet 同时以视图的形式提供原有的表名，兼容只加载第一个 sheet 时的用法
+							if err := dropTableOrView(db, in.Table); err != nil {
+								return err
+							}
+
+							if _, err := db.Exec(fmt.Sprintf("CREATE VIEW %s AS SELECT * FROM %s;", in.Table, tableName)); err != nil {
+								return fmt.Errorf("create view %s failed: %w", in.Table, err)
+							}
+
+							return addTableMeta(db, in.Filename, in.Table, "", currentTableFields, headers)
+						},
+						dataCB,
+					)
 				}
+			})...,
+		)
+		if walker == nil {
+			return nil, fmt.Errorf("no file avaiable: only support csv, xlsx, xls or ods files")
+		}
 
-				return addTableMeta(db, filepath, currentTableName, tableMetas[filepath].Hash, currentTableFields, headers)
+		if err := walker(
+			func(filepath string, headers []string) error {
+				bar.Describe("Loading ...")
+				bar.Add(1)
+				return nil
 			},
 			func(filepath string, id string, data []string) error {
 				if opt.ShowTables && opt.TempDS == ":memory:" {
@@ -374,22 +791,55 @@ func createMemoryDatabaseForFly(opt FlyOption, db *sql.DB) ([]Table, error) {
 					bar.Add(1)
 				}()
 
-				maxSize := ternary.If(len(data) > (len(currentTableFields)-1), len(currentTableFields)-1, len(data))
+				return loader.Insert(recordIndex, data)
+			},
+		); err != nil {
+			return nil, err
+		}
 
-				placeholders := strings.Join(array.Repeat("?", maxSize), ",")
-				fields := strings.Join(currentTableFields[0:maxSize+1], ",")
+		if err := loader.Flush(); err != nil {
+			return nil, err
+		}
 
-				insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%d, %s);", currentTableName, fields, recordIndex, placeholders)
+		for _, in := range inputFiles {
+			if err := updateMetaHash(db, in.Source(), in.Hash); err != nil {
+				return nil, err
+			}
+		}
 
-				if _, err := db.Exec(insertSQL, array.Map(data, func(d string, i int) any { return d })...); err != nil {
-					return fmt.Errorf("insert data failed: %w", err)
-				}
+		// 与进度条一样输出到 STDERR，避免影响输出到 STDOUT 的查询结果
+		if !opt.Slient && loader.Rows() > 0 {
+			bar.Clear()
 
-				return nil
-			},
-		); err != nil {
+			elapsed := time.Since(startTs)
+			fmt.Fprintf(os.Stderr, "%d rows loaded in %s (%d rows/s)\n", loader.Rows(), elapsed.Round(time.Millisecond), int(float64(loader.Rows())/elapsed.Seconds()))
+		}
+	}
+
+	for _, index := range previousIndexes {
+		if _, err := createFlyIndexes(db, []flyIndex{index}); err != nil {
+			log.Warningf("recreate index for table %s failed: %v", index.Table, err)
+		}
+	}
+
+	if opt.AutoIndex && opt.SQL != "" {
+		metas, err := queryMetas(db)
+		if err != nil {
 			return nil, err
 		}
+		indexes = append(indexes, autoIndexesFromSQL(opt.SQL, metas)...)
+	}
+
+	created, err := createFlyIndexes(db, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 更新统计信息，让查询优化器能够选择合适的索引
+	if len(inputFiles) > 0 || created > 0 {
+		if _, err := db.Exec("ANALYZE;"); err != nil {
+			return nil, fmt.Errorf("analyze tables failed: %w", err)
+		}
 	}
 
 	tables, err := queryMetas(db)
@@ -400,15 +850,35 @@ func createMemoryDatabaseForFly(opt FlyOption, db *sql.DB) ([]Table, error) {
 	return array.DistinctBy(tables, func(item Table) string { return item.Name }), nil
 }
 
+// updateMetaHash update the file hash of input source, including the tables of its sheets
+func updateMetaHash(db *sql.DB, source string, hash string) error {
+	if _, err := db.Exec(
+		"UPDATE meta SET hash = ? WHERE filename = ? OR substr(filename, 1, ?) = ?",
+		hash,
+		source,
+		len([]rune(source))+1,
+		source+"#",
+	); err != nil {
+		return fmt.Errorf("update meta hash for %s failed: %w", source, err)
+	}
+
+	return nil
+}
+
 // addTableMeta add table meta to database
 func addTableMeta(db *sql.DB, filepath string, tableName string, hash string, currentTableFields []string, headers []string) error {
 	if _, err := db.Exec("DELETE FROM meta WHERE filename = ?", filepath); err != nil {
 		return fmt.Errorf("delete meta for %s failed: %w", tableName, err)
 	}
 
+	maxMetaID, err := queryMaxMetaID(db)
+	if err != nil {
+		return err
+	}
+
 	if _, err := db.Exec(
 		"INSERT INTO meta (id, filename, hash, name, columns, original_columns, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		must.Must(queryMaxMetaID(db))+1,
+		maxMetaID+1,
 		filepath,
 		hash,
 		tableName,
@@ -439,34 +909,39 @@ func slugifyColumnName(name string) string {
 
 func createDBFieldsFromHeaders(headers []string, useColumnNumAsName bool) []DatabaseField {
 	return array.Map(headers, func(h string, i int) DatabaseField {
-		if useColumnNumAsName {
-			return DatabaseField{
-				Field: fmt.Sprintf("col_%d", i+1),
-				Name:  h,
-				Index: i,
-			}
-		}
-		name := slugifyColumnName(h)
-		if name == "" || len(name) > maxColumnNameLength {
-			log.Warningf("column name [%s] is invalid (empty or too long), use col_%d instead", extracter.Sanitize(h), i+1)
-			return DatabaseField{
-				Field: fmt.Sprintf("col_%d", i+1),
-				Name:  h,
-				Index: i,
-			}
-		}
+		return createDBField(h, i, useColumnNumAsName)
+	})
+}
 
-		if !unicode.IsLetter(rune(name[0])) {
-			log.Warningf("column name [%s] is invalid, use col_%d instead", extracter.Sanitize(h), i+1)
-			name = fmt.Sprintf("col_%d", i+1)
+// createDBField create a database field for the header at index i
+func createDBField(h string, i int, useColumnNumAsName bool) DatabaseField {
+	if useColumnNumAsName {
+		return DatabaseField{
+			Field: fmt.Sprintf("col_%d", i+1),
+			Name:  h,
+			Index: i,
 		}
-
+	}
+	name := slugifyColumnName(h)
+	if name == "" || len(name) > maxColumnNameLength {
+		log.Warningf("column name [%s] is invalid (empty or too long), use col_%d instead", extracter.Sanitize(h), i+1)
 		return DatabaseField{
-			Field: name,
+			Field: fmt.Sprintf("col_%d", i+1),
 			Name:  h,
 			Index: i,
 		}
-	})
+	}
+
+	if !unicode.IsLetter(rune(name[0])) {
+		log.Warningf("column name [%s] is invalid, use col_%d instead", extracter.Sanitize(h), i+1)
+		name = fmt.Sprintf("col_%d", i+1)
+	}
+
+	return DatabaseField{
+		Field: name,
+		Name:  h,
+		Index: i,
+	}
 }
 
 type DatabaseField struct {
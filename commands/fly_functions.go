@@ -0,0 +1,614 @@
+package commands
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+	"modernc.org/sqlite"
+)
+
+// flyFunctions are the functions registered to sqlite for fly, most of them emulate the MySQL functions
+var flyFunctions = map[string]*sqlite.FunctionImpl{
+	"date_format":    {NArgs: 2, Deterministic: true, Scalar: sqlDateFormat},
+	"str_to_date":    {NArgs: 2, Deterministic: true, Scalar: sqlStrToDate},
+	"from_unixtime":  {NArgs: -1, Deterministic: true, Scalar: sqlFromUnixtime},
+	"unix_timestamp": {NArgs: -1, Scalar: sqlUnixTimestamp},
+	"now":            {NArgs: 0, Scalar: sqlNow},
+	"concat":         {NArgs: -1, Deterministic: true, Scalar: sqlConcat},
+	"concat_ws":      {NArgs: -1, Deterministic: true, Scalar: sqlConcatWS},
+	"regexp":         {NArgs: 2, Deterministic: true, Scalar: sqlRegexp},
+	"if":             {NArgs: 3, Deterministic: true, Scalar: sqlIf},
+	"excel_date":     {NArgs: 1, Deterministic: true, Scalar: sqlExcelDate},
+	"pinyin":         {NArgs: -1, Deterministic: true, Scalar: sqlPinyin},
+	"median":         {NArgs: 1, Deterministic: true, MakeAggregate: makePercentileAggregate(50)},
+	"percentile":     {NArgs: 2, Deterministic: true, MakeAggregate: makePercentileAggregate(-1)},
+	// group_concat_distinct 用于支持 GROUP_CONCAT(DISTINCT expr SEPARATOR 'sep')，sqlite 中 DISTINCT 聚合函数只能有一个参数
+	"group_concat_distinct": {NArgs: 2, Deterministic: true, MakeAggregate: makeGroupConcatDistinctAggregate},
+}
+
+var (
+	flyFunctionsOnce sync.Once
+	flyFunctionsErr  error
+)
+
+// openFlyDatabase open a sqlite database for fly, the fly functions are registered to the sqlite driver
+// on the first call, so that other users of the driver are not affected unless a fly database is opened.
+// The driver only supports registering functions for all connections opened afterwards
+func openFlyDatabase(dsn string) (*sql.DB, error) {
+	flyFunctionsOnce.Do(func() {
+		for name, impl := range flyFunctions {
+			if err := sqlite.RegisterFunction(name, impl); err != nil {
+				flyFunctionsErr = fmt.Errorf("register sqlite function %s failed: %w", name, err)
+				return
+			}
+		}
+	})
+
+	if flyFunctionsErr != nil {
+		return nil, flyFunctionsErr
+	}
+
+	return sql.Open("sqlite", dsn)
+}
+
+var (
+	groupConcatRegexp = regexp.MustCompile(`(?i)\bgroup_concat\s*\(`)
+	separatorRegexp   = regexp.MustCompile(`(?i)^\s+separator\s+`)
+	distinctRegexp    = regexp.MustCompile(`(?i)^\s*distinct\s+`)
+)
+
+// rewriteMySQLCompatSQL rewrite the MySQL syntax which is not supported by sqlite,
+// currently only GROUP_CONCAT(expr SEPARATOR 'sep') is rewritten to GROUP_CONCAT(expr, 'sep'),
+// and GROUP_CONCAT(DISTINCT expr SEPARATOR 'sep') is rewritten to GROUP_CONCAT_DISTINCT(expr, 'sep')
+func rewriteMySQLCompatSQL(sqlStr string) string {
+	var sb strings.Builder
+	for {
+		loc := groupConcatRegexp.FindStringIndex(sqlStr)
+		if loc == nil {
+			sb.WriteString(sqlStr)
+			return sb.String()
+		}
+
+		if inSQLString(sb.String() + sqlStr[:loc[0]]) {
+			sb.WriteString(sqlStr[:loc[1]])
+			sqlStr = sqlStr[loc[1]:]
+			continue
+		}
+
+		// 查找 group_concat 参数中第一层的 SEPARATOR 关键字，跳过字符串以及嵌套的括号
+		depth, quoted := 0, false
+		end := loc[1]
+		for ; end < len(sqlStr); end++ {
+			c := sqlStr[end]
+			if quoted {
+				if c == '\'' {
+					quoted = false
+				}
+				continue
+			}
+
+			if c == '\'' {
+				quoted = true
+			} else if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if depth == 0 {
+				if m := separatorRegexp.FindStringIndex(sqlStr[end:]); m != nil {
+					if d := distinctRegexp.FindStringIndex(sqlStr[loc[1]:end]); d != nil {
+						sb.WriteString(sqlStr[:loc[0]])
+						sb.WriteString("group_concat_distinct(")
+						sb.WriteString(sqlStr[loc[1]+d[1] : end])
+					} else {
+						sb.WriteString(sqlStr[:end])
+					}
+					sb.WriteString(", ")
+					end += m[1]
+					sqlStr = sqlStr[end:]
+					end = -1
+					break
+				}
+			}
+		}
+
+		if end >= 0 {
+			sb.WriteString(sqlStr[:loc[1]])
+			sqlStr = sqlStr[loc[1]:]
+		}
+	}
+}
+
+// inSQLString check whether the end of str is inside a string literal
+func inSQLString(str string) bool {
+	return strings.Count(str, "'")%2 == 1
+}
+
+var sqlDateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"2006-1-2 15:04:05",
+	"2006-1-2",
+	"2006/1/2 15:04:05",
+	"2006/1/2",
+}
+
+func sqlString(v driver.Value) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return val, true
+	case []byte:
+		return string(val), true
+	case int64:
+		return strconv.FormatInt(val, 10), true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05"), true
+	}
+
+	return fmt.Sprintf("%v", v), true
+}
+
+func sqlFloat(v driver.Value) (float64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	case nil:
+		return 0, false
+	}
+
+	str, _ := sqlString(v)
+	num, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	return num, err == nil
+}
+
+func sqlTime(v driver.Value) (time.Time, bool) {
+	if t, ok := v.(time.Time); ok {
+		return t, true
+	}
+
+	str, ok := sqlString(v)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	str = strings.TrimSpace(str)
+	for _, layout := range sqlDateLayouts {
+		if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// sqlDateFormat DATE_FORMAT(date, format)
+func sqlDateFormat(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	t, ok := sqlTime(args[0])
+	format, ok2 := sqlString(args[1])
+	if !ok || !ok2 {
+		return nil, nil
+	}
+
+	return mysqlDateFormat(t, format), nil
+}
+
+// mysqlDateFormat format time using MySQL format specifiers
+func mysqlDateFormat(t time.Time, format string) string {
+	var sb strings.Builder
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' || i == len(runes)-1 {
+			sb.WriteRune(runes[i])
+			continue
+		}
+
+		i++
+		switch runes[i] {
+		case 'Y':
+			sb.WriteString(fmt.Sprintf("%04d", t.Year()))
+		case 'y':
+			sb.WriteString(fmt.Sprintf("%02d", t.Year()%100))
+		case 'm':
+			sb.WriteString(fmt.Sprintf("%02d", int(t.Month())))
+		case 'c':
+			sb.WriteString(strconv.Itoa(int(t.Month())))
+		case 'd':
+			sb.WriteString(fmt.Sprintf("%02d", t.Day()))
+		case 'e':
+			sb.WriteString(strconv.Itoa(t.Day()))
+		case 'H':
+			sb.WriteString(fmt.Sprintf("%02d", t.Hour()))
+		case 'k':
+			sb.WriteString(strconv.Itoa(t.Hour()))
+		case 'h', 'I':
+			sb.WriteString(t.Format("03"))
+		case 'l':
+			sb.WriteString(t.Format("3"))
+		case 'i':
+			sb.WriteString(fmt.Sprintf("%02d", t.Minute()))
+		case 's', 'S':
+			sb.WriteString(fmt.Sprintf("%02d", t.Second()))
+		case 'f':
+			sb.WriteString(fmt.Sprintf("%06d", t.Nanosecond()/1000))
+		case 'p':
+			sb.WriteString(t.Format("PM"))
+		case 'T':
+			sb.WriteString(t.Format("15:04:05"))
+		case 'r':
+			sb.WriteString(t.Format("03:04:05 PM"))
+		case 'W':
+			sb.WriteString(t.Weekday().String())
+		case 'a':
+			sb.WriteString(t.Format("Mon"))
+		case 'M':
+			sb.WriteString(t.Month().String())
+		case 'b':
+			sb.WriteString(t.Format("Jan"))
+		case 'j':
+			sb.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		case 'w':
+			sb.WriteString(strconv.Itoa(int(t.Weekday())))
+		default:
+			// %% 以及不支持的格式说明符，按照 MySQL 的行为输出字符本身
+			sb.WriteRune(runes[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// mysqlToGoLayoutReplacer convert MySQL format to go layout for parsing, the layouts which accept
+// one or two digits are used, because MySQL accepts both 2022-1-2 and 2022-01-02 for %Y-%m-%d
+var mysqlToGoLayoutReplacer = strings.NewReplacer(
+	"%Y", "2006", "%y", "06", "%m", "1", "%c", "1", "%d", "2", "%e", "2",
+	"%H", "15", "%k", "15", "%h", "3", "%I", "3", "%l", "3", "%i", "4",
+	"%s", "5", "%S", "5", "%f", "000000", "%p", "PM", "%T", "15:4:5",
+	"%r", "3:4:5 PM", "%W", "Monday", "%a", "Mon", "%M", "January", "%b", "Jan",
+	"%%", "%",
+)
+
+// sqlStrToDate STR_TO_DATE(str, format), the result is a date if format has no time parts, otherwise a datetime
+func sqlStrToDate(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	str, ok := sqlString(args[0])
+	format, ok2 := sqlString(args[1])
+	if !ok || !ok2 {
+		return nil, nil
+	}
+
+	t, err := time.ParseInLocation(mysqlToGoLayoutReplacer.Replace(format), strings.TrimSpace(str), time.Local)
+	if err != nil {
+		return nil, nil
+	}
+
+	if strings.ContainsAny(strings.ReplaceAll(format, "%%", ""), "HkhIlisSfpTr") {
+		return t.Format("2006-01-02 15:04:05"), nil
+	}
+
+	return t.Format("2006-01-02"), nil
+}
+
+// sqlFromUnixtime FROM_UNIXTIME(ts[, format])
+func sqlFromUnixtime(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("from_unixtime requires 1 or 2 arguments")
+	}
+
+	ts, ok := sqlFloat(args[0])
+	if !ok {
+		return nil, nil
+	}
+
+	sec, frac := math.Modf(ts)
+	t := time.Unix(int64(sec), int64(frac*1e9))
+	if len(args) == 2 {
+		format, ok := sqlString(args[1])
+		if !ok {
+			return nil, nil
+		}
+
+		return mysqlDateFormat(t, format), nil
+	}
+
+	return t.Format("2006-01-02 15:04:05"), nil
+}
+
+// sqlUnixTimestamp UNIX_TIMESTAMP([date])
+func sqlUnixTimestamp(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("unix_timestamp requires at most 1 argument")
+	}
+
+	if len(args) == 0 {
+		return time.Now().Unix(), nil
+	}
+
+	t, ok := sqlTime(args[0])
+	if !ok {
+		return nil, nil
+	}
+
+	return t.Unix(), nil
+}
+
+// sqlNow NOW()
+func sqlNow(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	return time.Now().Format("2006-01-02 15:04:05"), nil
+}
+
+// sqlConcat CONCAT(str1, str2, ...), returns NULL if any argument is NULL
+func sqlConcat(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		str, ok := sqlString(arg)
+		if !ok {
+			return nil, nil
+		}
+
+		sb.WriteString(str)
+	}
+
+	return sb.String(), nil
+}
+
+// sqlConcatWS CONCAT_WS(separator, str1, str2, ...), NULL values are skipped
+func sqlConcatWS(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("concat_ws requires at least 1 argument")
+	}
+
+	sep, ok := sqlString(args[0])
+	if !ok {
+		return nil, nil
+	}
+
+	values := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		if str, ok := sqlString(arg); ok {
+			values = append(values, str)
+		}
+	}
+
+	return strings.Join(values, sep), nil
+}
+
+var sqlRegexpCache sync.Map
+
+// sqlRegexp REGEXP(pattern, str), it is also used by the operator: str REGEXP pattern
+func sqlRegexp(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := sqlString(args[0])
+	str, ok2 := sqlString(args[1])
+	if !ok || !ok2 {
+		return nil, nil
+	}
+
+	re, ok := sqlRegexpCache.Load(pattern)
+	if !ok {
+		// 与 MySQL 保持一致，默认不区分大小写
+		compiled, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %s: %w", pattern, err)
+		}
+
+		re, _ = sqlRegexpCache.LoadOrStore(pattern, compiled)
+	}
+
+	if re.(*regexp.Regexp).MatchString(str) {
+		return int64(1), nil
+	}
+
+	return int64(0), nil
+}
+
+// sqlIf IF(cond, then, else)
+func sqlIf(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if num, ok := sqlFloat(args[0]); ok && num != 0 {
+		return args[1], nil
+	}
+
+	return args[2], nil
+}
+
+// excelEpoch is the day 0 of Excel serial date, 1899-12-30 is used to compensate the 1900 leap year bug
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// sqlExcelDate EXCEL_DATE(serial) convert Excel serial date to date or datetime
+func sqlExcelDate(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	serial, ok := sqlFloat(args[0])
+	if !ok || serial <= 0 {
+		return nil, nil
+	}
+
+	days, frac := math.Modf(serial)
+	t := excelEpoch.AddDate(0, 0, int(days)).Add(time.Duration(math.Round(frac*86400)) * time.Second)
+	if frac == 0 {
+		return t.Format("2006-01-02"), nil
+	}
+
+	return t.Format("2006-01-02 15:04:05"), nil
+}
+
+// sqlPinyin PINYIN(str[, separator]) convert chinese characters to pinyin, other characters are kept
+func sqlPinyin(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("pinyin requires 1 or 2 arguments")
+	}
+
+	str, ok := sqlString(args[0])
+	if !ok {
+		return nil, nil
+	}
+
+	sep := ""
+	if len(args) == 2 {
+		sep, _ = sqlString(args[1])
+	}
+
+	arg := pinyin.NewArgs()
+	res := make([]string, 0)
+	var other strings.Builder
+	for _, r := range str {
+		if !unicode.Is(unicode.Han, r) {
+			other.WriteRune(r)
+			continue
+		}
+
+		if other.Len() > 0 {
+			res = append(res, other.String())
+			other.Reset()
+		}
+
+		if py := pinyin.SinglePinyin(r, arg); len(py) > 0 {
+			res = append(res, py[0])
+		}
+	}
+
+	if other.Len() > 0 {
+		res = append(res, other.String())
+	}
+
+	return strings.Join(res, sep), nil
+}
+
+// percentileAggregate collect all numeric values, and calculate the percentile using linear interpolation
+type percentileAggregate struct {
+	percent float64
+	values  []float64
+}
+
+// makePercentileAggregate create percentile aggregate, if percent is negative,
+// the percent is specified by the second argument, in the range of [0, 100]
+func makePercentileAggregate(percent float64) func(ctx sqlite.FunctionContext) (sqlite.AggregateFunction, error) {
+	return func(ctx sqlite.FunctionContext) (sqlite.AggregateFunction, error) {
+		return &percentileAggregate{percent: percent}, nil
+	}
+}
+
+func (agg *percentileAggregate) Step(ctx *sqlite.FunctionContext, args []driver.Value) error {
+	if len(args) == 2 {
+		percent, ok := sqlFloat(args[1])
+		if !ok || percent < 0 || percent > 100 {
+			return fmt.Errorf("percentile should be in the range of [0, 100]")
+		}
+
+		agg.percent = percent
+	}
+
+	if num, ok := sqlFloat(args[0]); ok {
+		agg.values = append(agg.values, num)
+	}
+
+	return nil
+}
+
+func (agg *percentileAggregate) WindowInverse(ctx *sqlite.FunctionContext, args []driver.Value) error {
+	num, ok := sqlFloat(args[0])
+	if !ok {
+		return nil
+	}
+
+	for i, v := range agg.values {
+		if v == num {
+			agg.values = append(agg.values[:i], agg.values[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (agg *percentileAggregate) WindowValue(ctx *sqlite.FunctionContext) (driver.Value, error) {
+	if len(agg.values) == 0 {
+		return nil, nil
+	}
+
+	values := append([]float64{}, agg.values...)
+	sort.Float64s(values)
+
+	pos := agg.percent / 100 * float64(len(values)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+
+	return values[lower] + (values[upper]-values[lower])*(pos-float64(lower)), nil
+}
+
+func (agg *percentileAggregate) Final(ctx *sqlite.FunctionContext) {}
+
+// groupConcatDistinctAggregate concat the distinct non-NULL values with separator, in the order they appear
+type groupConcatDistinctAggregate struct {
+	sep    string
+	seen   map[string]int
+	values []string
+}
+
+func makeGroupConcatDistinctAggregate(ctx sqlite.FunctionContext) (sqlite.AggregateFunction, error) {
+	return &groupConcatDistinctAggregate{sep: ",", seen: make(map[string]int)}, nil
+}
+
+func (agg *groupConcatDistinctAggregate) Step(ctx *sqlite.FunctionContext, args []driver.Value) error {
+	agg.sep, _ = sqlString(args[1])
+
+	str, ok := sqlString(args[0])
+	if !ok {
+		return nil
+	}
+
+	if agg.seen[str] == 0 {
+		agg.values = append(agg.values, str)
+	}
+	agg.seen[str]++
+
+	return nil
+}
+
+func (agg *groupConcatDistinctAggregate) WindowInverse(ctx *sqlite.FunctionContext, args []driver.Value) error {
+	str, ok := sqlString(args[0])
+	if !ok || agg.seen[str] == 0 {
+		return nil
+	}
+
+	agg.seen[str]--
+	if agg.seen[str] == 0 {
+		for i, v := range agg.values {
+			if v == str {
+				agg.values = append(agg.values[:i], agg.values[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (agg *groupConcatDistinctAggregate) WindowValue(ctx *sqlite.FunctionContext) (driver.Value, error) {
+	if len(agg.values) == 0 {
+		return nil, nil
+	}
+
+	return strings.Join(agg.values, agg.sep), nil
+}
+
+func (agg *groupConcatDistinctAggregate) Final(ctx *sqlite.FunctionContext) {}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestFlyFunctions(t *testing.T) {
+	db, err := openFlyDatabase(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t (name, score)")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t VALUES ('张三', 1), ('李四', 2), ('王五', 3), ('赵六', 10)")
+	assert.NoError(t, err)
+
+	cases := map[string]interface{}{
+		"SELECT DATE_FORMAT('2022-01-02 15:04:05', '%Y年%m月%d日 %H:%i:%s %W')": "2022年01月02日 15:04:05 Sunday",
+		"SELECT STR_TO_DATE('2022/1/2', '%Y/%m/%d')":                         "2022-01-02",
+		"SELECT STR_TO_DATE('02-01-2022 10:11:12', '%d-%m-%Y %H:%i:%s')":     "2022-01-02 10:11:12",
+		"SELECT CONCAT_WS('-', 'a', NULL, 'b', 1)":                           "a-b-1",
+		"SELECT CONCAT('a', 1)":                                              "a1",
+		"SELECT 'Hello' REGEXP '^h'":                                         int64(1),
+		"SELECT IF(1 > 2, 'yes', 'no')":                                      "no",
+		"SELECT EXCEL_DATE(44563)":                                           "2022-01-02",
+		"SELECT EXCEL_DATE(44563.5)":                                         "2022-01-02 12:00:00",
+		"SELECT PINYIN('张三abc')":                                             "zhangsanabc",
+		"SELECT PINYIN('张三', ' ')":                                           "zhang san",
+		"SELECT MEDIAN(score) FROM t":                                        2.5,
+		"SELECT PERCENTILE(score, 100) FROM t":                               10.0,
+		rewriteMySQLCompatSQL("SELECT GROUP_CONCAT(name SEPARATOR '|') FROM (SELECT name FROM t ORDER BY score LIMIT 2)"): "张三|李四",
+	}
+
+	for sqlStr, expected := range cases {
+		var res interface{}
+		assert.NoError(t, db.QueryRow(sqlStr).Scan(&res))
+		assert.Equal(t, expected, res)
+	}
+}
+
+func TestRewriteMySQLCompatSQL(t *testing.T) {
+	db, err := openFlyDatabase(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t (name, a)")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t VALUES ('Tom', 'x'), ('Jerry', NULL), ('Tom', 'y')")
+	assert.NoError(t, err)
+
+	cases := []struct {
+		sql       string
+		rewritten string
+		expected  interface{}
+	}{
+		{
+			sql:       "SELECT group_concat(DISTINCT name SEPARATOR ';') FROM t",
+			rewritten: "SELECT group_concat_distinct(name, ';') FROM t",
+			expected:  "Tom;Jerry",
+		},
+		{
+			sql:       "SELECT GROUP_CONCAT(DISTINCT name) FROM t",
+			rewritten: "SELECT GROUP_CONCAT(DISTINCT name) FROM t",
+			expected:  "Tom,Jerry",
+		},
+		{
+			sql:       "SELECT GROUP_CONCAT(IFNULL(a, 'x') SEPARATOR ', ') FROM t",
+			rewritten: "SELECT GROUP_CONCAT(IFNULL(a, 'x'), ', ') FROM t",
+			expected:  "x, x, y",
+		},
+		{
+			sql:       "SELECT 'separator' FROM t LIMIT 1",
+			rewritten: "SELECT 'separator' FROM t LIMIT 1",
+			expected:  "separator",
+		},
+		{
+			sql:       "SELECT 'group_concat(a separator b)' || group_concat(a separator '|') FROM t",
+			rewritten: "SELECT 'group_concat(a separator b)' || group_concat(a, '|') FROM t",
+			expected:  "group_concat(a separator b)x|y",
+		},
+		{
+			sql:       "SELECT group_concat(DISTINCT a SEPARATOR '-') FROM t WHERE a IS NULL",
+			rewritten: "SELECT group_concat_distinct(a, '-') FROM t WHERE a IS NULL",
+			expected:  nil,
+		},
+	}
+
+	for _, c := range cases {
+		rewritten := rewriteMySQLCompatSQL(c.sql)
+		assert.Equal(t, c.rewritten, rewritten)
+
+		var res interface{}
+		assert.NoError(t, db.QueryRow(rewritten).Scan(&res))
+		assert.Equal(t, c.expected, res)
+	}
+}
@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mylxsw/go-utils/array"
+)
+
+// flyIndex is an index definition of fly table
+type flyIndex struct {
+	Table   string
+	Columns []string
+}
+
+// parseFlyIndexes parse --index values in the form of TABLE.COLUMN[,COLUMN]
+//
+// The comma is also the separator of slice flags, so values without table prefix are treated as columns of the previous index
+func parseFlyIndexes(values []string) ([]flyIndex, error) {
+	indexes := make([]flyIndex, 0)
+	for _, val := range values {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+
+		if segs := strings.SplitN(val, ".", 2); len(segs) == 2 {
+			indexes = append(indexes, flyIndex{Table: segs[0], Columns: []string{segs[1]}})
+			continue
+		}
+
+		if len(indexes) == 0 {
+			return nil, fmt.Errorf("invalid index %s, should be in the form of TABLE.COLUMN[,COLUMN]", val)
+		}
+
+		indexes[len(indexes)-1].Columns = append(indexes[len(indexes)-1].Columns, val)
+	}
+
+	return indexes, nil
+}
+
+var (
+	sqlIdentifierPattern = "[`\"]?([\\p{L}\\p{N}_]+)[`\"]?"
+	sqlTableRefRegexp    = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+` + sqlIdentifierPattern + `(?:\s+(?:AS\s+)?` + sqlIdentifierPattern + `)?`)
+	sqlJoinOnRegexp      = regexp.MustCompile(`(?is)\bON\s+(.*?)(?:\b(?:WHERE|JOIN|LEFT|RIGHT|INNER|CROSS|FULL|NATURAL|GROUP|ORDER|LIMIT|UNION|HAVING)\b|$)`)
+	sqlEqualityRegexp    = regexp.MustCompile(sqlIdentifierPattern + `\.` + sqlIdentifierPattern + `\s*=\s*` + sqlIdentifierPattern + `\.` + sqlIdentifierPattern)
+	sqlKeywords          = []string{"ON", "WHERE", "JOIN", "LEFT", "RIGHT", "INNER", "OUTER", "CROSS", "FULL", "NATURAL", "GROUP", "ORDER", "LIMIT", "UNION", "USING", "HAVING"}
+)
+
+// autoIndexesFromSQL find the columns compared in JOIN ON clauses, such as `ON a.id = b.ref_id`,
+// only the columns of tables loaded by fly are returned
+func autoIndexesFromSQL(sqlStr string, tables []Table) []flyIndex {
+	tableNames := array.BuildMap(tables, func(t Table, _ int) (string, string) { return strings.ToLower(t.Name), t.Name })
+
+	aliases := make(map[string]string)
+	for _, match := range sqlTableRefRegexp.FindAllStringSubmatch(sqlStr, -1) {
+		table, ok := tableNames[strings.ToLower(match[1])]
+		if !ok {
+			continue
+		}
+
+		aliases[strings.ToLower(table)] = table
+		if match[2] != "" && !array.In(strings.ToUpper(match[2]), sqlKeywords) {
+			aliases[strings.ToLower(match[2])] = table
+		}
+	}
+
+	indexes := make([]flyIndex, 0)
+	for _, on := range sqlJoinOnRegexp.FindAllStringSubmatch(sqlStr, -1) {
+		for _, eq := range sqlEqualityRegexp.FindAllStringSubmatch(on[1], -1) {
+			for _, ref := range [][2]string{{eq[1], eq[2]}, {eq[3], eq[4]}} {
+				if table, ok := aliases[strings.ToLower(ref[0])]; ok {
+					indexes = append(indexes, flyIndex{Table: table, Columns: []string{ref[1]}})
+				}
+			}
+		}
+	}
+
+	return indexes
+}
+
+// createFlyIndexes create indexes for fly tables, and record the index definitions in meta table,
+// it returns the number of indexes created
+func createFlyIndexes(db *sql.DB, indexes []flyIndex) (int, error) {
+	var created int
+	for _, index := range indexes {
+		tableName, err := resolveViewTable(db, index.Table)
+		if err != nil {
+			return created, err
+		}
+
+		metas, err := queryMetas(db)
+		if err != nil {
+			return created, err
+		}
+
+		meta, ok := array.ToMap(metas, func(t Table, _ int) string { return strings.ToLower(t.Name) })[strings.ToLower(tableName)]
+		if !ok {
+			return created, fmt.Errorf("create index failed: table %s not found", index.Table)
+		}
+
+		columns := make([]string, 0, len(index.Columns))
+		for _, col := range index.Columns {
+			field := resolveTableColumn(meta, strings.TrimSpace(col))
+			if field == "" {
+				return created, fmt.Errorf("create index failed: column %s not found in table %s", col, index.Table)
+			}
+
+			columns = append(columns, field)
+		}
+
+		definition := strings.Join(columns, ",")
+		if array.In(definition, meta.Indexes) {
+			continue
+		}
+
+		indexName := fmt.Sprintf("idx_%s_%s", meta.Name, strings.Join(columns, "_"))
+		if _, err := db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s);", indexName, meta.Name, definition)); err != nil {
+			return created, fmt.Errorf("create index %s failed: %w", indexName, err)
+		}
+
+		if _, err := db.Exec("UPDATE meta SET indexes = ? WHERE name = ?", strings.Join(append(meta.Indexes, definition), ";"), meta.Name); err != nil {
+			return created, fmt.Errorf("update meta for %s failed: %w", meta.Name, err)
+		}
+
+		created++
+	}
+
+	return created, nil
+}
+
+// resolveTableColumn return the field name of the column, the column can be a field name or an original header name
+func resolveTableColumn(meta Table, column string) string {
+	for i, field := range meta.Columns {
+		if strings.EqualFold(field, column) {
+			return field
+		}
+
+		// Columns 中第一列为 __rowid，因此 OriginalColumns 的下标比 Columns 少 1
+		if i > 0 && i-1 < len(meta.OriginalColumns) && meta.OriginalColumns[i-1] == column {
+			return field
+		}
+	}
+
+	return ""
+}
+
+var sqlViewSourceRegexp = regexp.MustCompile(`(?i)\bFROM\s+` + sqlIdentifierPattern)
+
+// resolveViewTable return the underlying table if name is a view of fly (the first sheet of excel file), otherwise return name itself
+func resolveViewTable(db *sql.DB, name string) (string, error) {
+	var typ, createSQL string
+	if err := db.QueryRow("SELECT type, sql FROM sqlite_master WHERE name = ? COLLATE NOCASE AND type IN ('table', 'view')", name).Scan(&typ, &createSQL); err != nil {
+		if err == sql.ErrNoRows {
+			return name, nil
+		}
+
+		return "", fmt.Errorf("query table %s failed: %w", name, err)
+	}
+
+	if typ == "view" {
+		if match := sqlViewSourceRegexp.FindStringSubmatch(createSQL); match != nil {
+			return match[1], nil
+		}
+	}
+
+	return name, nil
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestParseFlyIndexes(t *testing.T) {
+	// StringSliceFlag 会按照逗号拆分参数值
+	indexes, err := parseFlyIndexes([]string{"a.id", "b.name", "age", "c.ref_id"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(indexes))
+	assert.Equal(t, "b", indexes[1].Table)
+	assert.Equal(t, []string{"name", "age"}, indexes[1].Columns)
+
+	_, err = parseFlyIndexes([]string{"id"})
+	assert.True(t, err != nil)
+}
+
+func TestAutoIndexesFromSQL(t *testing.T) {
+	tables := []Table{{Name: "table_0"}, {Name: "table_1"}, {Name: "orders"}}
+
+	indexes := autoIndexesFromSQL(
+		"SELECT * FROM table_0 a LEFT JOIN table_1 AS b ON a.id = b.ref_id JOIN orders ON orders.uid = a.uid AND orders.x > 1 JOIN (SELECT 1) s ON s.id = a.id WHERE a.status = 1",
+		tables,
+	)
+
+	assert.Equal(t, 5, len(indexes))
+	assert.Equal(t, flyIndex{Table: "table_0", Columns: []string{"id"}}, indexes[0])
+	assert.Equal(t, flyIndex{Table: "table_1", Columns: []string{"ref_id"}}, indexes[1])
+	assert.Equal(t, flyIndex{Table: "orders", Columns: []string{"uid"}}, indexes[2])
+	assert.Equal(t, flyIndex{Table: "table_0", Columns: []string{"uid"}}, indexes[3])
+	assert.Equal(t, flyIndex{Table: "table_0", Columns: []string{"id"}}, indexes[4])
+}
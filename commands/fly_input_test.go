@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParseFlyInput(t *testing.T) {
+	dir := t.TempDir()
+	book := filepath.Join(dir, "book.xlsx")
+	assert.NoError(t, os.WriteFile(book, []byte("book"), 0644))
+	// 文件名中本身包含 #
+	hashName := filepath.Join(dir, "report#1.csv")
+	assert.NoError(t, os.WriteFile(hashName, []byte("id\n1\n"), 0644))
+
+	var testcases = []struct {
+		val      string
+		table    string
+		explicit bool
+		filename string
+		sheet    string
+	}{
+		{val: book, table: "table_0", filename: book},
+		{val: "cost:" + book, table: "cost", explicit: true, filename: book},
+		{val: book + "#Cost", table: "table_0", filename: book, sheet: "Cost"},
+		{val: book + "#2", table: "table_0", filename: book, sheet: "2"},
+		{val: "cost:" + book + "#成本", table: "cost", explicit: true, filename: book, sheet: "成本"},
+		{val: book + "#", table: "table_0", filename: book},
+		{val: hashName, table: "table_0", filename: hashName},
+		{val: "t:" + hashName, table: "t", explicit: true, filename: hashName},
+	}
+
+	for _, tc := range testcases {
+		in, err := parseFlyInput(tc.val, "table_0", reader.Options{})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.table, in.Table)
+		assert.Equal(t, tc.explicit, in.Explicit)
+		assert.Equal(t, tc.filename, in.Filename)
+		assert.Equal(t, tc.sheet, in.Sheet)
+		assert.True(t, in.Hash != "")
+	}
+
+	in, err := parseFlyInput(book+"#2", "table_0", reader.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, book+"#2", in.Source())
+
+	in, err = parseFlyInput(book+"#", "table_0", reader.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, book, in.Source())
+
+	in, err = parseFlyInput(reader.Stdin, "table_0", reader.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, reader.Stdin, in.Filename)
+	assert.True(t, strings.HasPrefix(in.Hash, "stdin:"))
+
+	// 文件不存在时返回错误
+	_, err = parseFlyInput(filepath.Join(dir, "missing.csv"), "table_0", reader.Options{})
+	assert.True(t, err != nil)
+}
+
+func TestParseFlyInputWindowsPath(t *testing.T) {
+	var testcases = []struct {
+		val      string
+		table    string
+		explicit bool
+		filename string
+		sheet    string
+	}{
+		{val: `C:\data\book.xlsx`, table: "table_0", filename: `C:\data\book.xlsx`},
+		{val: `d:\data\book.xlsx#Cost`, table: "table_0", filename: `d:\data\book.xlsx`, sheet: "Cost"},
+		{val: `cost:C:\data\book.xlsx#2`, table: "cost", explicit: true, filename: `C:\data\book.xlsx`, sheet: "2"},
+		{val: `t:C:\data\book.xlsx`, table: "t", explicit: true, filename: `C:\data\book.xlsx`},
+		// 非 Windows 系统中单个字母也可以作为表名
+		{val: "t:data/book.xlsx", table: "t", explicit: true, filename: "data/book.xlsx"},
+	}
+
+	for _, tc := range testcases {
+		// 文件不存在，只检查解析结果
+		in, _ := parseFlyInput(tc.val, "table_0", reader.Options{})
+		assert.Equal(t, tc.table, in.Table)
+		assert.Equal(t, tc.explicit, in.Explicit)
+		assert.Equal(t, tc.filename, in.Filename)
+		assert.Equal(t, tc.sheet, in.Sheet)
+	}
+
+	assert.Equal(t, `C:\data\book.xlsx#Cost`, parseFlyInputFilename(`C:\data\book.xlsx#Cost`))
+	assert.Equal(t, `C:\data\book.xlsx`, parseFlyInputFilename(`cost:C:\data\book.xlsx`))
+	assert.Equal(t, reader.Stdin, parseFlyInputFilename("t:"+reader.Stdin))
+	assert.Equal(t, reader.Stdin, parseFlyInputFilename(reader.Stdin))
+}
+
+func TestSheetTableName(t *testing.T) {
+	in := flyInput{Table: "table_0", Filename: filepath.Join("data", "finance.xlsx")}
+	assert.Equal(t, "finance__Cost", sheetTableName(in, "Cost", 1))
+	assert.Equal(t, "finance__chengben", sheetTableName(in, "成本", 2))
+	assert.Equal(t, "finance__Q12023", sheetTableName(in, "Q1 2023!", 3))
+	assert.Equal(t, "finance__sheet4", sheetTableName(in, "!!", 4))
+
+	// 文件名不能作为表名时使用默认表名
+	in = flyInput{Table: "table_0", Filename: filepath.Join("data", "2023.xlsx")}
+	assert.Equal(t, "table_0__Cost", sheetTableName(in, "Cost", 1))
+
+	// 指定了表名时使用指定的表名
+	in = flyInput{Table: "fin", Explicit: true, Filename: filepath.Join("data", "finance.xlsx")}
+	assert.Equal(t, "fin__Cost", sheetTableName(in, "Cost", 1))
+}
+
+func TestCreateMemoryDatabaseForFlySheets(t *testing.T) {
+	dir := t.TempDir()
+	book := filepath.Join(dir, "finance.xlsx")
+
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", "Income")
+	assert.NoError(t, f.SetSheetRow("Income", "A1", &[]interface{}{"month", "amount"}))
+	assert.NoError(t, f.SetSheetRow("Income", "A2", &[]interface{}{"2023-01", 100}))
+	assert.NoError(t, f.SetSheetRow("Income", "A3", &[]interface{}{"2023-02", 200}))
+	f.NewSheet("Cost")
+	assert.NoError(t, f.SetSheetRow("Cost", "A1", &[]interface{}{"month", "cost"}))
+	assert.NoError(t, f.SetSheetRow("Cost", "A2", &[]interface{}{"2023-01", 30}))
+	assert.NoError(t, f.SaveAs(book))
+
+	db, err := openFlyDatabase(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	opt := FlyOption{InputFiles: []string{book, "c:" + book + "#2"}, CSVSepertor: ',', TempDS: ":memory:", Slient: true}
+	_, err = createMemoryDatabaseForFly(opt, db)
+	assert.NoError(t, err)
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM finance__Income").Scan(&count))
+	assert.Equal(t, 2, count)
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM finance__Cost").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	// 第一个 sheet 同时以视图的形式提供默认表名
+	var typ string
+	assert.NoError(t, db.QueryRow("SELECT type FROM sqlite_master WHERE name = 'table_0'").Scan(&typ))
+	assert.Equal(t, "view", typ)
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM table_0 WHERE amount > 0").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	// 按照序号指定 sheet 时只加载该 sheet
+	var cost int
+	assert.NoError(t, db.QueryRow("SELECT cost FROM c").Scan(&cost))
+	assert.Equal(t, 30, cost)
+
+	rows, err := db.Query("SELECT filename, name FROM meta")
+	assert.NoError(t, err)
+	defer rows.Close()
+
+	metas := make([]string, 0)
+	for rows.Next() {
+		var filename, name string
+		assert.NoError(t, rows.Scan(&filename, &name))
+		metas = append(metas, strings.TrimPrefix(filename, dir)+" "+name)
+	}
+	assert.NoError(t, rows.Err())
+
+	sort.Strings(metas)
+	sep := string(filepath.Separator)
+	assert.Equal(t, []string{
+		sep + "finance.xlsx table_0",
+		sep + "finance.xlsx#2 c",
+		sep + "finance.xlsx#Cost finance__Cost",
+		sep + "finance.xlsx#Income finance__Income",
+	}, metas)
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/mylxsw/go-utils/array"
+)
+
+// flyLoadBatchSize is the number of rows committed in one transaction when loading files
+const flyLoadBatchSize = 10000
+
+// flyTableLoader insert rows to a table in batches, each batch is committed in a transaction using a prepared statement
+//
+// The transaction is started lazily when the first row is inserted, other statements (such as create table) must be executed
+// after the loader is flushed, otherwise they will be executed on another connection, which is a different database for :memory:
+type flyTableLoader struct {
+	db        *sql.DB
+	batchSize int
+
+	table  string
+	fields []string
+
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	pending int
+	rows    int
+}
+
+func newFlyTableLoader(db *sql.DB, batchSize int) *flyTableLoader {
+	return &flyTableLoader{db: db, batchSize: batchSize}
+}
+
+// Reset flush pending rows and switch to a new table
+func (l *flyTableLoader) Reset(table string, fields []string) error {
+	if err := l.Flush(); err != nil {
+		return err
+	}
+
+	l.table = table
+	l.fields = fields
+	return nil
+}
+
+// Insert insert a row, the first field is the row id, missing fields are set to NULL and extra fields are dropped
+func (l *flyTableLoader) Insert(id int, data []string) error {
+	if l.tx == nil {
+		if err := l.begin(); err != nil {
+			return err
+		}
+	}
+
+	args := make([]any, len(l.fields))
+	args[0] = id
+	for i := 1; i < len(l.fields) && i <= len(data); i++ {
+		args[i] = data[i-1]
+	}
+
+	if _, err := l.stmt.Exec(args...); err != nil {
+		return fmt.Errorf("insert data failed: %w", err)
+	}
+
+	l.rows++
+	l.pending++
+	if l.pending >= l.batchSize {
+		return l.Flush()
+	}
+
+	return nil
+}
+
+// Rows return the number of rows inserted
+func (l *flyTableLoader) Rows() int {
+	return l.rows
+}
+
+func (l *flyTableLoader) begin() error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s);",
+		l.table,
+		strings.Join(l.fields, ","),
+		strings.Join(array.Repeat("?", len(l.fields)), ","),
+	))
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("prepare insert statement for %s failed: %w", l.table, err)
+	}
+
+	l.tx, l.stmt, l.pending = tx, stmt, 0
+	return nil
+}
+
+// Flush commit the pending rows
+func (l *flyTableLoader) Flush() error {
+	if l.tx == nil {
+		return nil
+	}
+
+	defer func() { l.tx, l.stmt, l.pending = nil, nil, 0 }()
+
+	_ = l.stmt.Close()
+	if err := l.tx.Commit(); err != nil {
+		return fmt.Errorf("commit data of %s failed: %w", l.table, err)
+	}
+
+	return nil
+}
+
+// Rollback discard the pending rows
+func (l *flyTableLoader) Rollback() {
+	if l.tx == nil {
+		return
+	}
+
+	_ = l.stmt.Close()
+	_ = l.tx.Rollback()
+	l.tx, l.stmt, l.pending = nil, nil, 0
+}
+
+// isFileTempDS return whether the temporary database is stored in file
+func isFileTempDS(ds string) bool {
+	return ds != "" && ds != ":memory:" && !strings.Contains(ds, "mode=memory")
+}
+
+// setLoadPragmas set pragmas to speed up loading for file database, it returns a function to restore the original values
+func setLoadPragmas(db *sql.DB) (func(), error) {
+	var journalMode string
+	var synchronous int
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		return nil, fmt.Errorf("query journal_mode failed: %w", err)
+	}
+
+	if err := db.QueryRow("PRAGMA synchronous").Scan(&synchronous); err != nil {
+		return nil, fmt.Errorf("query synchronous failed: %w", err)
+	}
+
+	// 临时数据库中的数据可以随时从原文件中重新加载，因此加载时不需要保证崩溃时的数据安全
+	if _, err := db.Exec("PRAGMA journal_mode = MEMORY; PRAGMA synchronous = OFF;"); err != nil {
+		return nil, fmt.Errorf("set pragmas failed: %w", err)
+	}
+
+	return func() {
+		_, _ = db.Exec(fmt.Sprintf("PRAGMA journal_mode = %s; PRAGMA synchronous = %d;", journalMode, synchronous))
+	}, nil
+}
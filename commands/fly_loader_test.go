@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestFlyTableLoader(t *testing.T) {
+	db, err := openFlyDatabase(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("CREATE TABLE t (__rowid, a, b)")
+	assert.NoError(t, err)
+
+	loader := newFlyTableLoader(db, 3)
+	assert.NoError(t, loader.Reset("t", []string{"__rowid", "a", "b"}))
+
+	// 每 3 行提交一次，最后不足一批的行在 Rollback 时被丢弃
+	for i := 1; i <= 8; i++ {
+		assert.NoError(t, loader.Insert(i, []string{fmt.Sprintf("a%d", i), "b", "dropped"}))
+	}
+	assert.Equal(t, 8, loader.Rows())
+
+	loader.Rollback()
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM t").Scan(&count))
+	assert.Equal(t, 6, count)
+
+	assert.NoError(t, loader.Insert(9, []string{"a9"}))
+	assert.NoError(t, loader.Flush())
+
+	var b interface{}
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*), b FROM t WHERE __rowid = 9").Scan(&count, &b))
+	assert.Equal(t, 1, count)
+	assert.Equal(t, nil, b)
+
+	// 插入失败时返回错误
+	assert.NoError(t, loader.Reset("missing", []string{"__rowid", "a"}))
+	assert.True(t, loader.Insert(10, []string{"a10"}) != nil)
+}
+
+func TestSetLoadPragmas(t *testing.T) {
+	db, err := openFlyDatabase(filepath.Join(t.TempDir(), "fly.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	restore, err := setLoadPragmas(db)
+	assert.NoError(t, err)
+
+	var journalMode string
+	var synchronous int
+	assert.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	assert.NoError(t, db.QueryRow("PRAGMA synchronous").Scan(&synchronous))
+	assert.Equal(t, "memory", strings.ToLower(journalMode))
+	assert.Equal(t, 0, synchronous)
+
+	restore()
+
+	assert.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	assert.NoError(t, db.QueryRow("PRAGMA synchronous").Scan(&synchronous))
+	assert.Equal(t, "delete", strings.ToLower(journalMode))
+	assert.Equal(t, 2, synchronous)
+}
+
+func TestCreateMemoryDatabaseForFly(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "users.csv")
+
+	var sb strings.Builder
+	sb.WriteString("id,name,city\n")
+	for i := 1; i <= 25; i++ {
+		sb.WriteString(fmt.Sprintf("%d,user%d,city%d\n", i, i, i%3))
+	}
+	assert.NoError(t, os.WriteFile(filename, []byte(sb.String()), 0644))
+
+	tempDS := filepath.Join(dir, "fly.db")
+	db, err := openFlyDatabase(tempDS)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	opt := FlyOption{InputFiles: []string{"users:" + filename}, CSVSepertor: ',', TempDS: tempDS, Slient: true, Indexes: []string{"users.city"}}
+	tables, err := createMemoryDatabaseForFly(opt, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tables))
+	assert.Equal(t, []string{"city"}, tables[0].Indexes)
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count))
+	assert.Equal(t, 25, count)
+
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_users_city'").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	// ANALYZE 生成的统计信息
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'users'").Scan(&count))
+	assert.True(t, count > 0)
+
+	// 加载完成后恢复数据库原有的 pragma
+	var journalMode string
+	assert.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	assert.Equal(t, "delete", strings.ToLower(journalMode))
+
+	// 文件变化后重新加载，之前创建的索引会被重建
+	assert.NoError(t, os.WriteFile(filename, []byte("id,name,city\n1,user1,city1\n"), 0644))
+	opt.Indexes = nil
+	tables, err = createMemoryDatabaseForFly(opt, db)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"city"}, tables[0].Indexes)
+
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_users_city'").Scan(&count))
+	assert.Equal(t, 1, count)
+}
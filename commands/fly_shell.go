@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/extracter"
+	"github.com/mylxsw/heimdall/mask"
+	"github.com/mylxsw/heimdall/query"
+	"github.com/peterh/liner"
+)
+
+const flyShellHelp = `Enter SQL statements terminated with a ";", or meta-commands:
+
+  .tables                 show all tables and their columns
+  .schema [TABLE]         show the create statements of tables
+  .format [FORMAT]        show or set the output format: %s
+  .output [FILE]          write query results to FILE, each query overwrites it, without FILE write to STDOUT
+  .load FILE [as TABLE]   load a xlsx or csv file as table
+  .help                   show this message
+  .quit                   exit the shell
+`
+
+// flyShell is an interactive sql shell, files are loaded only once, and can be queried multiple times
+type flyShell struct {
+	opt     FlyOption
+	db      *sql.DB
+	tables  []Table
+	handler func(sqlStr string, args []interface{}, format string, output io.Writer, noHeader bool, dataProcesser func(*extracter.Rows)) (int, error)
+
+	format string
+	output string
+}
+
+func newFlyShell(opt FlyOption, db *sql.DB, tables []Table, masker *mask.Masker) *flyShell {
+	return &flyShell{
+		opt:     opt,
+		db:      db,
+		tables:  tables,
+		handler: query.NewStandardQueryWriterWithDB(db, opt.TargetTableForSQLFormat, opt.QueryTimeout, masker, opt.CSVOutput),
+		format:  opt.Format,
+		output:  opt.Output,
+	}
+}
+
+// flyShellHistoryFile return the path of history file
+func flyShellHistoryFile() string {
+	return expandHome("~/.heimdall_history")
+}
+
+// Run start the read-eval-print loop until EOF or .quit
+func (s *flyShell) Run() error {
+	line := liner.NewLiner()
+	defer line.Close()
+
+	line.SetCtrlCAborts(true)
+	line.SetMultiLineMode(true)
+
+	if f, err := os.Open(flyShellHistoryFile()); err == nil {
+		_, _ = line.ReadHistory(f)
+		_ = f.Close()
+	}
+
+	defer func() {
+		if f, err := os.Create(flyShellHistoryFile()); err == nil {
+			_, _ = line.WriteHistory(f)
+			_ = f.Close()
+		}
+	}()
+
+	fmt.Printf("%d tables loaded, enter .help for usage hints.\n", len(s.tables))
+
+	var buffer []string
+	for {
+		prompt := "heimdall> "
+		if len(buffer) > 0 {
+			prompt = "     ...> "
+		}
+
+		input, err := line.Prompt(prompt)
+		if err != nil {
+			if errors.Is(err, liner.ErrPromptAborted) {
+				// Ctrl+C 放弃当前输入的语句
+				buffer = nil
+				continue
+			}
+
+			if errors.Is(err, io.EOF) {
+				fmt.Println()
+				return nil
+			}
+
+			return err
+		}
+
+		trimed := strings.TrimSpace(input)
+		if trimed == "" {
+			continue
+		}
+
+		if len(buffer) == 0 && strings.HasPrefix(trimed, ".") {
+			line.AppendHistory(trimed)
+			if quit := s.meta(trimed); quit {
+				return nil
+			}
+
+			continue
+		}
+
+		buffer = append(buffer, input)
+
+		sqlStr := strings.Join(buffer, "\n")
+		end := flyStatementEnd(sqlStr)
+		if end < 0 {
+			continue
+		}
+
+		buffer = nil
+
+		line.AppendHistory(sqlStr)
+		if err := s.query(rewriteMySQLCompatSQL(strings.TrimSpace(sqlStr[:end]))); err != nil {
+			fmt.Fprintf(os.Stderr, "😨 %s\n", err)
+		}
+	}
+}
+
+// flyStatementEnd return the position of the ";" which terminates the statement, the ";" must be the last
+// character outside of quotes and comments, it returns -1 if the statement is not finished yet
+func flyStatementEnd(sqlStr string) int {
+	end := -1
+	var quote byte
+	for i := 0; i < len(sqlStr); i++ {
+		c := sqlStr[i]
+		switch {
+		case quote != 0:
+			// 引号中连续的两个引号为转义
+			if c == quote {
+				if i+1 < len(sqlStr) && sqlStr[i+1] == quote {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote, end = c, -1
+		case c == '-' && strings.HasPrefix(sqlStr[i:], "--"):
+			pos := strings.IndexByte(sqlStr[i:], '\n')
+			if pos < 0 {
+				return end
+			}
+			i += pos
+		case c == '/' && strings.HasPrefix(sqlStr[i:], "/*"):
+			pos := strings.Index(sqlStr[i+2:], "*/")
+			if pos < 0 {
+				return -1
+			}
+			i += pos + 3
+		case c == ';':
+			end = i
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			end = -1
+		}
+	}
+
+	if quote != 0 {
+		return -1
+	}
+
+	return end
+}
+
+// query execute the sql and write the result to output
+func (s *flyShell) query(sqlStr string) error {
+	if s.format == "sql" && s.opt.TargetTableForSQLFormat == "" {
+		return fmt.Errorf("when the format is sql, the table name (--table) is required")
+	}
+
+	startTs := time.Now()
+
+	if s.opt.AutoIndex {
+		created, err := createFlyIndexes(s.db, autoIndexesFromSQL(sqlStr, s.tables))
+		if err != nil {
+			return err
+		}
+
+		if created > 0 {
+			if _, err := s.db.Exec("ANALYZE;"); err != nil {
+				return fmt.Errorf("analyze tables failed: %w", err)
+			}
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if s.output != "" {
+		f, err := os.Create(s.output)
+		if err != nil {
+			return fmt.Errorf("create output file failed: %w", err)
+		}
+		defer f.Close()
+
+		ew, err := wrapOutputEncoding(f, s.format, ternary.If(s.format == "csv", s.opt.OutputEncoding, ""))
+		if err != nil {
+			return err
+		}
+		defer ew.Close()
+
+		w = ew
+	}
+
+	count, err := s.handler(sqlStr, nil, s.format, w, s.opt.NoHeader, nil)
+	if err != nil {
+		return err
+	}
+
+	if s.output != "" {
+		fmt.Printf("%d rows written to %s (%s)\n", count, s.output, time.Since(startTs).Round(time.Millisecond))
+	} else {
+		fmt.Printf("\n%d rows in set (%s)\n", count, time.Since(startTs).Round(time.Millisecond))
+	}
+
+	return nil
+}
+
+// meta execute meta-command, it returns true if the shell should exit
+func (s *flyShell) meta(cmd string) bool {
+	args := strings.Fields(cmd)
+
+	var err error
+	switch args[0] {
+	case ".quit", ".exit":
+		return true
+	case ".help":
+		fmt.Printf(flyShellHelp, strings.Join(query.SupportedStandardFormats, ", "))
+	case ".tables":
+		err = showTables(s.tables, query.NewStandardQueryWriterWithDB(s.db, "", s.opt.QueryTimeout, nil, s.opt.CSVOutput))
+	case ".schema":
+		err = s.schema(os.Stdout, args[1:])
+	case ".format":
+		if len(args) < 2 {
+			fmt.Println(s.format)
+			break
+		}
+
+		if !array.In(args[1], query.SupportedStandardFormats) {
+			err = fmt.Errorf("unsupported format %s, support %s", args[1], strings.Join(query.SupportedStandardFormats, ", "))
+			break
+		}
+
+		s.format = args[1]
+	case ".output":
+		if len(args) < 2 {
+			s.output = ""
+			break
+		}
+
+		s.output = expandHome(args[1])
+
+		// 根据文件扩展名自动设置输出格式，如 out.xlsx
+		ext := strings.TrimPrefix(filepath.Ext(s.output), ".")
+		if ext == "md" {
+			ext = "markdown"
+		}
+		if array.In(ext, query.SupportedStandardFormats) {
+			s.format = ext
+		}
+	case ".load":
+		err = s.load(args[1:])
+	default:
+		err = fmt.Errorf("unknown command %s, enter .help for usage hints", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "😨 %s\n", err)
+	}
+
+	return false
+}
+
+// schema write the create statements of tables to w
+func (s *flyShell) schema(w io.Writer, tables []string) error {
+	sqlStr := "SELECT name 'table', sql FROM sqlite_master WHERE type = 'table' AND name != 'meta'"
+	args := make([]interface{}, 0)
+	if len(tables) > 0 {
+		sqlStr += fmt.Sprintf(" AND name IN (%s)", strings.Join(array.Repeat("?", len(tables)), ","))
+		args = append(args, array.Map(tables, func(t string, _ int) interface{} { return t })...)
+	}
+
+	_, err := s.handler(sqlStr+" ORDER BY name", args, "table", w, false, nil)
+	return err
+}
+
+// load load a file as table, the syntax is: .load FILE [as TABLE]
+func (s *flyShell) load(args []string) error {
+	var input string
+	switch {
+	case len(args) == 1:
+		input = expandHome(args[0])
+	case len(args) == 3 && strings.EqualFold(args[1], "as"):
+		input = args[2] + ":" + expandHome(args[0])
+	default:
+		return fmt.Errorf("usage: .load FILE [as TABLE]")
+	}
+
+	opt := s.opt
+	opt.InputFiles = []string{input}
+	opt.ShowTables = false
+
+	tables, err := createMemoryDatabaseForFly(opt, s.db)
+	if err != nil {
+		return fmt.Errorf("load file failed: %w", err)
+	}
+
+	s.tables = tables
+	fmt.Printf("%d tables loaded\n", len(s.tables))
+
+	return nil
+}
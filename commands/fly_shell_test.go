@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestFlyStatementEnd(t *testing.T) {
+	testCases := map[string]int{
+		"SELECT 1":                                -1,
+		"SELECT 1;":                               8,
+		"SELECT 1;  ":                             8,
+		"SELECT 1; -- comment":                    8,
+		"SELECT 1 -- comment;":                    -1,
+		"SELECT 1 -- comment;\n;":                 21,
+		"SELECT ';' AS a":                         -1,
+		"SELECT ';' AS a;":                        15,
+		"SELECT 'it''s;' AS a":                    -1,
+		"SELECT 'unclosed;":                       -1,
+		"SELECT \"a;b\" FROM `t;`;":               22,
+		"SELECT 1; /* comment; */":                8,
+		"SELECT 1 /* comment;":                    -1,
+		"SELECT 1; SELECT 2":                      -1,
+		"SELECT 1 AS a,\n  2 AS b;\n-- trailing;": 23,
+	}
+
+	for sqlStr, expected := range testCases {
+		assert.Equal(t, expected, flyStatementEnd(sqlStr))
+	}
+}
+
+func createFlyShellForTest(t *testing.T) *flyShell {
+	db, err := openFlyDatabase(":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	db.SetMaxOpenConns(1)
+
+	opt := FlyOption{Format: "table", QueryTimeout: 10 * time.Second, Slient: true, TempDS: ":memory:"}
+	tables, err := createMemoryDatabaseForFly(opt, db)
+	assert.NoError(t, err)
+
+	for _, stmt := range []string{"CREATE TABLE users (id INTEGER, name TEXT)", "CREATE TABLE orders (id INTEGER, uid INTEGER)"} {
+		_, err := db.Exec(stmt)
+		assert.NoError(t, err)
+	}
+
+	return newFlyShell(opt, db, tables, nil)
+}
+
+func TestFlyShellFormat(t *testing.T) {
+	s := createFlyShellForTest(t)
+
+	assert.False(t, s.meta(".format csv"))
+	assert.Equal(t, "csv", s.format)
+
+	// 不支持的格式不会修改当前的输出格式
+	s.meta(".format docx")
+	assert.Equal(t, "csv", s.format)
+
+	assert.True(t, s.meta(".quit"))
+}
+
+func TestFlyShellOutput(t *testing.T) {
+	s := createFlyShellForTest(t)
+	dir := t.TempDir()
+
+	s.meta(".output " + filepath.Join(dir, "out.xlsx"))
+	assert.Equal(t, filepath.Join(dir, "out.xlsx"), s.output)
+	assert.Equal(t, "xlsx", s.format)
+
+	s.meta(".output " + filepath.Join(dir, "out.md"))
+	assert.Equal(t, "markdown", s.format)
+
+	// 无法识别的扩展名保持当前的输出格式
+	s.meta(".output " + filepath.Join(dir, "out.txt"))
+	assert.Equal(t, filepath.Join(dir, "out.txt"), s.output)
+	assert.Equal(t, "markdown", s.format)
+
+	s.meta(".output")
+	assert.Equal(t, "", s.output)
+}
+
+func TestFlyShellSchema(t *testing.T) {
+	s := createFlyShellForTest(t)
+
+	var buf bytes.Buffer
+	assert.NoError(t, s.schema(&buf, nil))
+	assert.True(t, strings.Contains(buf.String(), "CREATE TABLE users"))
+	assert.True(t, strings.Contains(buf.String(), "CREATE TABLE orders"))
+	assert.False(t, strings.Contains(buf.String(), "CREATE TABLE meta"))
+
+	buf.Reset()
+	assert.NoError(t, s.schema(&buf, []string{"orders"}))
+	assert.True(t, strings.Contains(buf.String(), "CREATE TABLE orders"))
+	assert.False(t, strings.Contains(buf.String(), "CREATE TABLE users"))
+}
+
+func TestFlyShellLoad(t *testing.T) {
+	s := createFlyShellForTest(t)
+
+	// 文件不存在时返回错误，而不是 panic
+	assert.True(t, s.load([]string{filepath.Join(t.TempDir(), "missing.csv")}) != nil)
+	assert.True(t, s.load([]string{"a", "b"}) != nil)
+}
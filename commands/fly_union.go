@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// flySourceFileField is the column recording the file of each row, for the table loaded from multiple files
+const flySourceFileField = "_source_file"
+
+// flyUnionTable merge the files matched by one pattern into a single table, the columns are aligned by header,
+// and the columns not in the table before are appended to the end
+type flyUnionTable struct {
+	useColumnNumAsName bool
+
+	// headers and fields are the original headers and field names of the data columns
+	headers []string
+	fields  []string
+	// mapping is the position in headers for each column of the current file
+	mapping []int
+}
+
+func newFlyUnionTable(useColumnNumAsName bool) *flyUnionTable {
+	return &flyUnionTable{useColumnNumAsName: useColumnNumAsName}
+}
+
+// Merge add the headers of a new file, it returns the fields and headers which are not in the table before
+func (u *flyUnionTable) Merge(headers []string) (newFields []string, newHeaders []string) {
+	// 同一个文件中可能存在同名的列，每一列只能匹配一次
+	matched := make(map[int]bool)
+	u.mapping = make([]int, len(headers))
+	for i, header := range headers {
+		pos := -1
+		for j, h := range u.headers {
+			if h == header && !matched[j] {
+				pos = j
+				break
+			}
+		}
+
+		if pos < 0 {
+			field := u.uniqueField(createDBField(header, len(u.headers), u.useColumnNumAsName).Field)
+
+			pos = len(u.headers)
+			u.headers = append(u.headers, header)
+			u.fields = append(u.fields, field)
+
+			newFields = append(newFields, field)
+			newHeaders = append(newHeaders, header)
+		}
+
+		matched[pos] = true
+		u.mapping[i] = pos
+	}
+
+	return newFields, newHeaders
+}
+
+// uniqueField add a suffix to the field if it is already used
+func (u *flyUnionTable) uniqueField(field string) string {
+	used := func(name string) bool {
+		if strings.EqualFold(name, flySourceFileField) {
+			return true
+		}
+
+		for _, f := range u.fields {
+			if strings.EqualFold(f, name) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	name := field
+	for i := 2; used(name); i++ {
+		name = fmt.Sprintf("%s_%d", field, i)
+	}
+
+	return name
+}
+
+// Row align the data of the current file to the columns of table, the source file is the first column
+func (u *flyUnionTable) Row(source string, data []string) []string {
+	row := make([]string, len(u.headers)+1)
+	row[0] = source
+	for i, val := range data {
+		if i < len(u.mapping) {
+			row[u.mapping[i]+1] = val
+		}
+	}
+
+	return row
+}
+
+// Fields return the field names of table, including the source file column
+func (u *flyUnionTable) Fields() []string {
+	return append([]string{flySourceFileField}, u.fields...)
+}
+
+// Headers return the original headers of table, including the source file column
+func (u *flyUnionTable) Headers() []string {
+	return append([]string{flySourceFileField}, u.headers...)
+}
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestFlyUnionTable(t *testing.T) {
+	union := newFlyUnionTable(false)
+
+	fields, headers := union.Merge([]string{"id", "name"})
+	assert.Equal(t, []string{"id", "name"}, fields)
+	assert.Equal(t, []string{"id", "name"}, headers)
+	assert.Equal(t, []string{"a.csv", "1", "foo"}, union.Row("a.csv", []string{"1", "foo"}))
+
+	fields, headers = union.Merge([]string{"name", "ID", "id", "remark"})
+	assert.Equal(t, []string{"ID_2", "remark"}, fields)
+	assert.Equal(t, []string{"ID", "remark"}, headers)
+	assert.Equal(t, []string{"b.csv", "2", "bar", "x", ""}, union.Row("b.csv", []string{"bar", "x", "2"}))
+
+	assert.Equal(t, []string{flySourceFileField, "id", "name", "ID_2", "remark"}, union.Fields())
+	assert.Equal(t, []string{flySourceFileField, "id", "name", "ID", "remark"}, union.Headers())
+}
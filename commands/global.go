@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/mylxsw/heimdall/query"
@@ -41,8 +43,22 @@ func BuildGlobalFlags() []cli.Flag {
 		&cli.StringFlag{Name: "host", Aliases: []string{"H"}, Value: "127.0.0.1", Usage: "MySQL host"},
 		&cli.IntFlag{Name: "port", Aliases: []string{"P"}, Value: 3306, Usage: "MySQL port"},
 		&cli.StringFlag{Name: "user", Aliases: []string{"u"}, Value: "root", Usage: "MySQL user"},
-		&cli.StringFlag{Name: "password", Aliases: []string{"p"}, Value: "", Usage: "MySQL password"},
+		&cli.StringFlag{Name: "password", Aliases: []string{"p"}, Value: "", Usage: "MySQL password, it will leak into shell history, consider using --password-prompt, --defaults-file, MYSQL_PWD environment or profile instead"},
+		&cli.BoolFlag{Name: "password-prompt", Usage: "ask for MySQL password interactively"},
+		&cli.StringFlag{Name: "defaults-file", Value: "", Usage: "read connection options from [client] section of MySQL option file, if not set, ~/.my.cnf is read when it exists"},
+		&cli.BoolFlag{Name: "no-defaults", Usage: "do not read ~/.my.cnf when --defaults-file is not set"},
+		&cli.StringFlag{Name: "profile", Value: "", Usage: "use the named connection profile in config file"},
+		&cli.StringFlag{Name: "config", Value: defaultConfigPath(), EnvVars: []string{"HEIMDALL_CONFIG"}, Usage: "config file path"},
 		&cli.StringFlag{Name: "database", Aliases: []string{"d"}, Value: "", Usage: "MySQL database"},
+		&cli.StringFlag{Name: "socket", Value: "", Usage: "MySQL unix socket file path, if set, host and port will be ignored"},
+		&cli.StringFlag{Name: "ssl-mode", Value: "", Usage: "MySQL ssl mode: disabled, preferred, required, verify-ca, verify-identity"},
+		&cli.StringFlag{Name: "ssl-ca", Value: "", Usage: "CA certificate file for verifying MySQL server certificate"},
+		&cli.StringFlag{Name: "ssl-cert", Value: "", Usage: "client certificate file for MySQL TLS connection"},
+		&cli.StringFlag{Name: "ssl-key", Value: "", Usage: "client private key file for MySQL TLS connection"},
+		&cli.StringFlag{Name: "ssh", Value: "", Usage: "connect to MySQL through ssh tunnel, eg: user@bastion[:port]"},
+		&cli.StringFlag{Name: "ssh-key", Value: "", Usage: "ssh private key file, if not set, ssh-agent and ~/.ssh/id_* will be used"},
+		&cli.BoolFlag{Name: "ssh-insecure", Usage: "do not verify the host key of ssh server using ~/.ssh/known_hosts"},
+		&cli.StringSliceFlag{Name: "dsn-param", Usage: "extra MySQL DSN params, eg: charset=utf8mb4, collation=utf8mb4_bin, timeout=5s, readTimeout=30s, this flag can be specified multiple times"},
 		&cli.BoolFlag{Name: "debug", Aliases: []string{"D"}, Value: false, Usage: "debug mode"},
 		&cli.DurationFlag{Name: "connect-timeout", Value: 3 * time.Second, Usage: "database connect timeout"},
 		&cli.BoolFlag{Name: "beta", Usage: "enable beta feature, may be unstable, use at your own risk"},
@@ -58,29 +74,153 @@ type GlobalOption struct {
 	Debug          bool
 	Beta           bool
 	ConnectTimeout time.Duration
+
+	Socket string
+	TLS    query.TLSOption
+	SSH    query.SSHOption
+	Params map[string]string
+
+	// network and tls are the names registered to mysql driver by registerConnection
+	network string
+	tls     string
 }
 
 func (globalOption GlobalOption) DSN() string {
-	return query.BuildConnStr(
-		globalOption.Database,
-		globalOption.User,
-		globalOption.Password,
-		globalOption.Host,
-		globalOption.Port,
-	)
+	return query.ConnOption{
+		Host:     globalOption.Host,
+		Port:     globalOption.Port,
+		User:     globalOption.User,
+		Password: globalOption.Password,
+		Database: globalOption.Database,
+		Socket:   globalOption.Socket,
+		Net:      globalOption.network,
+		TLS:      globalOption.tls,
+		Params:   globalOption.Params,
+	}.ConnStr()
+}
+
+// registerConnection register the tls config and ssh tunnel to mysql driver if needed
+func (globalOption *GlobalOption) registerConnection() error {
+	tlsName, err := query.RegisterTLSConfig(globalOption.TLS, globalOption.Host)
+	if err != nil {
+		return err
+	}
+
+	globalOption.tls = tlsName
+
+	if globalOption.SSH.Address != "" {
+		network, err := query.RegisterSSHTunnel(globalOption.SSH)
+		if err != nil {
+			return err
+		}
+
+		globalOption.network = network
+	}
+
+	return nil
 }
 
-func resolveGlobalOption(c *cli.Context) GlobalOption {
-	return GlobalOption{
+// resolveGlobalOption resolve connection options, the priority from low to high is:
+// flag default values, MYSQL_PWD environment, --defaults-file (or ~/.my.cnf), --profile, flags explicitly set,
+// and the password prompt will be shown if --password-prompt or password_prompt in profile is set.
+// A socket inherited from a lower priority source is dropped when host or port is set without socket
+func resolveGlobalOption(c *cli.Context) (GlobalOption, error) {
+	opt := GlobalOption{
 		Host:           c.String("host"),
 		Port:           c.Int("port"),
 		User:           c.String("user"),
-		Password:       c.String("password"),
+		Password:       os.Getenv("MYSQL_PWD"),
 		Database:       c.String("database"),
 		Debug:          c.Bool("debug"),
 		ConnectTimeout: c.Duration("connect-timeout"),
 		Beta:           c.Bool("beta"),
 	}
+
+	var err error
+	if defaultsFile := resolveDefaultsFile(c.String("defaults-file"), c.Bool("no-defaults")); defaultsFile != "" {
+		if opt, err = applyMySQLOptionFile(opt, defaultsFile); err != nil {
+			return opt, err
+		}
+	}
+
+	passwordPrompt := c.Bool("password-prompt")
+	if name := c.String("profile"); name != "" {
+		profile, err := loadProfile(c.String("config"), name)
+		if err != nil {
+			return opt, err
+		}
+
+		if opt, err = profile.apply(opt); err != nil {
+			return opt, err
+		}
+
+		passwordPrompt = passwordPrompt || (profile.PasswordPrompt && !c.IsSet("password"))
+	}
+
+	if c.IsSet("host") {
+		opt.Host = c.String("host")
+	}
+	if c.IsSet("port") {
+		opt.Port = c.Int("port")
+	}
+	if c.IsSet("user") {
+		opt.User = c.String("user")
+	}
+	if c.IsSet("password") {
+		opt.Password = c.String("password")
+	}
+	if c.IsSet("database") {
+		opt.Database = c.String("database")
+	}
+	if c.IsSet("connect-timeout") {
+		opt.ConnectTimeout = c.Duration("connect-timeout")
+	}
+	if c.IsSet("socket") {
+		opt.Socket = c.String("socket")
+	} else if c.IsSet("host") || c.IsSet("port") {
+		// 通过 -H 或 -P 指定了服务器时使用 TCP 连接，而不是 defaults file 或 profile 中的 socket
+		opt.Socket = ""
+	}
+	if c.IsSet("ssl-mode") {
+		opt.TLS.Mode = c.String("ssl-mode")
+	}
+	if c.IsSet("ssl-ca") {
+		opt.TLS.CA = c.String("ssl-ca")
+	}
+	if c.IsSet("ssl-cert") {
+		opt.TLS.Cert = c.String("ssl-cert")
+	}
+	if c.IsSet("ssl-key") {
+		opt.TLS.Key = c.String("ssl-key")
+	}
+	if c.IsSet("ssh") {
+		opt.SSH.Address = c.String("ssh")
+	}
+	if c.IsSet("ssh-key") {
+		opt.SSH.KeyFile = c.String("ssh-key")
+	}
+	if c.IsSet("ssh-insecure") {
+		opt.SSH.Insecure = c.Bool("ssh-insecure")
+	}
+	for _, param := range c.StringSlice("dsn-param") {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || key == "" {
+			return opt, fmt.Errorf("invalid dsn param %s, should be KEY=VALUE", param)
+		}
+
+		if opt.Params == nil {
+			opt.Params = make(map[string]string)
+		}
+		opt.Params[key] = value
+	}
+
+	if passwordPrompt {
+		if opt.Password, err = passwordFromPrompt(opt.User, opt.Host); err != nil {
+			return opt, err
+		}
+	}
+
+	return opt, opt.registerConnection()
 }
 
 func fileHash(filepath string) (string, error) {
@@ -97,3 +237,18 @@ func fileHash(filepath string) (string, error) {
 
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// filesHash return the hash of files, it changes when any file is changed, added or removed
+func filesHash(files []string) (string, error) {
+	h := md5.New()
+	for _, f := range files {
+		hash, err := fileHash(f)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%s:%s\n", f, hash)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/mylxsw/heimdall/query"
 	"github.com/mylxsw/heimdall/reader"
 	"github.com/mylxsw/heimdall/render"
+	"github.com/mylxsw/heimdall/validator"
 	"github.com/urfave/cli/v2"
 )
 
@@ -26,6 +27,7 @@ type ImportOption struct {
 	Includes    []string
 	Excludes    []string
 	CSVSepertor rune
+	Sheet       string
 	UsingTx     bool
 	DryRun      bool
 
@@ -35,6 +37,8 @@ type ImportOption struct {
 	WithCreateTime       bool
 	TableStructureFormat string
 	Slient               bool
+	Rules                string
+	ReaderOption         reader.Options
 }
 
 // resolveImportOption resolve import option
@@ -64,7 +68,8 @@ func resolveImportOption(c *cli.Context) ImportOption {
 		FieldsMap:            fieldsMap,
 		Includes:             includes,
 		Excludes:             ternary.If(len(includes) > 0, []string{}, excludes),
-		CSVSepertor:          rune(c.String("csv-sepertor")[0]),
+		CSVSepertor:          parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor")),
+		Sheet:                c.String("sheet"),
 		UsingTx:              c.Bool("tx"),
 		DryRun:               c.Bool("dry-run"),
 		CreateTable:          c.Bool("create-table"),
@@ -73,18 +78,21 @@ func resolveImportOption(c *cli.Context) ImportOption {
 		WithCreateTime:       c.Bool("with-ts"),
 		TableStructureFormat: c.String("table-structure-format"),
 		Slient:               c.Bool("slient"),
+		Rules:                c.String("rules"),
+		ReaderOption:         resolveReaderOption(c),
 	}
 }
 
 // BuildImportFlags build import flags
 func BuildImportFlags() []cli.Flag {
-	return append(BuildGlobalFlags(), []cli.Flag{
-		&cli.StringSliceFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, this flag can be specified multiple times for importing multiple files at the same time", Required: true},
+	return append(append(BuildGlobalFlags(), []cli.Flag{
+		&cli.StringSliceFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, - for STDIN (--input-format is required), a glob pattern or a directory, this flag can be specified multiple times for importing multiple files at the same time", Required: true},
 		&cli.StringFlag{Name: "table", Aliases: []string{"t"}, Usage: "target table name", Required: true},
 		&cli.StringSliceFlag{Name: "field", Aliases: []string{"f"}, Usage: "field map, eg: excel_field:db_field, this flag can be specified multiple times"},
 		&cli.StringSliceFlag{Name: "include", Aliases: []string{"I"}, Usage: "include fields, if set, only these fields will be imported, this flag can be specified multiple times"},
 		&cli.StringSliceFlag{Name: "exclude", Aliases: []string{"E"}, Usage: "exclude fields, if set, these fields will be ignored, this flag can be specified multiple times"},
-		&cli.StringFlag{Name: "csv-sepertor", Value: ",", Usage: "csv file sepertor, default is ','"},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
+		&cli.StringFlag{Name: "sheet", Usage: "the sheet name or index (start from 1) of excel file to import, default is the first sheet"},
 		&cli.BoolFlag{Name: "tx", Aliases: []string{"T"}, Usage: "import data using transaction, all success or all failure, only work with InnoDB or other engines that support transaction"},
 		&cli.BoolFlag{Name: "dry-run", Usage: "perform import tests to verify correctness of imported files, but do not commit transactions, only work with InnoDB or other engines that support transaction"},
 		&cli.BoolFlag{Name: "create-table", Usage: "create table automatically if not exists"},
@@ -92,7 +100,8 @@ func BuildImportFlags() []cli.Flag {
 		&cli.BoolFlag{Name: "with-ts", Usage: "add created_at column to table"},
 		&cli.StringFlag{Name: "table-structure-format", Usage: "if set, the table structure will be output to the stdout with the specified format, support: json, yaml, table, markdown, html, csv, xml"},
 		&cli.BoolFlag{Name: "slient", Value: false, Usage: "do not print warning log or progressbar"},
-	}...)
+		&cli.StringFlag{Name: "rules", Usage: "validation rules file in yaml format, if set, input files will be validated before importing, and nothing will be imported when errors found, can not be used when reading from STDIN"},
+	}...), BuildReaderFlags()...)
 }
 
 // ImportCommand import command
@@ -102,7 +111,35 @@ func ImportCommand(c *cli.Context) error {
 	}
 
 	opt := resolveImportOption(c)
-	globalOpt := resolveGlobalOption(c)
+	globalOpt, err := resolveGlobalOption(c)
+	if err != nil {
+		return err
+	}
+
+	// 读取单元格的类型，日期和数字按照实际的值导入，而不是单元格中显示的文本
+	walker := reader.MergeTypedWalkers(array.Map(
+		opt.InputFiles,
+		func(f string, _ int) reader.TypedFileWalker {
+			readerOpt := opt.ReaderOption
+			readerOpt.CSVSepertor, readerOpt.Beta, readerOpt.Sheet = opt.CSVSepertor, opt.Beta, opt.Sheet
+
+			return reader.CreateTypedFileWalker(f, readerOpt)
+		})...,
+	)
+	if walker == nil {
+		return fmt.Errorf("no file avaiable: only support csv, xlsx, xls or ods files")
+	}
+
+	// 校验和导入需要分别读取一次输入文件，而 STDIN 只能读取一次
+	if opt.Rules != "" && array.In(reader.Stdin, opt.InputFiles) {
+		return fmt.Errorf("--rules can not be used when reading from STDIN (-), because STDIN can only be read once")
+	}
+
+	if opt.Rules != "" {
+		if err := validateBeforeImport(opt.Rules, walker.Texts()); err != nil {
+			return err
+		}
+	}
 
 	db, err := sql.Open("mysql", globalOpt.DSN())
 	if err != nil {
@@ -119,16 +156,6 @@ func ImportCommand(c *cli.Context) error {
 		}
 	}
 
-	walker := reader.MergeWalkers(array.Map(
-		opt.InputFiles,
-		func(f string, _ int) reader.FileWalker {
-			return reader.CreateFileWalker(f, opt.CSVSepertor, false, opt.Beta)
-		})...,
-	)
-	if walker == nil {
-		return fmt.Errorf("no file avaiable: only support csv or xlsx files")
-	}
-
 	if opt.UsingTx || opt.DryRun {
 		log.Debugf("import data using transaction")
 
@@ -180,6 +207,37 @@ func ImportCommand(c *cli.Context) error {
 	return nil
 }
 
+// validateBeforeImport validate input files using rules, the violations will be printed to STDOUT
+func validateBeforeImport(rulesFile string, walker reader.FileWalker) error {
+	v, err := validator.Load(rulesFile)
+	if err != nil {
+		return err
+	}
+
+	violations, err := validateWalker(v, walker)
+	if err != nil {
+		return fmt.Errorf("validate input files failed: %w", err)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	res, err := renderViolations("table", false, violations, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nVIOLATIONS:\n\n%s\n", res)
+
+	if hasValidationErrors(violations) {
+		// 与 validate 命令保持一致，存在错误时以非零状态码退出
+		return cli.Exit("😨 validate input files failed, nothing imported", 1)
+	}
+
+	return nil
+}
+
 // printTableStructure print table structure
 func printTableStructure(db *sql.DB, targetDB string, targetTable string, format string, allowFields []DatabaseField) {
 	rows, err := query.QueryDB(
@@ -203,7 +261,7 @@ func printTableStructure(db *sql.DB, targetDB string, targetTable string, format
 		return row
 	})
 
-	buf, err := render.Render(format, false, rows.Columns, rows.DataSets, "", "")
+	buf, err := render.Render(format, false, rows.Columns, rows.DataSets, "", "", render.CSVOptions{})
 	if err != nil {
 		log.Errorf("render table structure failed: %v", err)
 		return
@@ -260,7 +318,7 @@ type ImportResult struct {
 }
 
 // importData import excel file
-func importData(opt ImportOption, tx Tx, fileWalker reader.FileWalker) (res ImportResult, allowFields []DatabaseField, err error) {
+func importData(opt ImportOption, tx Tx, fileWalker reader.TypedFileWalker) (res ImportResult, allowFields []DatabaseField, err error) {
 	defer func() {
 		if err1 := recover(); err1 != nil {
 			err = fmt.Errorf("panic: %v", err1)
@@ -321,7 +379,7 @@ func importData(opt ImportOption, tx Tx, fileWalker reader.FileWalker) (res Impo
 			sqlTemplate, fields = buildSQLTemplate(opt.Table, fieldIndexs)
 			return nil
 		},
-		func(filepath string, id string, row []string) error {
+		func(filepath string, id string, row []reader.Cell) error {
 			defer func() {
 				bar.Add(1)
 			}()
@@ -329,12 +387,7 @@ func importData(opt ImportOption, tx Tx, fileWalker reader.FileWalker) (res Impo
 			var args []interface{}
 			for _, fieldName := range fields {
 				if fieldIndexs[fieldName] < len(row) {
-					arg := strings.TrimSpace(row[fieldIndexs[fieldName]])
-					if arg != "" {
-						args = append(args, arg)
-					} else {
-						args = append(args, nil)
-					}
+					args = append(args, importCellValue(row[fieldIndexs[fieldName]]))
 				} else {
 					args = append(args, nil)
 				}
@@ -355,7 +408,9 @@ func importData(opt ImportOption, tx Tx, fileWalker reader.FileWalker) (res Impo
 					"line": id,
 					"file": filepath,
 				}).Errorf("exec sql failed: %v", err)
-				return err
+
+				// 单行导入失败时记录失败数量并继续导入后续的行
+				return nil
 			}
 
 			res.SuccessCount++
@@ -373,6 +428,22 @@ func importData(opt ImportOption, tx Tx, fileWalker reader.FileWalker) (res Impo
 	return res, allowFields, nil
 }
 
+// importCellValue return the value of cell bound to the insert statement: numbers and booleans are typed values, dates are
+// in the form of 2006-01-02 15:04:05, which can be written to DATETIME columns regardless of the number format of cell and
+// the time zone of connection, and other cells are the trimmed text, empty text is NULL
+func importCellValue(cell reader.Cell) interface{} {
+	switch cell.Type {
+	case reader.CellTypeNumber, reader.CellTypeBool, reader.CellTypeDate:
+		return typedCellValue(cell)
+	}
+
+	if val := strings.TrimSpace(cell.Value); val != "" {
+		return val
+	}
+
+	return nil
+}
+
 func resolveAllowFields(fields []DatabaseField, includes []string, excludes []string) []DatabaseField {
 	allowFields := make([]DatabaseField, 0)
 	for _, f := range fields {
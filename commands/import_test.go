@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"database/sql"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/xuri/excelize/v2"
+)
+
+// importTestTx record the rows inserted by importData
+type importTestTx struct {
+	rows []map[string]interface{}
+}
+
+var importTestInsertRegexp = regexp.MustCompile(`^INSERT INTO \w+ \(([^)]+)\)`)
+
+func (tx *importTestTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if matches := importTestInsertRegexp.FindStringSubmatch(query); matches != nil {
+		row := make(map[string]interface{})
+		for i, field := range strings.Split(matches[1], ", ") {
+			row[field] = args[i]
+		}
+
+		tx.rows = append(tx.rows, row)
+	}
+
+	return nil, nil
+}
+
+func (tx *importTestTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+
+func TestImportData(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "users.xlsx")
+
+	f := excelize.NewFile()
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"name", "amount", "created_at", "remark"}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A2", &[]interface{}{" Tom ", 1234.5, time.Date(2023, 7, 15, 10, 30, 0, 0, time.UTC), ""}))
+
+	// 单元格中显示的文本与实际的值不同
+	style, err := f.NewStyle(&excelize.Style{NumFmt: 4})
+	assert.NoError(t, err)
+	assert.NoError(t, f.SetCellStyle("Sheet1", "B2", "B2", style))
+	assert.NoError(t, f.SaveAs(filename))
+
+	tx := &importTestTx{}
+	opt := ImportOption{Table: "users", Slient: true}
+	res, fields, err := importData(opt, tx, reader.CreateTypedFileWalker(filename, reader.Options{}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.SuccessCount)
+	assert.Equal(t, 4, len(fields))
+	assert.Equal(t, []map[string]interface{}{
+		{"name": "Tom", "amount": 1234.5, "created_at": "2023-07-15 10:30:00", "remark": nil},
+	}, tx.rows)
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/mylxsw/heimdall/mask"
+	"github.com/urfave/cli/v2"
+)
+
+// BuildMaskFlags build flags for masking sensitive columns
+func BuildMaskFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{Name: "mask", Usage: "mask sensitive column, eg: phone:partial(3,4), supported rules: hash, partial(KEEP_FIRST,KEEP_LAST), null, redact, fake:name, fake:phone, fake:email, column name supports glob pattern such as *phone*, this flag can be specified multiple times"},
+		&cli.StringFlag{Name: "mask-policy", Value: "", Usage: "masking policy file in yaml format, the rules specified by --mask have higher priority"},
+		&cli.StringFlag{Name: "mask-salt", Value: "", EnvVars: []string{"HEIMDALL_MASK_SALT"}, Usage: "salt for hash rule, required when any hash rule is used, otherwise the hashed values can be reversed by brute force"},
+		&cli.Int64Flag{Name: "mask-seed", Value: 0, Usage: "seed for fake rules, the same seed and value always generate the same fake value"},
+	}
+}
+
+// resolveMasker create a masker from mask flags, it returns nil if no mask rule specified
+func resolveMasker(c *cli.Context) (*mask.Masker, error) {
+	// 命令行解析时会按照逗号拆分 --mask 的值，这里需要将 partial(3,4) 这类被拆开的规则重新合并
+	specs := make([]string, 0)
+	for _, spec := range c.StringSlice("mask") {
+		if len(specs) > 0 && !strings.Contains(spec, ":") {
+			specs[len(specs)-1] += "," + spec
+			continue
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return mask.New(c.String("mask-policy"), specs, c.String("mask-salt"), c.Int64("mask-seed"))
+}
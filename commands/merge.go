@@ -0,0 +1,551 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mylxsw/asteria/event"
+	"github.com/mylxsw/asteria/filter"
+	"github.com/mylxsw/asteria/level"
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/urfave/cli/v2"
+	"github.com/xuri/excelize/v2"
+)
+
+// Column alignment modes of merge
+const (
+	mergeModeUnion        = "union"
+	mergeModeIntersection = "intersection"
+)
+
+// Columns added by --add-source
+const (
+	mergeSourceFileField  = "_source_file"
+	mergeSourceSheetField = "_source_sheet"
+)
+
+type MergeOption struct {
+	InputFiles []string
+	Output     string
+	Slient     bool
+	Debug      bool
+
+	// Mode is how to align the columns of inputs, union or intersection
+	Mode string
+	// AddSource add the _source_file and _source_sheet columns
+	AddSource bool
+	// AsSheets copy each input into its own sheet instead of merging the rows
+	AsSheets bool
+	// Sheet is the sheet name or index of excel files to merge, the first sheet is merged if empty
+	Sheet string
+	// AllSheets merge all sheets of excel files
+	AllSheets bool
+
+	ReaderOption   reader.Options
+	OutputEncoding string
+	CSVOutput      render.CSVOptions
+}
+
+func BuildMergeFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringSliceFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, a glob pattern or a directory, this flag can be specified multiple times", Required: true},
+		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "the merged file path, support xlsx and csv format, the format is detected by file extension", Required: true},
+		&cli.StringFlag{Name: "mode", Value: mergeModeUnion, Usage: "how to align the columns of inputs by header name, union: all columns of inputs, intersection: only the columns in every input"},
+		&cli.BoolFlag{Name: "add-source", Usage: "add _source_file and _source_sheet columns which record the file and the sheet of each row"},
+		&cli.BoolFlag{Name: "as-sheets", Usage: "copy each input into its own sheet of the output workbook instead of merging the rows, the output must be xlsx"},
+		&cli.StringFlag{Name: "sheet", Usage: "the sheet name or index (start from 1) of excel files to merge, default is the first sheet"},
+		&cli.BoolFlag{Name: "all-sheets", Usage: "merge all sheets of excel files"},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
+		&cli.BoolFlag{Name: "slient", Value: false, Usage: "do not print warning log"},
+		&cli.BoolFlag{Name: "debug", Aliases: []string{"D"}, Value: false, Usage: "Debug mode"},
+	}, append(BuildReaderFlags(), BuildCSVOutputFlags()...)...)
+}
+
+func resolveMergeOption(c *cli.Context) MergeOption {
+	readerOpt := resolveReaderOption(c)
+	readerOpt.CSVSepertor = parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor"))
+
+	return MergeOption{
+		InputFiles: array.Filter(c.StringSlice("file"), func(f string, _ int) bool { return f != "" }),
+		Output:     c.String("output"),
+		Slient:     c.Bool("slient"),
+		Debug:      c.Bool("debug"),
+		Mode:       strings.ToLower(c.String("mode")),
+		AddSource:  c.Bool("add-source"),
+		AsSheets:   c.Bool("as-sheets"),
+		Sheet:      c.String("sheet"),
+		AllSheets:  c.Bool("all-sheets"),
+
+		ReaderOption:   readerOpt,
+		OutputEncoding: c.String("output-encoding"),
+		CSVOutput:      resolveCSVOutputOption(c),
+	}
+}
+
+// MergeCommand merge multiple excel or csv files into one file
+func MergeCommand(c *cli.Context) error {
+	opt := resolveMergeOption(c)
+	if !opt.Debug {
+		log.All().LogLevel(level.Info)
+	}
+
+	if opt.Slient {
+		log.AddGlobalFilter(func(filter filter.Filter) filter.Filter {
+			return func(evt event.Event) {
+				if evt.Level == level.Warning {
+					return
+				}
+
+				filter(evt)
+			}
+		})
+	}
+
+	if opt.AsSheets && (c.IsSet("mode") || opt.AddSource) {
+		log.Warningf("--mode and --add-source do not work with --as-sheets, ignored")
+	}
+
+	if opt.Sheet != "" && opt.AllSheets {
+		log.Warningf("--sheet and --all-sheets are both set, only the sheet %s will be merged", opt.Sheet)
+	}
+
+	return MergeFiles(opt)
+}
+
+// mergeInput is a file or a sheet of excel file to merge
+type mergeInput struct {
+	File    string
+	Sheet   string
+	Headers []string
+	// key is the key of rows in spill
+	key string
+}
+
+// MergeFiles 将多个 Excel 或者 csv 文件合并为一个文件，列按照表头名称对齐（opt.Mode 为 union 时保留所有列，为 intersection
+// 时只保留每个文件中都存在的列），opt.AsSheets 为 true 时，每个文件复制为输出文件中的一个 Sheet
+//
+// 所有文件读取完成后才能确定输出的列，因此读取时每个文件的数据行先写入临时文件
+func MergeFiles(opt MergeOption) error {
+	if len(opt.InputFiles) == 0 {
+		return fmt.Errorf("input file (--file) is required")
+	}
+
+	if opt.Output == "" {
+		return fmt.Errorf("output file (--output) is required")
+	}
+
+	if opt.Mode != mergeModeUnion && opt.Mode != mergeModeIntersection {
+		return fmt.Errorf("invalid mode %s, only support %s, %s", opt.Mode, mergeModeUnion, mergeModeIntersection)
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(opt.Output)), ".")
+	if format != splitFormatXLSX && format != splitFormatCSV {
+		return fmt.Errorf("unsupported output format %s, only support xlsx, csv", filepath.Ext(opt.Output))
+	}
+
+	if opt.AsSheets && format != splitFormatXLSX {
+		return fmt.Errorf("--as-sheets only works with xlsx output")
+	}
+
+	if opt.OutputEncoding != "" && format != splitFormatCSV {
+		log.Warningf("--output-encoding only works with csv format, ignored")
+		opt.OutputEncoding = ""
+	}
+
+	// 读取所有 Sheet 时回调中的文件名为 FILE#SHEET，这样可以得到每一行所在的 Sheet，只读取一个 Sheet 时使用序号 1 表示第一个 Sheet
+	readerOpt := opt.ReaderOption
+	readerOpt.AllSheets, readerOpt.Sheet = true, opt.Sheet
+	if readerOpt.Sheet == "" && !opt.AllSheets {
+		readerOpt.Sheet = "1"
+	}
+
+	// 每个文件单独读取，这样回调中可以直接得到每一行所在的文件，Sheet 名称为 FILE#SHEET 去掉文件名前缀后的部分
+	output, _ := filepath.Abs(opt.Output)
+	files := make([]string, 0)
+	for _, f := range opt.InputFiles {
+		matches, err := reader.ExpandPath(f, readerOpt)
+		if err != nil {
+			return err
+		}
+
+		for _, match := range matches {
+			// 输出文件可能与输入文件的表达式匹配，例如再次执行同样的命令时
+			if abs, _ := filepath.Abs(match); abs == output {
+				log.Warningf("file %s is the output file, skipped", match)
+				continue
+			}
+
+			files = append(files, match)
+		}
+	}
+
+	// xlsx 文件中保留数字和日期的类型，csv 文件中只需要文本
+	walkers := make(map[string]reader.TypedFileWalker)
+	for _, file := range files {
+		if walker := createConvertWalker(file, readerOpt, format == splitFormatXLSX); walker != nil {
+			walkers[file] = walker
+		}
+	}
+
+	if len(walkers) == 0 {
+		if len(files) == 0 {
+			return fmt.Errorf("no file matched %s", strings.Join(opt.InputFiles, ", "))
+		}
+
+		return fmt.Errorf("no file avaiable: only support csv, xlsx, xls or ods files")
+	}
+
+	prg := NewProgressbar(!opt.Slient, "reading files ...")
+	defer prg.Close()
+
+	logger := NewLogger()
+	defer logger.Flush()
+
+	spill, err := newSplitSpill()
+	if err != nil {
+		return err
+	}
+	defer spill.Close()
+
+	inputs := make([]*mergeInput, 0)
+	for _, file := range files {
+		walker, ok := walkers[file]
+		if !ok {
+			continue
+		}
+
+		var current *mergeInput
+		var spillErr error
+		if err := walker(
+			func(source string, headers []string) error {
+				sheet := ""
+				if source != file {
+					sheet = strings.TrimPrefix(source, reader.SheetSource(file, ""))
+				}
+
+				current = &mergeInput{File: file, Sheet: sheet, Headers: headers, key: fmt.Sprintf("%d", len(inputs))}
+				inputs = append(inputs, current)
+				return nil
+			},
+			func(source string, id string, data []reader.Cell) error {
+				prg.Add(1)
+				spillErr = spill.Add(current.key, splitRecord{Values: array.Map(data, func(cell reader.Cell, _ int) interface{} {
+					if format == splitFormatCSV {
+						return cell.Value
+					}
+
+					return cell.Interface()
+				})})
+
+				return spillErr
+			},
+		); err != nil {
+			return err
+		}
+
+		// 临时文件写入失败时，即使读取文件的过程没有返回错误，也不能继续合并
+		if spillErr != nil {
+			return fmt.Errorf("write rows of %s to temporary file failed: %w", file, spillErr)
+		}
+	}
+
+	if len(inputs) == 0 {
+		return fmt.Errorf("no file matched %s", strings.Join(opt.InputFiles, ", "))
+	}
+
+	writer, err := newMergeWriter(opt, format)
+	if err != nil {
+		return err
+	}
+
+	prg.Reset(len(inputs), "writing file ...")
+	if err := writeMergedInputs(opt, writer, spill, inputs, prg); err != nil {
+		_ = writer.Close()
+		return err
+	}
+
+	if err := writer.Save(); err != nil {
+		return err
+	}
+
+	logger.Add(fmt.Sprintf("merge %d inputs into %s", len(inputs), opt.Output))
+	return nil
+}
+
+// writeMergedInputs write the rows of inputs to writer, each input is written to its own sheet if opt.AsSheets
+func writeMergedInputs(opt MergeOption, writer *mergeWriter, spill *splitSpill, inputs []*mergeInput, prg *Progressbar) error {
+	if opt.AsSheets {
+		used := make(map[string]bool)
+		for _, in := range inputs {
+			prg.Add(1)
+			name := splitFilePrefix(filepath.Base(in.File))
+			if opt.AllSheets && in.Sheet != "" {
+				name += "-" + in.Sheet
+			}
+
+			if err := writer.NewSheet(mergeSheetName(name, used)); err != nil {
+				return err
+			}
+
+			if err := writer.Write(array.Map(in.Headers, func(header string, _ int) interface{} { return header })); err != nil {
+				return err
+			}
+
+			if err := spill.Rows(in.key, func(row splitRecord) error { return writer.Write(row.Values) }); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	columns := mergeColumns(opt.Mode, array.Map(inputs, func(in *mergeInput, _ int) []string { return in.Headers }))
+	if len(columns) == 0 {
+		return fmt.Errorf("no column is in all inputs")
+	}
+
+	offset := 0
+	headers := make([]interface{}, 0, len(columns)+2)
+	if opt.AddSource {
+		offset = 2
+		headers = append(headers, mergeSourceFileField, mergeSourceSheetField)
+	}
+
+	for _, col := range columns {
+		headers = append(headers, mergeColumnName(col))
+	}
+
+	if err := writer.NewSheet("Sheet1"); err != nil {
+		return err
+	}
+
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+
+	positions := make(map[string]int)
+	for i, col := range columns {
+		positions[col] = i + offset
+	}
+
+	for _, in := range inputs {
+		prg.Add(1)
+		mapping := array.Map(mergeColumnKeys(in.Headers), func(key string, _ int) int {
+			if pos, ok := positions[key]; ok {
+				return pos
+			}
+
+			return -1
+		})
+
+		if err := spill.Rows(in.key, func(row splitRecord) error {
+			values := make([]interface{}, len(headers))
+			if opt.AddSource {
+				values[0], values[1] = in.File, in.Sheet
+			}
+
+			for i, val := range row.Values {
+				if i < len(mapping) && mapping[i] >= 0 {
+					values[mapping[i]] = val
+				}
+			}
+
+			return writer.Write(values)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeKeySepertor separate the header name and the occurrence in the column keys
+const mergeKeySepertor = "\x00"
+
+// mergeColumnKeys return the keys of columns for aligning, the key is the header name, and the n-th (n > 1) column with
+// the same name in a file is suffixed with the occurrence, so that each column is matched only once
+func mergeColumnKeys(headers []string) []string {
+	counts := make(map[string]int)
+	return array.Map(headers, func(header string, _ int) string {
+		name := strings.TrimSpace(header)
+		counts[name]++
+		if counts[name] > 1 {
+			return fmt.Sprintf("%s%s%d", name, mergeKeySepertor, counts[name])
+		}
+
+		return name
+	})
+}
+
+// mergeColumnName return the header name of column key
+func mergeColumnName(key string) string {
+	return strings.SplitN(key, mergeKeySepertor, 2)[0]
+}
+
+// mergeColumns return the keys of output columns, union mode return all columns in the order of first appearance, and
+// intersection mode return the columns in every input in the order of the first input
+func mergeColumns(mode string, headers [][]string) []string {
+	counts := make(map[string]int)
+	columns := make([]string, 0)
+	for _, h := range headers {
+		for _, key := range mergeColumnKeys(h) {
+			if counts[key] == 0 {
+				columns = append(columns, key)
+			}
+
+			counts[key]++
+		}
+	}
+
+	if mode == mergeModeIntersection {
+		return array.Filter(columns, func(key string, _ int) bool { return counts[key] == len(headers) })
+	}
+
+	return columns
+}
+
+// mergeMaxSheetName is the maximum length of sheet names in excel
+const mergeMaxSheetName = 31
+
+// mergeSheetName return a valid and unique sheet name, the invalid characters are replaced with _, and the duplicated
+// names (case-insensitive) are suffixed with (2), (3) ...
+func mergeSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+
+		return r
+	}, name)
+
+	name = strings.Trim(strings.TrimSpace(name), "'")
+	if name == "" {
+		name = "Sheet"
+	}
+
+	truncate := func(val string, max int) string {
+		for utf8.RuneCountInString(val) > max {
+			_, size := utf8.DecodeLastRuneInString(val)
+			val = val[:len(val)-size]
+		}
+
+		return val
+	}
+
+	result := truncate(name, mergeMaxSheetName)
+	for i := 2; used[strings.ToLower(result)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		result = truncate(name, mergeMaxSheetName-len(suffix)) + suffix
+	}
+
+	used[strings.ToLower(result)] = true
+	return result
+}
+
+// mergeWriter write the merged rows to a xlsx file with one or more sheets, or to a csv file
+type mergeWriter struct {
+	filename string
+	file     *excelize.File
+	writer   *excelize.StreamWriter
+	csv      *csvPartWriter
+	rowNum   int
+}
+
+func newMergeWriter(opt MergeOption, format string) (*mergeWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(opt.Output), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	if format == splitFormatCSV {
+		w, err := newCSVPartWriter(opt.Output, splitOutput{Format: format, CSV: opt.CSVOutput, Encoding: opt.OutputEncoding})
+		if err != nil {
+			return nil, err
+		}
+
+		return &mergeWriter{filename: opt.Output, csv: w}, nil
+	}
+
+	return &mergeWriter{filename: opt.Output, file: excelize.NewFile()}, nil
+}
+
+// NewSheet start writing a new sheet, the rows of previous sheet are flushed, csv files have only one sheet
+func (w *mergeWriter) NewSheet(name string) error {
+	if w.csv != nil {
+		return nil
+	}
+
+	if w.writer != nil {
+		if err := w.writer.Flush(); err != nil {
+			return err
+		}
+
+		// NewSheet 在 Sheet 已经存在时返回已有的 Sheet，会导致多个输入写入同一个 Sheet
+		if w.file.GetSheetIndex(name) != -1 {
+			return fmt.Errorf("sheet %s already exists", name)
+		}
+
+		if index := w.file.NewSheet(name); index < 0 {
+			return fmt.Errorf("create sheet %s failed", name)
+		}
+	} else {
+		// 第一个 Sheet 使用新建文件中默认的 Sheet
+		w.file.SetSheetName(w.file.GetSheetName(0), name)
+	}
+
+	sw, err := w.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+
+	w.writer, w.rowNum = sw, 0
+	return nil
+}
+
+func (w *mergeWriter) Write(values []interface{}) error {
+	if w.csv != nil {
+		return w.csv.Write(splitRecord{Values: values})
+	}
+
+	w.rowNum++
+	if w.rowNum > excelize.TotalRows {
+		return fmt.Errorf("the rows exceed the maximum rows (%d) of xlsx sheet, please use csv output", excelize.TotalRows)
+	}
+
+	axis, err := excelize.CoordinatesToCellName(1, w.rowNum)
+	if err != nil {
+		return err
+	}
+
+	return w.writer.SetRow(axis, values)
+}
+
+// Save flush the rows and save the file
+func (w *mergeWriter) Save() error {
+	if w.csv != nil {
+		return w.csv.Save()
+	}
+
+	defer w.file.Close()
+	if w.writer != nil {
+		if err := w.writer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return w.file.SaveAs(w.filename)
+}
+
+// Close discard the file without saving
+func (w *mergeWriter) Close() error {
+	if w.csv != nil {
+		return w.csv.Close()
+	}
+
+	return w.file.Close()
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/xuri/excelize/v2"
+)
+
+func createMergeTestFiles(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "regions")
+	assert.NoError(t, os.MkdirAll(dir, os.ModePerm))
+
+	f := excelize.NewFile()
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"name", "amount"}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A2", &[]interface{}{"Tom", 12.5}))
+	f.NewSheet("Extra")
+	assert.NoError(t, f.SetSheetRow("Extra", "A1", &[]interface{}{"name", "note"}))
+	assert.NoError(t, f.SetSheetRow("Extra", "A2", &[]interface{}{"Bob", "vip"}))
+	assert.NoError(t, f.SaveAs(filepath.Join(dir, "east.xlsx")))
+
+	g := excelize.NewFile()
+	assert.NoError(t, g.SetSheetRow("Sheet1", "A1", &[]interface{}{"amount", "name", "region"}))
+	assert.NoError(t, g.SetSheetRow("Sheet1", "A2", &[]interface{}{30, "Jerry", "west"}))
+	assert.NoError(t, g.SaveAs(filepath.Join(dir, "west.xlsx")))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "north.csv"), []byte("name,region\nSpike,north\n"), 0644))
+
+	return dir
+}
+
+func TestMergeFiles(t *testing.T) {
+	dir := createMergeTestFiles(t)
+	output := filepath.Join(filepath.Dir(dir), "all.xlsx")
+
+	opt := MergeOption{InputFiles: []string{filepath.Join(dir, "*")}, Output: output, Slient: true, Mode: mergeModeUnion, AddSource: true}
+	assert.NoError(t, MergeFiles(opt))
+
+	f, err := excelize.OpenFile(output)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	rows, err := f.GetRows("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"_source_file", "_source_sheet", "name", "amount", "region"},
+		{filepath.Join(dir, "east.xlsx"), "Sheet1", "Tom", "12.5"},
+		{filepath.Join(dir, "north.csv"), "", "Spike", "", "north"},
+		{filepath.Join(dir, "west.xlsx"), "Sheet1", "Jerry", "30", "west"},
+	}, rows)
+
+	// 只保留每个输入中都存在的列，输出文件与输入文件的表达式匹配时被忽略
+	opt.Output = filepath.Join(dir, "all.csv")
+	opt.Mode, opt.AddSource, opt.AllSheets = mergeModeIntersection, false, true
+	opt.CSVOutput = render.CSVOptions{Comma: ',', NoBOM: true}
+	assert.NoError(t, MergeFiles(opt))
+	assert.NoError(t, MergeFiles(opt))
+
+	data, err := os.ReadFile(opt.Output)
+	assert.NoError(t, err)
+	assert.Equal(t, "name\nTom\nBob\nSpike\nJerry\n", string(data))
+}
+
+func TestMergeFilesAsSheets(t *testing.T) {
+	dir := createMergeTestFiles(t)
+	output := filepath.Join(filepath.Dir(dir), "sheets.xlsx")
+
+	assert.NoError(t, MergeFiles(MergeOption{InputFiles: []string{filepath.Join(dir, "*")}, Output: output, Slient: true, Mode: mergeModeUnion, AsSheets: true, AllSheets: true}))
+
+	f, err := excelize.OpenFile(output)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	assert.Equal(t, []string{"east-Sheet1", "east-Extra", "north", "west-Sheet1"}, f.GetSheetList())
+
+	rows, err := f.GetRows("east-Extra")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"name", "note"}, {"Bob", "vip"}}, rows)
+
+	assert.True(t, MergeFiles(MergeOption{InputFiles: []string{filepath.Join(dir, "*")}, Output: filepath.Join(dir, "all.csv"), Mode: mergeModeUnion, AsSheets: true}) != nil)
+}
+
+func TestMergeFilesSheetSource(t *testing.T) {
+	dir := t.TempDir()
+
+	// 文件名中包含 #，并且 # 之前的部分也是一个存在的文件
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("name\nTom\n"), 0644))
+
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", "x#y")
+	assert.NoError(t, f.SetSheetRow("x#y", "A1", &[]interface{}{"name"}))
+	assert.NoError(t, f.SetSheetRow("x#y", "A2", &[]interface{}{"Jerry"}))
+	assert.NoError(t, f.SaveAs(filepath.Join(dir, "a#b.xlsx")))
+
+	output := filepath.Join(dir, "all.csv")
+	opt := MergeOption{InputFiles: []string{filepath.Join(dir, "a#b.xlsx")}, Output: output, Slient: true, Mode: mergeModeUnion, AddSource: true, AllSheets: true}
+	opt.CSVOutput = render.CSVOptions{Comma: ',', NoBOM: true}
+	assert.NoError(t, MergeFiles(opt))
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "_source_file,_source_sheet,name\n"+filepath.Join(dir, "a#b.xlsx")+",x#y,Jerry\n", string(data))
+}
+
+func TestMergeWriterNewSheet(t *testing.T) {
+	w, err := newMergeWriter(MergeOption{Output: filepath.Join(t.TempDir(), "sheets.xlsx")}, splitFormatXLSX)
+	assert.NoError(t, err)
+	defer w.Close()
+
+	assert.NoError(t, w.NewSheet("a"))
+	assert.NoError(t, w.NewSheet("b"))
+	assert.True(t, w.NewSheet("a") != nil)
+}
+
+func TestMergeColumns(t *testing.T) {
+	headers := [][]string{{"id", "name", "name"}, {"name", " id ", "age"}}
+	assert.Equal(t, []string{"id", "name", "name\x002", "age"}, mergeColumns(mergeModeUnion, headers))
+	assert.Equal(t, []string{"id", "name"}, mergeColumns(mergeModeIntersection, headers))
+	assert.Equal(t, "name", mergeColumnName("name\x002"))
+}
+
+func TestMergeSheetName(t *testing.T) {
+	used := make(map[string]bool)
+	assert.Equal(t, "a_b_c", mergeSheetName("a/b:c", used))
+	assert.Equal(t, "A_B_C (2)", mergeSheetName("A/B:C", used))
+	assert.Equal(t, "Sheet", mergeSheetName("''", used))
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyz01234", mergeSheetName("abcdefghijklmnopqrstuvwxyz0123456789", used))
+	assert.Equal(t, "abcdefghijklmnopqrstuvwxyz0 (2)", mergeSheetName("abcdefghijklmnopqrstuvwxyz0123456789", used))
+}
@@ -0,0 +1,288 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mylxsw/asteria/event"
+	"github.com/mylxsw/asteria/filter"
+	"github.com/mylxsw/asteria/level"
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/go-utils/must"
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/extracter"
+	"github.com/mylxsw/heimdall/profiler"
+	"github.com/mylxsw/heimdall/query"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/urfave/cli/v2"
+)
+
+type ProfileOption struct {
+	InputFiles    []string
+	SQL           string
+	CSVSepertor   rune
+	TopN          int
+	DistinctLimit int
+	Slient        bool
+	Debug         bool
+	Beta          bool
+
+	Format                  string
+	Output                  string
+	NoHeader                bool
+	TargetTableForSQLFormat string
+}
+
+func BuildProfileFlags() []cli.Flag {
+	return append(BuildGlobalFlags(), []cli.Flag{
+		&cli.StringSliceFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, this flag can be specified multiple times for profiling multiple files"},
+		&cli.StringFlag{Name: "sql", Aliases: []string{"s"}, Usage: "profile the result of the SQL query, the connection is specified by global flags"},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
+		&cli.IntFlag{Name: "top", Value: 5, Usage: "the number of most frequent values to show for each column"},
+		&cli.IntFlag{Name: "distinct-limit", Value: 100000, Usage: "the maximum number of distinct values counted exactly for each column, an approximate algorithm is used when exceeded"},
+		&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "table", Usage: "output format of the profile report, support " + strings.Join(query.SupportedStandardFormats, ", ")},
+		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "", Usage: "write output to a file, default output directly to STDOUT"},
+		&cli.BoolFlag{Name: "no-header", Aliases: []string{"n"}, Value: false, Usage: "do not write table header"},
+		&cli.StringFlag{Name: "table", Value: "", Usage: "when the format is sql, specify the table name"},
+		&cli.BoolFlag{Name: "slient", Value: false, Usage: "do not print warning log"},
+	}...)
+}
+
+func resolveProfileOption(c *cli.Context) ProfileOption {
+	return ProfileOption{
+		InputFiles:              array.Filter(c.StringSlice("file"), func(f string, _ int) bool { return f != "" }),
+		SQL:                     c.String("sql"),
+		CSVSepertor:             parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor")),
+		TopN:                    c.Int("top"),
+		DistinctLimit:           c.Int("distinct-limit"),
+		Slient:                  c.Bool("slient"),
+		Debug:                   c.Bool("debug"),
+		Beta:                    c.Bool("beta"),
+		Format:                  c.String("format"),
+		Output:                  c.String("output"),
+		NoHeader:                c.Bool("no-header"),
+		TargetTableForSQLFormat: c.String("table"),
+	}
+}
+
+// ProfileCommand compute statistics for each column of files or query result
+func ProfileCommand(c *cli.Context) error {
+	opt := resolveProfileOption(c)
+	if !opt.Debug {
+		log.All().LogLevel(level.Info)
+	}
+
+	if opt.Slient {
+		log.AddGlobalFilter(func(filter filter.Filter) filter.Filter {
+			return func(evt event.Event) {
+				if evt.Level == level.Warning {
+					return
+				}
+
+				filter(evt)
+			}
+		})
+	}
+
+	if len(opt.InputFiles) == 0 && opt.SQL == "" {
+		return fmt.Errorf("--file or --sql is required")
+	}
+
+	if opt.Format == "sql" && opt.TargetTableForSQLFormat == "" {
+		return fmt.Errorf("when the format is sql, the table name (--table) is required")
+	}
+
+	if opt.TopN < 0 || opt.DistinctLimit <= 0 {
+		return fmt.Errorf("--top must not be negative and --distinct-limit must be positive")
+	}
+
+	results := make([]profileSource, 0)
+	for _, f := range opt.InputFiles {
+		res, err := profileFile(opt, f)
+		if err != nil {
+			return fmt.Errorf("profile file %s failed: %w", f, err)
+		}
+
+		results = append(results, res...)
+	}
+
+	if opt.SQL != "" {
+		res, err := profileSQL(c, opt)
+		if err != nil {
+			return fmt.Errorf("profile query failed: %w", err)
+		}
+
+		results = append(results, res)
+	}
+
+	cols, kvs := buildProfileReport(results)
+	res, err := render.Render(opt.Format, opt.NoHeader, cols, kvs, "", opt.TargetTableForSQLFormat, render.CSVOptions{})
+	if err != nil {
+		return err
+	}
+
+	w := ternary.IfElseLazy(
+		opt.Output != "",
+		func() io.WriteCloser { return must.Must(os.Create(opt.Output)) },
+		func() io.WriteCloser { return os.Stdout },
+	)
+	defer w.Close()
+
+	_, err = w.Write(res.Bytes())
+	return err
+}
+
+// profileSource is the column profilers of an input source
+type profileSource struct {
+	Name    string
+	Columns []*profiler.Column
+}
+
+func (src profileSource) add(data []string) {
+	for i, col := range src.Columns {
+		if i < len(data) {
+			col.Add(&data[i])
+		} else {
+			col.Add(nil)
+		}
+	}
+}
+
+// profileFile profile the file row by row, the memory usage is not related to the file size
+func profileFile(opt ProfileOption, filename string) ([]profileSource, error) {
+	walker := reader.CreateFileWalker(filename, opt.CSVSepertor, false, opt.Beta)
+	if walker == nil {
+		return nil, fmt.Errorf("unsupported file type, only support csv, xlsx, xls and ods")
+	}
+
+	sources := make([]profileSource, 0)
+
+	err := walker(
+		func(filepath string, headers []string) error {
+			sources = append(sources, profileSource{
+				Name: filepath,
+				Columns: array.Map(headers, func(header string, i int) *profiler.Column {
+					return profiler.NewColumn(ternary.If(header == "", fmt.Sprintf("column_%d", i), header), opt.TopN, opt.DistinctLimit)
+				}),
+			})
+			return nil
+		},
+		func(filepath string, id string, data []string) error {
+			if len(sources) == 0 {
+				return fmt.Errorf("no header found")
+			}
+
+			sources[len(sources)-1].add(data)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// profileSQL profile the query result in streaming mode, so that large result set can be profiled with limited memory
+func profileSQL(c *cli.Context, opt ProfileOption) (profileSource, error) {
+	gOpt, err := resolveGlobalOption(c)
+	if err != nil {
+		return profileSource{}, err
+	}
+
+	db, err := sql.Open("mysql", gOpt.DSN())
+	if err != nil {
+		return profileSource{}, err
+	}
+	defer db.Close()
+
+	if gOpt.ConnectTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), gOpt.ConnectTimeout)
+		defer cancel()
+
+		if err := db.PingContext(ctx); err != nil {
+			return profileSource{}, fmt.Errorf("database is unreached: %w", err)
+		}
+	}
+
+	cols, stream, err := query.StreamQueryDB(db, opt.SQL, nil)
+	if err != nil {
+		return profileSource{}, err
+	}
+
+	src := profileSource{
+		Name: "sql",
+		Columns: array.Map(cols, func(col extracter.Column, _ int) *profiler.Column {
+			return profiler.NewColumn(col.Name, opt.TopN, opt.DistinctLimit)
+		}),
+	}
+
+	for row := range stream {
+		for i, col := range cols {
+			if row[col.Name] == nil {
+				src.Columns[i].Add(nil)
+				continue
+			}
+
+			val := comparableValue(col, row[col.Name])
+			src.Columns[i].Add(&val)
+		}
+	}
+
+	return src, nil
+}
+
+// buildProfileReport convert the profile results to rows, one row for each column
+func buildProfileReport(sources []profileSource) ([]extracter.Column, []map[string]interface{}) {
+	cols := []extracter.Column{
+		{Name: "source", Type: extracter.ColumnTypeVarchar},
+		{Name: "column", Type: extracter.ColumnTypeVarchar},
+		{Name: "type", Type: extracter.ColumnTypeVarchar},
+		{Name: "rows", Type: extracter.ColumnTypeBigint},
+		{Name: "empty", Type: extracter.ColumnTypeBigint},
+		{Name: "empty_percent", Type: extracter.ColumnTypeDouble},
+		{Name: "distinct", Type: extracter.ColumnTypeBigint},
+		{Name: "distinct_exact", Type: extracter.ColumnTypeVarchar},
+		{Name: "min", Type: extracter.ColumnTypeVarchar},
+		{Name: "max", Type: extracter.ColumnTypeVarchar},
+		{Name: "top_values", Type: extracter.ColumnTypeVarchar},
+		{Name: "len_min", Type: extracter.ColumnTypeBigint},
+		{Name: "len_avg", Type: extracter.ColumnTypeDouble},
+		{Name: "len_p50", Type: extracter.ColumnTypeBigint},
+		{Name: "len_p90", Type: extracter.ColumnTypeBigint},
+		{Name: "len_max", Type: extracter.ColumnTypeBigint},
+	}
+
+	kvs := make([]map[string]interface{}, 0)
+	for _, src := range sources {
+		for _, col := range src.Columns {
+			res := col.Result()
+			kvs = append(kvs, map[string]interface{}{
+				"source":         src.Name,
+				"column":         res.Name,
+				"type":           res.Type,
+				"rows":           int64(res.Rows),
+				"empty":          int64(res.Empty),
+				"empty_percent":  res.EmptyPercent,
+				"distinct":       int64(res.Distinct),
+				"distinct_exact": ternary.If(res.DistinctExact, "yes", "no"),
+				"min":            res.Min,
+				"max":            res.Max,
+				"top_values":     profiler.FormatTop(res.Top),
+				"len_min":        int64(res.LenMin),
+				"len_avg":        res.LenAvg,
+				"len_p50":        int64(res.LenP50),
+				"len_p90":        int64(res.LenP90),
+				"len_max":        int64(res.LenMax),
+			})
+		}
+	}
+
+	return cols, kvs
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/heimdall/charset"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/urfave/cli/v2"
+)
+
+// BuildReaderFlags build flags for reading input files
+func BuildReaderFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{Name: "header-row", Value: 0, Usage: "the row number (start from 1) of the table header, default is the first row of --range or 1"},
+		&cli.IntFlag{Name: "header-rows", Value: 1, Usage: "the number of table header rows, multi-row headers are merged into names in the form of Parent_Child"},
+		&cli.StringFlag{Name: "skip-rows", Value: "", Usage: "the row numbers or ranges to skip, separated by comma, eg: 5,8-10"},
+		&cli.IntFlag{Name: "skip-footer", Value: 0, Usage: "the number of rows to skip at the end of file or sheet, such as the total rows"},
+		&cli.StringFlag{Name: "range", Value: "", Usage: "the cell range to read, eg: A3:K500, the row numbers or column names can be omitted, such as A:K or 3:500"},
+		&cli.StringFlag{Name: "encoding", Value: charset.Auto, Usage: "the encoding of csv file, support " + strings.Join(charset.SupportedEncodings, ", ") + ", auto means detecting by BOM and content"},
+		&cli.StringFlag{Name: "input-format", Value: "", Usage: "the format of input files, support " + strings.Join(reader.SupportedFormats, ", ") + ", required when reading from STDIN (-), default is detected by file extension"},
+		&cli.StringFlag{Name: "quote", Value: `"`, Usage: "the quote character of csv file"},
+		&cli.BoolFlag{Name: "lazy-quotes", Usage: "allow quotes appear in unquoted field and non-doubled quotes appear in quoted field of csv file"},
+		&cli.StringFlag{Name: "comment", Value: "", Usage: "the comment character of csv file, lines beginning with it are ignored, eg: #"},
+		&cli.BoolFlag{Name: "trim-leading-space", Usage: "ignore the leading white space of fields in csv file"},
+		&cli.BoolFlag{Name: "fill-merged", Usage: "fill the value of merged cells to all cells covered, by default only the first cell has the value"},
+		&cli.BoolFlag{Name: "skip-hidden", Usage: "ignore the hidden rows and columns of excel files, including the rows hidden by autofilter"},
+	}
+}
+
+// csvSepertorUsage is the usage of --csv-sepertor flag
+const csvSepertorUsage = "csv file sepertor, support auto (detected from the first lines), \\t (or tab) and any single character such as ; or |, default is ','"
+
+// parseDelimiterFlag parse the value of delimiter flags such as --csv-sepertor, reader.AutoDelimiter is returned for auto
+func parseDelimiterFlag(name string, val string) rune {
+	switch strings.ToLower(val) {
+	case "auto":
+		return reader.AutoDelimiter
+	case `\t`, "tab":
+		return '\t'
+	}
+
+	return parseCharFlag(name, val, ',')
+}
+
+// parseCharFlag parse the flag value which should be a single character, def is returned if the value is empty
+func parseCharFlag(name string, val string, def rune) rune {
+	if val == "" {
+		return def
+	}
+
+	if utf8.RuneCountInString(val) > 1 {
+		log.Warningf("--%s only supports a single character, only the first character of %s is used", name, val)
+	}
+
+	ch, _ := utf8.DecodeRuneInString(val)
+	return ch
+}
+
+// resolveReaderOption resolve reader options from reader flags, the options specific to commands such as csv sepertor are not included
+func resolveReaderOption(c *cli.Context) reader.Options {
+	return reader.Options{
+		HeaderRow:  c.Int("header-row"),
+		HeaderRows: c.Int("header-rows"),
+		SkipRows:   c.String("skip-rows"),
+		SkipFooter: c.Int("skip-footer"),
+		Range:      c.String("range"),
+		Encoding:   c.String("encoding"),
+		Format:     c.String("input-format"),
+		FillMerged: c.Bool("fill-merged"),
+		SkipHidden: c.Bool("skip-hidden"),
+
+		CSVQuote:         parseCharFlag("quote", c.String("quote"), '"'),
+		CSVComment:       parseCharFlag("comment", c.String("comment"), 0),
+		LazyQuotes:       c.Bool("lazy-quotes"),
+		TrimLeadingSpace: c.Bool("trim-leading-space"),
+	}
+}
+
+// BuildCSVOutputFlags build flags for csv output
+func BuildCSVOutputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "output-encoding", Value: "", Usage: "the encoding of csv output, support " + strings.Join(charset.SupportedEncodings[1:], ", ") + ", default is utf-8 with BOM"},
+		&cli.StringFlag{Name: "output-delimiter", Value: ",", Usage: "the delimiter of csv output, support \\t (or tab) and any single character"},
+		&cli.BoolFlag{Name: "quote-all", Usage: "quote all fields of csv output, default only the fields containing special characters are quoted"},
+		&cli.BoolFlag{Name: "crlf", Usage: "use \\r\\n as the line terminator of csv output"},
+		&cli.BoolFlag{Name: "no-bom", Usage: "do not write utf-8 BOM at the beginning of csv output"},
+	}
+}
+
+// resolveCSVOutputOption resolve csv output options from csv output flags, the output encoding is not included
+func resolveCSVOutputOption(c *cli.Context) render.CSVOptions {
+	comma := parseDelimiterFlag("output-delimiter", c.String("output-delimiter"))
+	if comma == reader.AutoDelimiter {
+		log.Warningf("--output-delimiter does not support auto, use ',' instead")
+		comma = ','
+	}
+
+	return render.CSVOptions{
+		Comma:    comma,
+		QuoteAll: c.Bool("quote-all"),
+		UseCRLF:  c.Bool("crlf"),
+		NoBOM:    c.Bool("no-bom"),
+	}
+}
+
+// wrapOutputEncoding wrap the output writer to encode csv output, the returned writer must be closed before the output
+func wrapOutputEncoding(w io.Writer, format string, encoding string) (io.WriteCloser, error) {
+	if encoding != "" && format != "csv" {
+		log.Warningf("--output-encoding only works with csv format, ignored")
+		encoding = ""
+	}
+
+	return charset.NewWriter(w, encoding)
+}
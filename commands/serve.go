@@ -0,0 +1,439 @@
+package commands
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/asteria/level"
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/heimdall/mask"
+	"github.com/mylxsw/heimdall/query"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/urfave/cli/v2"
+)
+
+type ServeOption struct {
+	Listen        string
+	Token         string
+	Connections   map[string]string
+	QueryTimeout  time.Duration
+	MaxUploadSize int64
+	TempDir       string
+	Beta          bool
+}
+
+func BuildServeFlags() []cli.Flag {
+	return append(append(BuildGlobalFlags(), []cli.Flag{
+		&cli.StringFlag{Name: "listen", Aliases: []string{"l"}, Value: "127.0.0.1:8080", Usage: "http server listen address"},
+		&cli.StringFlag{Name: "token", Value: "", Usage: "static token for authorization, clients should send it as 'Authorization: Bearer TOKEN', if not set, authorization is disabled"},
+		&cli.StringSliceFlag{Name: "connection", Aliases: []string{"c"}, Usage: "named database connection, eg: NAME=user:password@tcp(host:port)/database or NAME=profile:PROFILE, this flag can be specified multiple times, the connection specified by global flags is named 'default'"},
+		&cli.DurationFlag{Name: "query-timeout", Aliases: []string{"t"}, Value: 120 * time.Second, Usage: "query timeout for each request, for streaming formats it only limits the query, not the writing of the response"},
+		&cli.Int64Flag{Name: "max-upload-size", Value: 100 << 20, Usage: "the maximum size in bytes of the request body for fly"},
+		&cli.StringFlag{Name: "temp-dir", Value: os.TempDir(), Usage: "directory for saving uploaded files temporarily"},
+	}...), BuildMaskFlags()...)
+}
+
+func resolveServeOption(c *cli.Context, gOpt GlobalOption) (ServeOption, error) {
+	connections := map[string]string{"default": gOpt.DSN()}
+	for _, conn := range c.StringSlice("connection") {
+		segs := strings.SplitN(conn, "=", 2)
+		if len(segs) != 2 || segs[0] == "" {
+			log.Warningf("invalid connection %s, should be NAME=DSN or NAME=profile:PROFILE, ignored", conn)
+			continue
+		}
+
+		if strings.HasPrefix(segs[1], "profile:") {
+			profile, err := loadProfile(c.String("config"), strings.TrimPrefix(segs[1], "profile:"))
+			if err != nil {
+				return ServeOption{}, err
+			}
+
+			profileOpt, err := profile.apply(GlobalOption{Host: "127.0.0.1", Port: 3306, User: "root"})
+			if err != nil {
+				return ServeOption{}, err
+			}
+
+			if err := profileOpt.registerConnection(); err != nil {
+				return ServeOption{}, err
+			}
+
+			connections[segs[0]] = profileOpt.DSN()
+			continue
+		}
+
+		connections[segs[0]] = segs[1]
+	}
+
+	return ServeOption{
+		Listen:        c.String("listen"),
+		Token:         c.String("token"),
+		Connections:   connections,
+		QueryTimeout:  c.Duration("query-timeout"),
+		MaxUploadSize: c.Int64("max-upload-size"),
+		TempDir:       c.String("temp-dir"),
+		Beta:          gOpt.Beta,
+	}, nil
+}
+
+func ServeCommand(c *cli.Context) error {
+	if !c.Bool("debug") {
+		log.All().LogLevel(level.Info)
+	}
+
+	gOpt, err := resolveGlobalOption(c)
+	if err != nil {
+		return err
+	}
+
+	opt, err := resolveServeOption(c, gOpt)
+	if err != nil {
+		return err
+	}
+
+	dbs := make(map[string]*sql.DB)
+	defer func() {
+		for _, db := range dbs {
+			_ = db.Close()
+		}
+	}()
+
+	for name, dsn := range opt.Connections {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return fmt.Errorf("open connection %s failed: %w", name, err)
+		}
+
+		dbs[name] = db
+	}
+
+	if gOpt.ConnectTimeout > 0 {
+		for name, db := range dbs {
+			ctx, cancel := context.WithTimeout(context.Background(), gOpt.ConnectTimeout)
+			if err := db.PingContext(ctx); err != nil {
+				log.Warningf("database for connection %s is unreached: %v", name, err)
+			}
+			cancel()
+		}
+	}
+
+	masker, err := resolveMasker(c)
+	if err != nil {
+		return err
+	}
+
+	srv := &apiServer{opt: opt, dbs: dbs, masker: masker}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/export", srv.authorize(srv.export))
+	mux.HandleFunc("/fly", srv.authorize(srv.fly))
+
+	log.Infof("http server started, listening on %s", opt.Listen)
+	return http.ListenAndServe(opt.Listen, mux)
+}
+
+type apiServer struct {
+	opt    ServeOption
+	dbs    map[string]*sql.DB
+	masker *mask.Masker
+}
+
+// ExportRequest is the request body of POST /export
+type ExportRequest struct {
+	SQL        string        `json:"sql"`
+	Args       []interface{} `json:"args"`
+	Connection string        `json:"connection"`
+	NoHeader   bool          `json:"no_header"`
+	Table      string        `json:"table"`
+}
+
+// authorize check the static token if it is configured
+func (srv *apiServer) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if srv.opt.Token != "" {
+			token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+			if subtle.ConstantTimeCompare([]byte(token), []byte(srv.opt.Token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+func (srv *apiServer) export(w http.ResponseWriter, r *http.Request) {
+	var req ExportRequest
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.SQL = r.FormValue("sql")
+		req.Connection = r.FormValue("connection")
+		req.NoHeader = r.FormValue("no_header") == "true"
+		req.Table = r.FormValue("table")
+		req.Args = array.Map(r.Form["args"], func(arg string, _ int) interface{} { return arg })
+	}
+
+	req.SQL = strings.Trim(strings.TrimSpace(req.SQL), ";")
+	if req.SQL == "" {
+		http.Error(w, "sql is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Connection == "" {
+		req.Connection = "default"
+	}
+
+	db, ok := srv.dbs[req.Connection]
+	if !ok {
+		http.Error(w, fmt.Sprintf("connection %s not found", req.Connection), http.StatusBadRequest)
+		return
+	}
+
+	srv.writeQueryResult(w, r, db, req.SQL, req.Args, req.NoHeader, req.Table)
+}
+
+func (srv *apiServer) fly(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, srv.opt.MaxUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, fmt.Sprintf("invalid multipart form: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	sqlStr := strings.Trim(strings.TrimSpace(r.FormValue("sql")), ";")
+	if sqlStr == "" {
+		http.Error(w, "sql is required", http.StatusBadRequest)
+		return
+	}
+
+	tempDir, err := os.MkdirTemp(srv.opt.TempDir, "heimdall-fly-")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("create temp dir failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 表单字段名为 file 的文件按照 table_N 自动命名，其它字段名作为表名使用
+	// 按照字段名排序，保证相同请求中自动命名的表名一致
+	fields := array.FromMapKeys(r.MultipartForm.File)
+	sort.Strings(fields)
+
+	inputFiles := make([]string, 0)
+	for _, field := range fields {
+		headers := r.MultipartForm.File[field]
+		if !flyTableNamePattern.MatchString(field) {
+			http.Error(w, fmt.Sprintf("invalid table name %s: must match %s", field, flyTableNamePattern), http.StatusBadRequest)
+			return
+		}
+
+		for i, header := range headers {
+			ext := strings.ToLower(filepath.Ext(header.Filename))
+			if !reader.IsSupported(header.Filename, reader.Options{}) {
+				http.Error(w, fmt.Sprintf("unsupported file %s: only support csv, xlsx, xls or ods files", header.Filename), http.StatusBadRequest)
+				return
+			}
+
+			savePath := filepath.Join(tempDir, fmt.Sprintf("%s-%d%s", field, i, ext))
+			if err := saveUploadedFile(header, savePath); err != nil {
+				http.Error(w, fmt.Sprintf("save uploaded file failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			if field == "file" {
+				inputFiles = append(inputFiles, savePath)
+			} else {
+				inputFiles = append(inputFiles, field+":"+savePath)
+			}
+		}
+	}
+
+	if len(inputFiles) == 0 {
+		http.Error(w, "at least one file is required", http.StatusBadRequest)
+		return
+	}
+
+	db, err := openFlyDatabase(":memory:")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("create sqlite database failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	// 内存数据库每个连接都是独立的，这里限制只使用一个连接
+	db.SetMaxOpenConns(1)
+
+	if _, err := createMemoryDatabaseForFly(FlyOption{
+		InputFiles:  inputFiles,
+		CSVSepertor: ',',
+		TempDS:      ":memory:",
+		Slient:      true,
+		Beta:        srv.opt.Beta,
+	}, db); err != nil {
+		http.Error(w, fmt.Sprintf("load files failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	srv.writeQueryResult(w, r, db, rewriteMySQLCompatSQL(sqlStr), nil, r.FormValue("no_header") == "true", r.FormValue("table"))
+}
+
+// writeQueryResult execute the query and write the result to response, the output format
+// is resolved from the query parameter format or Accept header
+func (srv *apiServer) writeQueryResult(w http.ResponseWriter, r *http.Request, db *sql.DB, sqlStr string, args []interface{}, noHeader bool, table string) {
+	format := resolveResponseFormat(r)
+	if !array.In(format, query.SupportedStandardFormats) {
+		http.Error(w, fmt.Sprintf("unsupport output format: %s", format), http.StatusNotAcceptable)
+		return
+	}
+
+	if format == "sql" && table == "" {
+		http.Error(w, "when the format is sql, the table name (table) is required", http.StatusBadRequest)
+		return
+	}
+
+	startTime := time.Now()
+
+	if array.In(format, query.SupportedStreamingFormats) {
+		// 超时只限制查询本身，查询返回后结果的输出时间不受限制，客户端断开连接时会取消查询
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		timer := time.AfterFunc(srv.opt.QueryTimeout, cancel)
+		cols, stream, err := query.StreamQueryDBContext(ctx, db, sqlStr, args)
+		timer.Stop()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("query failed: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		// 确保查询协程在渲染失败（如客户端断开连接）时能够退出
+		defer func() {
+			cancel()
+			for range stream {
+			}
+		}()
+
+		w.Header().Set("Content-Type", formatContentTypes[format])
+		total, err := render.StreamingRender(w, format, noHeader, srv.masker.Columns(cols), srv.masker.Stream(stream), table, render.CSVOptions{})
+		if err != nil {
+			log.WithFields(log.Fields{"sql": sqlStr}).Errorf("write response failed: %v", err)
+			return
+		}
+
+		log.Debugf("%s %s: total %d records, %s elapsed", r.Method, r.URL.Path, total, time.Since(startTime))
+		return
+	}
+
+	rs, err := query.QueryDB(db, sqlStr, args, srv.opt.QueryTimeout)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("query failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	query.MaskRows(rs, srv.masker)
+
+	buf, err := render.Render(format, noHeader, rs.Columns, rs.DataSets, sqlStr, table, render.CSVOptions{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("render failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	if _, err := buf.WriteTo(w); err != nil {
+		log.WithFields(log.Fields{"sql": sqlStr}).Errorf("write response failed: %v", err)
+		return
+	}
+
+	log.Debugf("%s %s: total %d records, %s elapsed", r.Method, r.URL.Path, len(rs.DataSets), time.Since(startTime))
+}
+
+var formatContentTypes = map[string]string{
+	"csv":      "text/csv; charset=utf-8",
+	"json":     "application/x-ndjson; charset=utf-8",
+	"yaml":     "application/yaml; charset=utf-8",
+	"xml":      "application/xml; charset=utf-8",
+	"table":    "text/plain; charset=utf-8",
+	"html":     "text/html; charset=utf-8",
+	"markdown": "text/markdown; charset=utf-8",
+	"xlsx":     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"plain":    "text/plain; charset=utf-8",
+	"sql":      "application/sql; charset=utf-8",
+}
+
+var acceptFormats = map[string]string{
+	"text/csv":             "csv",
+	"application/json":     "json",
+	"application/x-ndjson": "json",
+	"application/yaml":     "yaml",
+	"application/x-yaml":   "yaml",
+	"text/yaml":            "yaml",
+	"application/xml":      "xml",
+	"text/xml":             "xml",
+	"text/html":            "html",
+	"text/markdown":        "markdown",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": "xlsx",
+	"text/plain":      "plain",
+	"application/sql": "sql",
+}
+
+// resolveResponseFormat resolve output format from query parameter format or Accept header, default is json
+func resolveResponseFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		if format, ok := acceptFormats[mediaType]; ok {
+			return format
+		}
+	}
+
+	return "json"
+}
+
+// flyTableNamePattern is the valid table name for files uploaded to fly api
+var flyTableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func saveUploadedFile(header *multipart.FileHeader, savePath string) error {
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(savePath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	_, err = io.Copy(dest, src)
+	return err
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"bytes"
+	"database/sql"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+// newTestAPIServer create an api server with a sqlite database as the default connection
+func newTestAPIServer(t *testing.T, token string) (*apiServer, *http.ServeMux) {
+	db, err := openFlyDatabase(":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec("CREATE TABLE users (id INTEGER, name TEXT)")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users VALUES (1, 'alice'), (2, 'bob')")
+	assert.NoError(t, err)
+
+	srv := &apiServer{
+		opt: ServeOption{
+			Token:         token,
+			QueryTimeout:  10 * time.Second,
+			MaxUploadSize: 10 << 20,
+			TempDir:       t.TempDir(),
+		},
+		dbs: map[string]*sql.DB{"default": db},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/export", srv.authorize(srv.export))
+	mux.HandleFunc("/fly", srv.authorize(srv.fly))
+
+	return srv, mux
+}
+
+func serveTestRequest(mux *http.ServeMux, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func newExportRequest(path string, sqlStr string) *http.Request {
+	return newExportFormRequest(path, url.Values{"sql": {sqlStr}})
+}
+
+func newExportFormRequest(path string, form url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestServeAuthorize(t *testing.T) {
+	_, mux := newTestAPIServer(t, "secret")
+
+	req := httptest.NewRequest(http.MethodGet, "/export?sql=SELECT+1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, serveTestRequest(mux, req).Code)
+
+	req = newExportRequest("/export", "SELECT 1")
+	assert.Equal(t, http.StatusUnauthorized, serveTestRequest(mux, req).Code)
+
+	req = newExportRequest("/export", "SELECT 1")
+	req.Header.Set("Authorization", "Bearer wrong")
+	assert.Equal(t, http.StatusUnauthorized, serveTestRequest(mux, req).Code)
+
+	req = newExportRequest("/export", "SELECT 1")
+	req.Header.Set("Authorization", "Bearer secret")
+	assert.Equal(t, http.StatusOK, serveTestRequest(mux, req).Code)
+
+	// 未配置 token 时不需要认证，但仍然只允许 POST
+	_, mux = newTestAPIServer(t, "")
+	assert.Equal(t, http.StatusOK, serveTestRequest(mux, newExportRequest("/export", "SELECT 1")).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serveTestRequest(mux, httptest.NewRequest(http.MethodGet, "/fly", nil)).Code)
+}
+
+func TestServeExport(t *testing.T) {
+	_, mux := newTestAPIServer(t, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/export?format=csv", strings.NewReader(`{"sql": "SELECT id, name FROM users WHERE id = ?;", "args": [2]}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := serveTestRequest(mux, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "\ufeffid,name\n2,bob\n", rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, serveTestRequest(mux, newExportRequest("/export", " ; ")).Code)
+
+	req = newExportFormRequest("/export", url.Values{"sql": {"SELECT 1"}, "connection": {"missing"}})
+	assert.Equal(t, http.StatusBadRequest, serveTestRequest(mux, req).Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/export", strings.NewReader(`{"sql":`))
+	req.Header.Set("Content-Type", "application/json")
+	assert.Equal(t, http.StatusBadRequest, serveTestRequest(mux, req).Code)
+}
+
+func TestServeExportFormat(t *testing.T) {
+	_, mux := newTestAPIServer(t, "")
+
+	var testcases = []struct {
+		query       string
+		accept      string
+		code        int
+		contentType string
+	}{
+		{query: "", accept: "", code: http.StatusOK, contentType: formatContentTypes["json"]},
+		{query: "", accept: "text/csv", code: http.StatusOK, contentType: formatContentTypes["csv"]},
+		{query: "", accept: "application/unknown, text/markdown;q=0.9", code: http.StatusOK, contentType: formatContentTypes["markdown"]},
+		{query: "", accept: "*/*", code: http.StatusOK, contentType: formatContentTypes["json"]},
+		{query: "yaml", accept: "text/csv", code: http.StatusOK, contentType: formatContentTypes["yaml"]},
+		{query: "xlsx", accept: "", code: http.StatusOK, contentType: formatContentTypes["xlsx"]},
+		{query: "unknown", accept: "", code: http.StatusNotAcceptable},
+		{query: "", accept: "application/sql", code: http.StatusBadRequest},
+	}
+
+	for _, tc := range testcases {
+		path := "/export"
+		if tc.query != "" {
+			path += "?format=" + tc.query
+		}
+
+		req := newExportRequest(path, "SELECT id, name FROM users")
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+
+		rec := serveTestRequest(mux, req)
+		assert.Equal(t, tc.code, rec.Code)
+		if tc.code == http.StatusOK {
+			assert.Equal(t, tc.contentType, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	req := newExportFormRequest("/export?format=sql", url.Values{"sql": {"SELECT id, name FROM users"}, "table": {"users_copy"}})
+	rec := serveTestRequest(mux, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), "users_copy"))
+}
+
+// newFlyRequest create a multipart request for fly api, files is a list of field name and file content pairs
+func newFlyRequest(t *testing.T, sqlStr string, files [][3]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	assert.NoError(t, mw.WriteField("sql", sqlStr))
+	for _, file := range files {
+		fw, err := mw.CreateFormFile(file[0], file[1])
+		assert.NoError(t, err)
+		_, err = fw.Write([]byte(file[2]))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/fly?format=csv", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestServeFly(t *testing.T) {
+	_, mux := newTestAPIServer(t, "")
+
+	files := [][3]string{
+		{"users", "users.csv", "id,name\n1,alice\n2,bob\n"},
+		{"file", "orders.csv", "id,user_id\n10,1\n11,2\n12,2\n"},
+		{"file", "refunds.csv", "id,order_id\n20,12\n"},
+	}
+
+	// 多个文件时，自动命名的表名不受表单字段遍历顺序的影响
+	for i := 0; i < 5; i++ {
+		rec := serveTestRequest(mux, newFlyRequest(t, "SELECT u.name, COUNT(*) AS cnt FROM users u JOIN table_0 o ON o.user_id = u.id JOIN table_1 r ON r.order_id = o.id GROUP BY u.name", files))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "\ufeffname,cnt\nbob,1\n", rec.Body.String())
+	}
+
+	rec := serveTestRequest(mux, newFlyRequest(t, "SELECT * FROM t", [][3]string{{"bad-name", "a.csv", "id\n1\n"}}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), "invalid table name bad-name"))
+
+	rec = serveTestRequest(mux, newFlyRequest(t, "SELECT * FROM t", [][3]string{{"1users", "a.csv", "id\n1\n"}}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serveTestRequest(mux, newFlyRequest(t, "SELECT * FROM t", [][3]string{{"t", "a.txt", "id\n1\n"}}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serveTestRequest(mux, newFlyRequest(t, "SELECT 1", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serveTestRequest(mux, newFlyRequest(t, "", [][3]string{{"t", "a.csv", "id\n1\n"}}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
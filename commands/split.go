@@ -2,14 +2,20 @@ package commands
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/mylxsw/asteria/event"
 	"github.com/mylxsw/asteria/filter"
 	"github.com/mylxsw/asteria/level"
 	"github.com/mylxsw/asteria/log"
-	"github.com/mylxsw/go-utils/must"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/charset"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
 	"github.com/urfave/cli/v2"
-	"github.com/xuri/excelize/v2"
 )
 
 type SplitOption struct {
@@ -20,34 +26,108 @@ type SplitOption struct {
 	PerfileLimit    int
 	HeaderRowEndNum int
 	ColumnIndex     string
+	// MaxSize is the maximum estimated size (in bytes) of each file when mode=row, 0 means no limit
+	MaxSize int64
+	// By is the columns to split by, such as B,D, the rows with the same values of these columns are written to the same file
+	By []string
+	// ByExpr is the expression to calculate the group of rows, such as date(C).Year()
+	ByExpr string
+
+	// OutputFormat is the format of split files, xlsx or csv
+	OutputFormat string
+	// OutputDir is the directory of split files, default is the directory of input file
+	OutputDir string
+	// NameTemplate is the template of split filenames, such as {{.base}}-{{.key|slug}}-{{.n}}.xlsx
+	NameTemplate   string
+	ReaderOption   reader.Options
+	OutputEncoding string
+	CSVOutput      render.CSVOptions
+}
+
+// output return the output of split files, defaultTemplate is the name template used when opt.NameTemplate is empty
+func (opt SplitOption) output(defaultTemplate string) (splitOutput, error) {
+	namer, err := newSplitNamer(opt, defaultTemplate)
+	if err != nil {
+		return splitOutput{}, err
+	}
+
+	return splitOutput{
+		Format:   opt.OutputFormat,
+		CSV:      opt.CSVOutput,
+		Encoding: opt.OutputEncoding,
+		namer:    namer,
+		manifest: newSplitManifest(opt),
+	}, nil
 }
 
 func BuildSplitFlags() []cli.Flag {
-	return []cli.Flag{
-		&cli.StringFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel file path, currently only support xlsx format", Required: true},
+	return append([]cli.Flag{
+		&cli.StringFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input file path, support xlsx (xlsm, xltx), xls, ods and csv format", Required: true},
 		&cli.BoolFlag{Name: "slient", Value: false, Usage: "do not print warning log"},
 		&cli.BoolFlag{Name: "debug", Aliases: []string{"D"}, Value: false, Usage: "debug mode"},
 		&cli.StringFlag{Name: "mode", Aliases: []string{"m"}, Usage: "split method: row, column, sheet", Value: "row"},
-		&cli.IntFlag{Name: "perfile-limit", Aliases: []string{"p"}, Value: 1000, Usage: "the maximum number of records per file, only valid when mode=row"},
+		&cli.IntFlag{Name: "perfile-limit", Aliases: []string{"p"}, Value: 1000, Usage: "the maximum number of records per file, only valid when mode=row, no limit when only --max-size is specified"},
+		&cli.StringFlag{Name: "max-size", Usage: "the maximum size of each file when mode=row, such as 500KB, 20MB, the size is estimated by the serialized size of rows before compression"},
 		&cli.IntFlag{Name: "header-row-num", Aliases: []string{"r"}, Value: 1, Usage: "table header row maximum row number, only valid when mode=row or mode=column"},
 		&cli.StringFlag{Name: "column-index", Aliases: []string{"c"}, Usage: "specifies the index of the column to split, such as 'A', 'AA', only valid when mode=column"},
-	}
+		&cli.StringFlag{Name: "by", Usage: "the columns to split by, separated by comma, such as 'B,D', the rows with the same values of these columns are written to one file, mode=column is implied"},
+		&cli.StringFlag{Name: "by-expr", Usage: "the expression to calculate the group of each row, such as 'date(C).Year()', the columns can be referenced by name (A, B...) or header, mode=column is implied"},
+		&cli.StringFlag{Name: "output-format", Value: splitFormatXLSX, Usage: "the format of split files, support xlsx, csv"},
+		&cli.StringFlag{Name: "output-dir", Aliases: []string{"o"}, Usage: "the directory of split files, default is the directory of input file"},
+		&cli.StringFlag{Name: "name-template", Usage: "the template of split filenames, such as '{{.base}}-{{.key|slug}}-{{.n}}.xlsx', support variables: base, key, n, sheet, ext and functions: slug, lower, upper"},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
+		&cli.StringFlag{Name: "encoding", Value: charset.Auto, Usage: "the encoding of csv file, support " + strings.Join(charset.SupportedEncodings, ", ") + ", auto means detecting by BOM and content"},
+	}, BuildCSVOutputFlags()...)
 }
 
-func resolveSplitOption(c *cli.Context) SplitOption {
+func resolveSplitOption(c *cli.Context) (SplitOption, error) {
+	maxSize, err := parseByteSize(c.String("max-size"))
+	if err != nil {
+		return SplitOption{}, fmt.Errorf("invalid max-size: %w", err)
+	}
+
+	by := array.Filter(
+		array.Map(strings.Split(c.String("by"), ","), func(col string, _ int) string { return strings.ToUpper(strings.TrimSpace(col)) }),
+		func(col string, _ int) bool { return col != "" },
+	)
+
+	// 指定了分组方式时，默认按照列拆分
+	mode := c.String("mode")
+	if !c.IsSet("mode") && (len(by) > 0 || c.String("by-expr") != "") {
+		mode = "column"
+	}
+
 	return SplitOption{
 		InputFile:       c.String("input"),
 		Slient:          c.Bool("slient"),
 		Debug:           c.Bool("debug"),
-		Mode:            c.String("mode"),
-		PerfileLimit:    c.Int("perfile-limit"),
+		Mode:            mode,
+		PerfileLimit:    ternary.If(maxSize > 0 && !c.IsSet("perfile-limit"), 0, c.Int("perfile-limit")),
 		HeaderRowEndNum: c.Int("header-row-num"),
 		ColumnIndex:     c.String("column-index"),
-	}
+		MaxSize:         maxSize,
+		By:              by,
+		ByExpr:          c.String("by-expr"),
+
+		OutputFormat: strings.ToLower(c.String("output-format")),
+		OutputDir:    c.String("output-dir"),
+		NameTemplate: c.String("name-template"),
+		ReaderOption: reader.Options{
+			CSVSepertor: parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor")),
+			CSVQuote:    '"',
+			Encoding:    c.String("encoding"),
+		},
+		OutputEncoding: c.String("output-encoding"),
+		CSVOutput:      resolveCSVOutputOption(c),
+	}, nil
 }
 
 func SplitCommand(c *cli.Context) error {
-	opt := resolveSplitOption(c)
+	opt, err := resolveSplitOption(c)
+	if err != nil {
+		return err
+	}
+
 	if !opt.Debug {
 		log.All().LogLevel(level.Info)
 	}
@@ -68,53 +148,68 @@ func SplitCommand(c *cli.Context) error {
 		return fmt.Errorf("input file (--file) is required")
 	}
 
+	if opt.OutputFormat != splitFormatXLSX && opt.OutputFormat != splitFormatCSV {
+		return fmt.Errorf("invalid output-format %s, only support xlsx, csv", opt.OutputFormat)
+	}
+
+	if opt.OutputEncoding != "" && opt.OutputFormat != splitFormatCSV {
+		log.Warningf("--output-encoding only works with csv format, ignored")
+		opt.OutputEncoding = ""
+	}
+
+	if opt.MaxSize > 0 && (opt.Mode == "column" || opt.Mode == "sheet") {
+		log.Warningf("--max-size only works when mode=row, ignored")
+	}
+
 	switch opt.Mode {
 	case "column":
-		if opt.ColumnIndex == "" {
-			return fmt.Errorf("column-index is required when mode=column")
+		if opt.ColumnIndex == "" && len(opt.By) == 0 && opt.ByExpr == "" {
+			return fmt.Errorf("one of column-index, by and by-expr is required when mode=column")
 		}
 
-		return SplitExcelByColumn(opt.Slient, opt.InputFile, opt.HeaderRowEndNum, opt.ColumnIndex)
+		return SplitExcelByColumn(opt)
 	case "sheet":
-		return SplitExcelBySheets(opt.Slient, opt.InputFile)
+		return SplitExcelBySheets(opt)
 	default:
-		return SplitExcelToParts(opt.Slient, opt.InputFile, opt.HeaderRowEndNum, opt.PerfileLimit)
+		return SplitExcelToParts(opt)
 	}
 }
 
-func copyExcelHeaders(srcFile *excelize.File, destFile *excelize.File, sheet string, rows *[][]string, headerRowEndNum int) error {
-	// 复制表头字段导目标文件
-	for i, row := range (*rows)[:headerRowEndNum] {
-		for j, cell := range row {
-			cellName, err := excelize.CoordinatesToCellName(j+1, i+1)
-			if err != nil {
-				return err
-			}
-			destFile.SetCellValue(sheet, cellName, cell)
-		}
-	}
+// byteSizeUnits is the units supported by parseByteSize
+var byteSizeUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+}
 
-	// 合并表头字段单元格
-	func() {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Errorf("something went wrong while merging cells: %v", err)
-			}
-		}()
+// parseByteSize parse the size such as 500KB, 20MB, 1.5G, the units are case-insensitive and 1KB is 1024 bytes,
+// 0 is returned for empty string
+func parseByteSize(val string) (int64, error) {
+	val = strings.ToUpper(strings.TrimSpace(val))
+	if val == "" {
+		return 0, nil
+	}
 
-		for _, cell := range must.Must(srcFile.GetMergeCells(sheet)) {
-			_, startRowNum, _ := excelize.SplitCellName(cell.GetStartAxis())
-			_, endRowNum, _ := excelize.SplitCellName(cell.GetEndAxis())
+	num := strings.TrimRightFunc(val, func(r rune) bool { return r >= 'A' && r <= 'Z' })
+	unit, ok := byteSizeUnits[strings.TrimSpace(val[len(num):])]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit of size %s, support B, KB, MB, GB", val)
+	}
 
-			if startRowNum > headerRowEndNum || endRowNum > headerRowEndNum {
-				continue
-			}
+	size, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %s", val)
+	}
 
-			if err := destFile.MergeCell(sheet, cell.GetStartAxis(), cell.GetEndAxis()); err != nil {
-				log.Warningf("merge cell for %s failed: %v", sheet, err)
-			}
-		}
-	}()
+	return int64(size * float64(unit)), nil
+}
 
-	return nil
+// splitFilePrefix return the prefix of split files, which is the input file path without extension
+func splitFilePrefix(src string) string {
+	return strings.TrimSuffix(src, filepath.Ext(src))
 }
@@ -4,88 +4,203 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+
 	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
 	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/reader"
 	"github.com/xuri/excelize/v2"
 )
 
-// SplitExcelByColumn 按照指定的列来拆分 Excel 文件
-func SplitExcelByColumn(slient bool, src string, headerRowEndNum int, columnIndex string) error {
+// SplitExcelByColumn 按照指定的列（opt.ColumnIndex 或者 opt.By）或者表达式（opt.ByExpr）的值来拆分 Excel 或者 csv 文件，
+// 每个值对应一个文件
+//
+// 读取时每一行先按照列值写入临时文件，全部读取完成后再逐个生成拆分文件，因此内存占用以及同时打开的文件数量不受列值数量的影响
+func SplitExcelByColumn(opt SplitOption) error {
 	logger := NewLogger()
 	defer logger.Flush()
 
-	column, err := excelize.ColumnNameToNumber(columnIndex)
+	grouper, err := newSplitGrouper(opt)
+	if err != nil {
+		return err
+	}
+
+	output, err := opt.output(splitColumnNameTemplate)
 	if err != nil {
-		return fmt.Errorf("invalid column-index: %w", err)
+		return err
 	}
 
-	prg := NewProgressbar(!slient, "opening src file ...")
+	prg := NewProgressbar(!opt.Slient, "opening src file ...")
 	defer prg.Close()
 
-	srcFile, err := excelize.OpenFile(src)
+	source, err := openSplitSource(opt.InputFile, opt.ReaderOption, output.Format == splitFormatXLSX)
 	if err != nil {
 		return err
 	}
-	defer srcFile.Close()
+	defer source.Close()
+
+	sheets := source.Sheets()
+	if len(sheets) == 0 {
+		return nil
+	}
 
-	prg.Reset(-1, "loading ...")
+	if len(sheets) > 1 {
+		log.Warningf("file has more than one sheet, only the first sheet will be processed")
+	}
+
+	sheet := sheets[0]
+	format, err := source.Format(sheet)
+	if err != nil {
+		return fmt.Errorf("read formatting of sheet %s failed: %w", sheet, err)
+	}
+	format.HeaderRows = opt.HeaderRowEndNum
 
-	for i, sheet := range srcFile.GetSheetList() {
-		rows, err := srcFile.GetRows(sheet)
+	spill, err := newSplitSpill()
+	if err != nil {
+		return err
+	}
+	defer spill.Close()
+
+	prg.Reset(-1, "processing ...")
+
+	headers := make([]splitRecord, 0)
+	if err := walkSplitRows(
+		source, sheet, opt.HeaderRowEndNum,
+		func(row splitRow) error {
+			headers = append(headers, output.record(row))
+			grouper.headers = reader.CellValues(row.Cells)
+			return nil
+		},
+		func(row splitRow) error {
+			prg.Add(1)
+			key, err := grouper.Key(row.Cells)
+			if err != nil {
+				return err
+			}
+
+			return spill.Add(key, output.record(row))
+		},
+	); err != nil {
+		return err
+	}
+
+	prg.Reset(len(spill.Keys()), "writing files ...")
+
+	for i, key := range spill.Keys() {
+		prg.Add(1)
+
+		part, err := output.newPart(splitKeyName(key), i+1, sheet, headers, format)
 		if err != nil {
 			return err
 		}
 
-		if i > 0 && len(rows) > 0 {
-			log.Warningf("file has more than one sheet, only the first sheet will be processed")
-			break
+		if err := spill.Rows(key, part.Add); err != nil {
+			_ = part.Close()
+			return err
 		}
 
-		prg.Reset(len(rows[headerRowEndNum:]), "processing ...")
+		if err := output.save(logger, part); err != nil {
+			return err
+		}
+	}
 
-		destFileMap := make(map[string]*ExcelFileWithIndex, 0)
-		for _, row := range rows[headerRowEndNum:] {
-			prg.Add(1)
-			colVal := ternary.IfElseLazy(len(row) < column, func() string { return "" }, func() string { return row[column-1] })
-			d, ok := destFileMap[colVal]
-			if !ok {
-				destFile := excelize.NewFile()
-				// 重命名默认的 Sheet 名称为 src 文件中的名称
-				destFile.SetSheetName(destFile.GetSheetName(0), sheet)
-				if err := copyExcelHeaders(srcFile, destFile, sheet, &rows, headerRowEndNum); err != nil {
-					return err
-				}
-
-				d = &ExcelFileWithIndex{File: destFile, Index: headerRowEndNum}
-				destFileMap[colVal] = d
-			}
+	return output.manifest.Save()
+}
 
-			d.Index++
-			if err := d.File.SetSheetRow(sheet, fmt.Sprintf("A%d", d.Index), &row); err != nil {
-				return err
-			}
+// splitKeySepertor is the sepertor of column values in the group key of multiple columns
+const splitKeySepertor = "\x00"
+
+// splitKeyName return the name of group key used in filenames, the values of multiple columns are joined by -
+func splitKeyName(key string) string {
+	return strings.ReplaceAll(key, splitKeySepertor, "-")
+}
+
+// splitGrouper calculate the group key of data rows when splitting by column
+type splitGrouper struct {
+	// columns is the column numbers (start from 1) to split by
+	columns []int
+	// program is the compiled expression of opt.ByExpr
+	program *vm.Program
+	// headers is the last header row, the columns can be referenced by header in expression
+	headers []string
+}
+
+func newSplitGrouper(opt SplitOption) (*splitGrouper, error) {
+	if opt.ByExpr != "" {
+		if len(opt.By) > 0 || opt.ColumnIndex != "" {
+			return nil, fmt.Errorf("by-expr can not be used with by or column-index")
 		}
 
-		prg.Reset(len(destFileMap), "writing files ...")
+		program, err := expr.Compile(opt.ByExpr, expr.AllowUndefinedVariables())
+		if err != nil {
+			return nil, fmt.Errorf("invalid by-expr: %w", err)
+		}
 
-		for colName, d := range destFileMap {
-			prg.Add(1)
+		return &splitGrouper{program: program}, nil
+	}
 
-			destFilename := fmt.Sprintf("%s.%s.xlsx", strings.TrimSuffix(src, ".xlsx"), colName)
-			if err := d.File.SaveAs(destFilename); err != nil {
-				return err
-			}
+	names := opt.By
+	if len(names) == 0 {
+		names = []string{opt.ColumnIndex}
+	} else if opt.ColumnIndex != "" {
+		log.Warningf("column-index is ignored because by is specified")
+	}
 
-			d.File.Close()
+	columns := make([]int, 0, len(names))
+	for _, name := range names {
+		column, err := excelize.ColumnNameToNumber(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid column %s: %w", name, err)
+		}
 
-			logger.Add(fmt.Sprintf("save file %s", destFilename))
+		columns = append(columns, column)
+	}
+
+	return &splitGrouper{columns: columns}, nil
+}
+
+// Key return the group key of row
+func (g *splitGrouper) Key(cells []reader.Cell) (string, error) {
+	value := func(column int) string {
+		return ternary.IfElseLazy(len(cells) < column, func() string { return "" }, func() string { return cells[column-1].Value })
+	}
+
+	if g.program == nil {
+		return strings.Join(array.Map(g.columns, func(column int, _ int) string { return value(column) }), splitKeySepertor), nil
+	}
+
+	// 列可以通过列名（A、B...）或者表头引用，表头优先
+	env := make(map[string]interface{}, len(cells)+len(g.headers)+1)
+	for i := 1; i <= len(cells) || i <= len(g.headers); i++ {
+		env[splitColumnName(i)] = value(i)
+	}
+
+	// 表头不是合法的标识符时，可以使用 row["表头"] 的形式引用
+	row := make(map[string]interface{}, len(g.headers))
+	for i, header := range g.headers {
+		if header = strings.TrimSpace(header); header != "" {
+			env[header], row[header] = value(i+1), value(i+1)
 		}
 	}
+	env["row"] = row
+
+	res, err := expr.Run(g.program, env)
+	if err != nil {
+		// expr 的错误信息中包含多行的表达式位置提示，只保留第一行
+		return "", fmt.Errorf("evaluate by-expr failed: %s", strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+
+	if res == nil {
+		return "", nil
+	}
 
-	return nil
+	return fmt.Sprintf("%v", res), nil
 }
 
-type ExcelFileWithIndex struct {
-	File  *excelize.File
-	Index int
+// splitColumnName return the column name such as A, AA of column number, which starts from 1
+func splitColumnName(col int) string {
+	name, _ := excelize.ColumnNumberToName(col)
+	return name
 }
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/mylxsw/heimdall/reader"
+)
+
+func TestSplitGrouper(t *testing.T) {
+	row := reader.StringCells([]string{"Tom", "2023-07-15", "a", "x"})
+
+	grouper, err := newSplitGrouper(SplitOption{By: []string{"C", "D"}})
+	assert.NoError(t, err)
+
+	key, err := grouper.Key(row)
+	assert.NoError(t, err)
+	assert.Equal(t, "a-x", splitKeyName(key))
+
+	// 缺失的列值为空
+	key, err = grouper.Key(row[:3])
+	assert.NoError(t, err)
+	assert.Equal(t, "a-", splitKeyName(key))
+
+	grouper, err = newSplitGrouper(SplitOption{ByExpr: "date(B).Year()"})
+	assert.NoError(t, err)
+
+	key, err = grouper.Key(row)
+	assert.NoError(t, err)
+	assert.Equal(t, "2023", key)
+
+	_, err = grouper.Key(reader.StringCells([]string{"Tom", "unknown"}))
+	assert.True(t, err != nil)
+
+	grouper, err = newSplitGrouper(SplitOption{ByExpr: `name + "-" + row["birth day"][:7]`})
+	assert.NoError(t, err)
+	grouper.headers = []string{"name", "birth day"}
+
+	key, err = grouper.Key(row)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tom-2023-07", key)
+
+	_, err = newSplitGrouper(SplitOption{ByExpr: "A", By: []string{"B"}})
+	assert.True(t, err != nil)
+}
+
+func TestParseByteSize(t *testing.T) {
+	for val, expect := range map[string]int64{"": 0, "100": 100, "100B": 100, "20MB": 20 << 20, "1.5k": 1536, "2 GB": 2 << 30} {
+		size, err := parseByteSize(val)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, size)
+	}
+
+	for _, val := range []string{"20XB", "MB", "-1KB"} {
+		_, err := parseByteSize(val)
+		assert.True(t, err != nil)
+	}
+}
@@ -0,0 +1,480 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/xuri/excelize/v2"
+)
+
+// splitRow is a row read from the source file
+type splitRow struct {
+	// Cells is the cells of row, the values are the formatted text
+	Cells []reader.Cell
+	// Format is the row height and the cell styles, it is only available for xlsx files when the split files are xlsx
+	Format *splitRowFormat
+}
+
+// splitRowFormat is the formatting of a row in xlsx file
+type splitRowFormat struct {
+	// Height is the custom height of row, 0 means the default height
+	Height float64
+	// Style is the style id of row
+	Style int
+	// Values is the cells with their style ids (excelize.Cell) or nil, the values are the raw values in source file,
+	// such as the serial numbers of dates, so that the number formats are kept
+	Values []interface{}
+}
+
+// splitSheetFormat is the formatting of the source sheet, which is copied to every split file
+type splitSheetFormat struct {
+	// Merges is the merged cells in the form of [start, end], such as [A1, B2]
+	Merges [][2]string
+	// Cols is the custom widths of columns
+	Cols []splitColWidth
+	// Panes is the frozen panes in the json format of excelize.File.SetPanes, it is empty if no panes are frozen
+	Panes string
+	// AutoFilter is the range of autofilter, such as A2:D100
+	AutoFilter string
+	// Validations is the data validations, such as the dropdown lists
+	Validations []excelize.DataValidation
+
+	// HeaderRows is the number of header rows, the ranges after header rows are adjusted to the data rows of split files
+	HeaderRows int
+	// KeepRows means the rows of split files are the same as the source sheet, all merged cells are copied and the
+	// ranges are not adjusted
+	KeepRows bool
+
+	// styles is the source workbook to copy the cell styles, the theme and the date system from
+	styles *excelize.File
+}
+
+// splitColWidth is the width of columns from Min to Max
+type splitColWidth struct {
+	Min   int
+	Max   int
+	Width float64
+}
+
+// merges return the merged cells to copy to split files, only the merged cells in header rows are copied unless KeepRows
+func (format *splitSheetFormat) merges() [][2]string {
+	if format.KeepRows {
+		return format.Merges
+	}
+
+	return splitHeaderMerges(format.Merges, format.HeaderRows)
+}
+
+// prepare copy the cell styles, the theme, the date system and the frozen panes to the split file, it must be called
+// before creating the stream writer, because the sheet views are written when the stream writer is created
+func (format *splitSheetFormat) prepare(f *excelize.File, sheet string) error {
+	if src := format.styles; src != nil {
+		// 直接使用源文件的样式表，这样单元格中的样式 ID 无需转换
+		f.Styles = src.Styles
+		if theme, ok := src.Pkg.Load("xl/theme/theme1.xml"); ok {
+			f.Pkg.Store("xl/theme/theme1.xml", theme)
+		}
+
+		if src.WorkBook != nil && src.WorkBook.WorkbookPr != nil && src.WorkBook.WorkbookPr.Date1904 {
+			pr := *src.WorkBook.WorkbookPr
+			f.WorkBook.WorkbookPr = &pr
+		}
+	}
+
+	if format.Panes != "" {
+		return f.SetPanes(sheet, format.Panes)
+	}
+
+	return nil
+}
+
+// layout set the column widths and the merged cells by the stream writer, it must be called before writing rows
+func (format *splitSheetFormat) layout(sw *excelize.StreamWriter, sheet string) {
+	for _, col := range format.Cols {
+		if err := sw.SetColWidth(col.Min, col.Max, col.Width); err != nil {
+			log.Warningf("set width of columns %d-%d for %s failed: %v", col.Min, col.Max, sheet, err)
+		}
+	}
+
+	for _, merge := range format.merges() {
+		if err := sw.MergeCell(merge[0], merge[1]); err != nil {
+			log.Warningf("merge cell for %s failed: %v", sheet, err)
+		}
+	}
+}
+
+// finish add the autofilter and the data validations to the split file which has lastRow rows, it must be called
+// before flushing the stream writer
+func (format *splitSheetFormat) finish(f *excelize.File, sheet string, lastRow int) {
+	if format.AutoFilter != "" {
+		ref := format.adjustRange(format.AutoFilter, lastRow)
+		if cells := strings.SplitN(ref, ":", 2); len(cells) == 2 {
+			if err := f.AutoFilter(sheet, cells[0], cells[1], ""); err != nil {
+				log.Warningf("set autofilter %s for %s failed: %v", ref, sheet, err)
+			}
+		}
+	}
+
+	for _, validation := range format.Validations {
+		dv := validation
+		dv.Sqref = strings.Join(array.Map(strings.Fields(dv.Sqref), func(ref string, _ int) string {
+			return format.adjustRange(ref, lastRow)
+		}), " ")
+
+		if err := f.AddDataValidation(sheet, &dv); err != nil {
+			log.Warningf("add data validation %s for %s failed: %v", dv.Sqref, sheet, err)
+		}
+	}
+}
+
+// adjustRange adjust the range such as A2:C100 to the split file which has lastRow rows. The ranges in header rows are
+// kept, and the ranges which reach the data rows are extended to cover all data rows of the split file
+func (format *splitSheetFormat) adjustRange(ref string, lastRow int) string {
+	if format.KeepRows {
+		return ref
+	}
+
+	cells := strings.SplitN(ref, ":", 2)
+	startCol, startRow, err := excelize.SplitCellName(cells[0])
+	if err != nil {
+		return ref
+	}
+
+	endCol, endRow, err := excelize.SplitCellName(cells[len(cells)-1])
+	if err != nil {
+		return ref
+	}
+
+	if endRow <= format.HeaderRows {
+		return ref
+	}
+
+	if startRow > format.HeaderRows {
+		startRow = format.HeaderRows + 1
+	}
+
+	if lastRow < startRow {
+		lastRow = startRow
+	}
+
+	return fmt.Sprintf("%s%d:%s%d", startCol, startRow, endCol, lastRow)
+}
+
+// readXLSXSheetFormat read the merged cells, the column widths, the frozen panes, the autofilter and the data
+// validations of the sheet in xlsx file
+func readXLSXSheetFormat(filePath string, sheet string) (*splitSheetFormat, error) {
+	format := &splitSheetFormat{Merges: make([][2]string, 0)}
+	err := walkXLSXSheet(filePath, sheet, func(decoder *xml.Decoder, elem xml.StartElement) error {
+		switch elem.Name.Local {
+		case "mergeCells":
+			var cells struct {
+				Cells []struct {
+					Ref string `xml:"ref,attr"`
+				} `xml:"mergeCell"`
+			}
+			if err := decoder.DecodeElement(&cells, &elem); err != nil {
+				return err
+			}
+
+			for _, cell := range cells.Cells {
+				if bounds := strings.SplitN(cell.Ref, ":", 2); len(bounds) == 2 {
+					format.Merges = append(format.Merges, [2]string{bounds[0], bounds[1]})
+				}
+			}
+		case "cols":
+			var cols struct {
+				Cols []struct {
+					Min   int     `xml:"min,attr"`
+					Max   int     `xml:"max,attr"`
+					Width float64 `xml:"width,attr"`
+				} `xml:"col"`
+			}
+			if err := decoder.DecodeElement(&cols, &elem); err != nil {
+				return err
+			}
+
+			for _, col := range cols.Cols {
+				if col.Width > 0 && col.Min > 0 {
+					format.Cols = append(format.Cols, splitColWidth{Min: col.Min, Max: col.Max, Width: col.Width})
+				}
+			}
+		case "sheetViews":
+			var views struct {
+				Views []struct {
+					Pane *struct {
+						XSplit      float64 `xml:"xSplit,attr"`
+						YSplit      float64 `xml:"ySplit,attr"`
+						TopLeftCell string  `xml:"topLeftCell,attr"`
+						ActivePane  string  `xml:"activePane,attr"`
+						State       string  `xml:"state,attr"`
+					} `xml:"pane"`
+				} `xml:"sheetView"`
+			}
+			if err := decoder.DecodeElement(&views, &elem); err != nil {
+				return err
+			}
+
+			if len(views.Views) == 0 || views.Views[0].Pane == nil {
+				return nil
+			}
+
+			// 只复制冻结窗格，拆分窗格的位置与窗口大小相关
+			pane := views.Views[0].Pane
+			if pane.State != "frozen" && pane.State != "frozenSplit" {
+				return nil
+			}
+
+			data, err := json.Marshal(map[string]interface{}{
+				"freeze":        true,
+				"x_split":       int(pane.XSplit),
+				"y_split":       int(pane.YSplit),
+				"top_left_cell": pane.TopLeftCell,
+				"active_pane":   pane.ActivePane,
+			})
+			if err != nil {
+				return err
+			}
+
+			format.Panes = string(data)
+		case "autoFilter":
+			var filter struct {
+				Ref string `xml:"ref,attr"`
+			}
+			if err := decoder.DecodeElement(&filter, &elem); err != nil {
+				return err
+			}
+
+			format.AutoFilter = filter.Ref
+		case "dataValidations":
+			var validations struct {
+				Validations []excelize.DataValidation `xml:"dataValidation"`
+			}
+			if err := decoder.DecodeElement(&validations, &elem); err != nil {
+				return err
+			}
+
+			for _, dv := range validations.Validations {
+				// Formula1 和 Formula2 都是 innerxml，保存的是完整的 <formula1>...</formula1><formula2>...</formula2>，
+				// 写入时只需要保留其中一个
+				dv.Formula2 = ""
+				format.Validations = append(format.Validations, dv)
+			}
+		default:
+			return decoder.Skip()
+		}
+
+		return nil
+	})
+
+	return format, err
+}
+
+// xlsxRowFormatReader read the row heights and the cell styles of a sheet in xlsx file in streaming mode, the rows
+// must be read in order
+type xlsxRowFormatReader struct {
+	file    io.ReadCloser
+	decoder *xml.Decoder
+	// next is the row read but not returned yet
+	next   *xlsxRowFormat
+	rowNum int
+	done   bool
+}
+
+// xlsxRowFormat is a row in sheetData of xlsx file
+type xlsxRowFormat struct {
+	num    int
+	height float64
+	style  int
+	cells  []xlsxCellFormat
+}
+
+// xlsxCellFormat is a cell in sheetData of xlsx file, value is the raw value in <v>
+type xlsxCellFormat struct {
+	col   int
+	style int
+	typ   string
+	value string
+}
+
+func newXLSXRowFormatReader(filePath string, sheet string) (*xlsxRowFormatReader, error) {
+	r, err := reader.OpenXLSXSheet(filePath, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxRowFormatReader{file: r, decoder: xml.NewDecoder(bufio.NewReaderSize(r, 64*1024))}, nil
+}
+
+// Row return the formatting of row rowNum, cells is the formatted text of the row, which is used for the cells of
+// shared strings and other text types. It returns nil if the row does not exist in source file
+func (r *xlsxRowFormatReader) Row(rowNum int, cells []reader.Cell) (*splitRowFormat, error) {
+	for !r.done && (r.next == nil || r.next.num < rowNum) {
+		row, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+
+		if row == nil {
+			r.done = true
+			break
+		}
+
+		r.next = row
+	}
+
+	if r.next == nil || r.next.num != rowNum {
+		return nil, nil
+	}
+
+	row := r.next
+	r.next = nil
+
+	size := len(cells)
+	for _, cell := range row.cells {
+		if cell.col > size {
+			size = cell.col
+		}
+	}
+
+	format := &splitRowFormat{Height: row.height, Style: row.style, Values: make([]interface{}, size)}
+	for i, cell := range cells {
+		format.Values[i] = cell.Value
+	}
+
+	for _, cell := range row.cells {
+		if cell.col <= 0 {
+			continue
+		}
+
+		var text string
+		if cell.col <= len(cells) {
+			text = cells[cell.col-1].Value
+		}
+
+		var val interface{}
+		switch cell.typ {
+		case "", "n":
+			// 数值（包括日期的序列号）保存原始值，由单元格的数字格式来决定显示方式
+			if num, err := strconv.ParseFloat(cell.value, 64); err == nil {
+				val = num
+			} else if cell.value != "" {
+				val = text
+			}
+		case "b":
+			val = cell.value == "1" || cell.value == "true"
+		default:
+			if text != "" {
+				val = text
+			}
+		}
+
+		if val == nil && cell.style == 0 {
+			format.Values[cell.col-1] = nil
+			continue
+		}
+
+		format.Values[cell.col-1] = excelize.Cell{StyleID: cell.style, Value: val}
+	}
+
+	return format, nil
+}
+
+// read return the next row in sheetData, it returns nil at the end of sheetData
+func (r *xlsxRowFormatReader) read() (*xlsxRowFormat, error) {
+	var row *xlsxRowFormat
+	var cell *xlsxCellFormat
+	var value strings.Builder
+	inValue := false
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = &xlsxRowFormat{num: r.rowNum + 1}
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "r":
+						if num, err := strconv.Atoi(attr.Value); err == nil {
+							row.num = num
+						}
+					case "ht":
+						row.height, _ = strconv.ParseFloat(attr.Value, 64)
+					case "s":
+						row.style, _ = strconv.Atoi(attr.Value)
+					}
+				}
+				r.rowNum = row.num
+			case "c":
+				if row == nil {
+					continue
+				}
+
+				cell = &xlsxCellFormat{col: len(row.cells) + 1}
+				if len(row.cells) > 0 {
+					cell.col = row.cells[len(row.cells)-1].col + 1
+				}
+
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "r":
+						if col, _, err := excelize.CellNameToCoordinates(attr.Value); err == nil {
+							cell.col = col
+						}
+					case "s":
+						cell.style, _ = strconv.Atoi(attr.Value)
+					case "t":
+						cell.typ = attr.Value
+					}
+				}
+			case "v":
+				inValue = cell != nil
+				value.Reset()
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v":
+				if inValue {
+					cell.value = value.String()
+					inValue = false
+				}
+			case "c":
+				if cell != nil {
+					row.cells = append(row.cells, *cell)
+					cell = nil
+				}
+			case "row":
+				if row != nil {
+					return row, nil
+				}
+			case "sheetData":
+				return nil, nil
+			}
+		}
+	}
+}
+
+func (r *xlsxRowFormatReader) Close() error {
+	return r.file.Close()
+}
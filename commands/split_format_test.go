@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func createSplitFormatTestFile(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "finance.xlsx")
+
+	f := excelize.NewFile()
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	assert.NoError(t, err)
+	date, err := f.NewStyle(&excelize.Style{NumFmt: 14})
+	assert.NoError(t, err)
+	money, err := f.NewStyle(&excelize.Style{NumFmt: 4})
+	assert.NoError(t, err)
+
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"date", "amount", "status"}))
+	assert.NoError(t, f.SetCellStyle("Sheet1", "A1", "C1", bold))
+	assert.NoError(t, f.SetRowHeight("Sheet1", 1, 30))
+	for i := 0; i < 5; i++ {
+		row := i + 2
+		assert.NoError(t, f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{
+			time.Date(2023, 1, i+1, 0, 0, 0, 0, time.UTC), 1234.5 * float64(i+1), "open",
+		}))
+		assert.NoError(t, f.SetCellStyle("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), date))
+		assert.NoError(t, f.SetCellStyle("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("B%d", row), money))
+	}
+
+	assert.NoError(t, f.SetColWidth("Sheet1", "A", "B", 18))
+	assert.NoError(t, f.SetPanes("Sheet1", `{"freeze":true,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`))
+	assert.NoError(t, f.AutoFilter("Sheet1", "A1", "C6", ""))
+
+	dv := excelize.NewDataValidation(true)
+	dv.Sqref = "C2:C6"
+	assert.NoError(t, dv.SetDropList([]string{"open", "closed"}))
+	assert.NoError(t, f.AddDataValidation("Sheet1", dv))
+
+	assert.NoError(t, f.SaveAs(filename))
+	return filename
+}
+
+func TestSplitFormat(t *testing.T) {
+	filename := createSplitFormatTestFile(t)
+	prefix := splitFilePrefix(filename)
+
+	assert.NoError(t, SplitExcelToParts(SplitOption{InputFile: filename, Slient: true, HeaderRowEndNum: 1, PerfileLimit: 3, OutputFormat: splitFormatXLSX}))
+
+	part := prefix + ".part2.xlsx"
+	f, err := excelize.OpenFile(part)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	// 数字格式以及单元格样式
+	rows, err := f.GetRows("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"date", "amount", "status"}, {"01-04-23", "4938.00", "open"}, {"01-05-23", "6172.50", "open"}}, rows)
+
+	style, err := f.GetCellStyle("Sheet1", "B2")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, *f.Styles.CellXfs.Xf[style].NumFmtID)
+
+	style, err = f.GetCellStyle("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.True(t, f.Styles.CellXfs.Xf[style].FontID != nil && *f.Styles.CellXfs.Xf[style].FontID > 0)
+
+	width, err := f.GetColWidth("Sheet1", "B")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(18), width)
+
+	height, err := f.GetRowHeight("Sheet1", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(30), height)
+
+	// 冻结窗格、自动筛选以及数据验证的范围调整为拆分文件中的数据行
+	format, err := readXLSXSheetFormat(part, "Sheet1")
+	assert.NoError(t, err)
+	assert.True(t, format.Panes != "")
+	assert.Equal(t, "$A$1:$C$3", format.AutoFilter)
+	assert.Equal(t, 1, len(format.Validations))
+	assert.Equal(t, "C2:C3", format.Validations[0].Sqref)
+	assert.Equal(t, `<formula1>"open,closed"</formula1>`, format.Validations[0].Formula1)
+}
+
+func TestSplitSheetFormatAdjustRange(t *testing.T) {
+	format := &splitSheetFormat{HeaderRows: 2}
+	assert.Equal(t, "A1:B2", format.adjustRange("A1:B2", 10))
+	assert.Equal(t, "A1:D10", format.adjustRange("A1:D500", 10))
+	assert.Equal(t, "C3:C10", format.adjustRange("C40:C80", 10))
+	assert.Equal(t, "C3:C10", format.adjustRange("C5", 10))
+	assert.Equal(t, "C:C", format.adjustRange("C:C", 10))
+
+	format.KeepRows = true
+	assert.Equal(t, "A1:D500", format.adjustRange("A1:D500", 10))
+}
@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Default name templates of split files, the extension of output format is appended if the name has no extension
+const (
+	splitRowNameTemplate    = "{{.base}}.part{{.n}}"
+	splitColumnNameTemplate = "{{.base}}.{{.key}}"
+	splitSheetNameTemplate  = "{{.base}}.{{.sheet}}"
+)
+
+// splitEmptyName is the name used for empty values in filenames
+const splitEmptyName = "_empty"
+
+// splitMaxNameLength is the maximum length (in bytes) of values in filenames
+const splitMaxNameLength = 200
+
+// splitNamer generate the paths of split files by the name template, the values are sanitized and the duplicated
+// names are suffixed with -2, -3 ...
+type splitNamer struct {
+	dir    string
+	base   string
+	src    string
+	format string
+	tmpl   *template.Template
+	used   map[string]bool
+}
+
+func newSplitNamer(opt SplitOption, defaultTemplate string) (*splitNamer, error) {
+	text := opt.NameTemplate
+	if text == "" {
+		text = defaultTemplate
+	}
+
+	tmpl, err := template.New("name").Funcs(template.FuncMap{
+		"slug":  splitSlug,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name-template: %w", err)
+	}
+
+	dir := opt.OutputDir
+	if dir == "" {
+		dir = filepath.Dir(opt.InputFile)
+	}
+
+	src, _ := filepath.Abs(opt.InputFile)
+	return &splitNamer{
+		dir:    dir,
+		base:   filepath.Base(splitFilePrefix(opt.InputFile)),
+		src:    src,
+		format: opt.OutputFormat,
+		tmpl:   tmpl,
+		used:   make(map[string]bool),
+	}, nil
+}
+
+// Path return the path of split file, key is the group of rows, n is the number of file which starts from 1
+func (namer *splitNamer) Path(key string, n int, sheet string) (string, error) {
+	var buf bytes.Buffer
+	if err := namer.tmpl.Execute(&buf, map[string]interface{}{
+		"base":  sanitizeFilename(namer.base),
+		"key":   sanitizeFilename(key),
+		"n":     n,
+		"sheet": sanitizeFilename(sheet),
+		"ext":   namer.format,
+	}); err != nil {
+		return "", fmt.Errorf("render name-template failed: %w", err)
+	}
+
+	// 模板中可以使用 / 来指定子目录，但是不允许写入到输出目录之外
+	name := filepath.Clean(filepath.FromSlash(strings.TrimSpace(buf.String())))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid filename %s rendered by name-template, it must be a relative path in output directory", buf.String())
+	}
+
+	if !strings.EqualFold(filepath.Ext(name), "."+namer.format) {
+		name += "." + namer.format
+	}
+
+	filename := filepath.Join(namer.dir, name)
+	ext := filepath.Ext(filename)
+	for i := 2; namer.exists(filename); i++ {
+		filename = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(filepath.Join(namer.dir, name), ext), i, ext)
+	}
+
+	namer.used[strings.ToLower(filename)] = true
+	return filename, nil
+}
+
+// exists return whether the filename is used by other split files or the source file, the names are compared
+// case-insensitively, because the file systems of Windows and macOS are case-insensitive
+func (namer *splitNamer) exists(filename string) bool {
+	if namer.used[strings.ToLower(filename)] {
+		return true
+	}
+
+	abs, _ := filepath.Abs(filename)
+	return strings.EqualFold(abs, namer.src)
+}
+
+// sanitizeFilename replace the characters which are invalid in filenames, such as / and :, with _, the empty values
+// are replaced with splitEmptyName
+func sanitizeFilename(val string) string {
+	val = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+
+		return r
+	}, val)
+
+	// Windows 中文件名不能以空格或者 . 结尾，. 和 .. 也不能作为文件名
+	val = strings.TrimRight(strings.TrimSpace(val), ".")
+	if val == "" {
+		return splitEmptyName
+	}
+
+	if len(val) > splitMaxNameLength {
+		val = val[:splitMaxNameLength]
+		for !utf8.ValidString(val) {
+			val = val[:len(val)-1]
+		}
+	}
+
+	return val
+}
+
+// splitSlug convert the value to a slug which only contains lower case letters, digits, _ and -
+func splitSlug(val string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(val) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			if dash && sb.Len() > 0 {
+				sb.WriteRune('-')
+			}
+
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+
+		dash = true
+	}
+
+	if sb.Len() == 0 {
+		return splitEmptyName
+	}
+
+	return sb.String()
+}
+
+// splitManifest is the list of split files, which is written to manifest.json
+type splitManifest struct {
+	path   string
+	dir    string
+	Source string              `json:"source"`
+	Parts  []splitManifestPart `json:"parts"`
+}
+
+type splitManifestPart struct {
+	// File is the path of split file relative to the directory of manifest
+	File  string `json:"file"`
+	Key   string `json:"key"`
+	Sheet string `json:"sheet"`
+	Rows  int    `json:"rows"`
+	Size  int64  `json:"size"`
+	// SHA256 is the sha256 checksum of split file in hex
+	SHA256 string `json:"sha256"`
+}
+
+// newSplitManifest create a manifest for split files, it is saved as manifest.json in the output directory, or as
+// NAME.manifest.json beside the source file when the output directory is not specified
+func newSplitManifest(opt SplitOption) *splitManifest {
+	path := filepath.Join(opt.OutputDir, "manifest.json")
+	if opt.OutputDir == "" {
+		path = splitFilePrefix(opt.InputFile) + ".manifest.json"
+	}
+
+	return &splitManifest{path: path, dir: filepath.Dir(path), Source: opt.InputFile, Parts: make([]splitManifestPart, 0)}
+}
+
+// Add add a saved split file to manifest
+func (manifest *splitManifest) Add(part *splitPart) error {
+	f, err := os.Open(part.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+
+	file, err := filepath.Rel(manifest.dir, part.filename)
+	if err != nil {
+		file = part.filename
+	}
+
+	manifest.Parts = append(manifest.Parts, splitManifestPart{
+		File:   filepath.ToSlash(file),
+		Key:    part.key,
+		Sheet:  part.sheet,
+		Rows:   part.Rows,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	})
+
+	return nil
+}
+
+// Save write the manifest to file
+func (manifest *splitManifest) Save() error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(manifest.path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestSplitNamer(t *testing.T) {
+	dir := t.TempDir()
+	namer, err := newSplitNamer(SplitOption{
+		InputFile:    filepath.Join(dir, "data.xlsx"),
+		OutputDir:    filepath.Join(dir, "out"),
+		OutputFormat: splitFormatXLSX,
+		NameTemplate: "{{.base}}-{{.key|slug}}-{{.n}}.xlsx",
+	}, splitRowNameTemplate)
+	assert.NoError(t, err)
+
+	for _, c := range []struct {
+		key    string
+		n      int
+		expect string
+	}{
+		{key: "North/East: 华东", n: 1, expect: "data-north_east_-华东-1.xlsx"},
+		{key: "", n: 2, expect: "data-_empty-2.xlsx"},
+		{key: "A", n: 3, expect: "data-a-3.xlsx"},
+		// 重复的文件名添加序号，文件名不区分大小写
+		{key: "a", n: 3, expect: "data-a-3-2.xlsx"},
+		{key: "A", n: 3, expect: "data-a-3-3.xlsx"},
+	} {
+		filename, err := namer.Path(c.key, c.n, "Sheet1")
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "out", c.expect), filename)
+	}
+
+	// 默认模板与源文件同名时不会覆盖源文件
+	namer, err = newSplitNamer(SplitOption{InputFile: filepath.Join(dir, "data.csv"), OutputFormat: splitFormatCSV}, "{{.base}}")
+	assert.NoError(t, err)
+
+	filename, err := namer.Path("", 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "data-2.csv"), filename)
+
+	namer, err = newSplitNamer(SplitOption{InputFile: filepath.Join(dir, "data.csv"), OutputFormat: splitFormatCSV, NameTemplate: "../{{.key}}"}, "")
+	assert.NoError(t, err)
+
+	_, err = namer.Path("..", 1, "")
+	assert.True(t, err != nil)
+
+	assert.Equal(t, "_empty", sanitizeFilename(" .. "))
+	assert.Equal(t, "a_b_c", sanitizeFilename("a/b\\c"))
+	assert.Equal(t, "hello-world", splitSlug(" Hello, World! "))
+}
+
+func TestSplitManifest(t *testing.T) {
+	filename := createSplitTestFile(t, 150)
+	outputDir := filepath.Join(t.TempDir(), "parts")
+
+	assert.NoError(t, SplitExcelByColumn(SplitOption{
+		InputFile:       filename,
+		Slient:          true,
+		HeaderRowEndNum: 2,
+		By:              []string{"B"},
+		OutputFormat:    splitFormatXLSX,
+		OutputDir:       outputDir,
+		NameTemplate:    "{{.key}}/{{.base}}-{{.n}}",
+	}))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "manifest.json"))
+	assert.NoError(t, err)
+
+	var manifest splitManifest
+	assert.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, 80, len(manifest.Parts))
+	assert.Equal(t, "g0/data-1.xlsx", manifest.Parts[0].File)
+	assert.Equal(t, "g0", manifest.Parts[0].Key)
+	assert.Equal(t, 2, manifest.Parts[0].Rows)
+	assert.Equal(t, 64, len(manifest.Parts[0].SHA256))
+
+	info, err := os.Stat(filepath.Join(outputDir, "g0", "data-1.xlsx"))
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), manifest.Parts[0].Size)
+}
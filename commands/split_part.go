@@ -0,0 +1,586 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/xuri/excelize/v2"
+)
+
+// Supported formats of split files
+const (
+	splitFormatXLSX = "xlsx"
+	splitFormatCSV  = "csv"
+)
+
+// splitOutput is the format of split files
+type splitOutput struct {
+	// Format is the format of split files, xlsx or csv
+	Format string
+	// CSV is the options for writing csv files
+	CSV render.CSVOptions
+	// Encoding is the encoding of csv files, default is utf-8
+	Encoding string
+
+	namer    *splitNamer
+	manifest *splitManifest
+}
+
+// splitRecord is a row to write to split files
+type splitRecord struct {
+	Values []interface{}
+	// Height is the custom height of row, 0 means the default height
+	Height float64
+	// Style is the style id of row
+	Style int
+}
+
+// record return the row to write, the formatted text is written to csv files. For xlsx files, the cells of xlsx source
+// files keep their raw values and styles, and the numbers and dates of xls and ods files keep their types
+func (output splitOutput) record(row splitRow) splitRecord {
+	if output.Format == splitFormatCSV {
+		return splitRecord{Values: array.Map(row.Cells, func(cell reader.Cell, _ int) interface{} { return cell.Value })}
+	}
+
+	if row.Format != nil {
+		return splitRecord{Values: row.Format.Values, Height: row.Format.Height, Style: row.Format.Style}
+	}
+
+	return splitRecord{Values: array.Map(row.Cells, func(cell reader.Cell, _ int) interface{} { return cell.Interface() })}
+}
+
+// newPart create a split file and write the header rows to it, the file is named by the key of rows and the number of
+// file (start from 1), format is the formatting of source sheet, which is ignored by csv files
+func (output splitOutput) newPart(key string, n int, sheet string, headers []splitRecord, format *splitSheetFormat) (*splitPart, error) {
+	filename, err := output.namer.Path(key, n, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	var writer splitPartWriter
+	if output.Format == splitFormatCSV {
+		writer, err = newCSVPartWriter(filename, output)
+	} else {
+		writer, err = newXLSXPartWriter(filename, sheet, format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	part := &splitPart{filename: filename, key: key, sheet: sheet, writer: writer, output: output, Size: output.initialSize()}
+	for _, row := range headers {
+		if err := part.write(row); err != nil {
+			_ = writer.Close()
+			return nil, err
+		}
+	}
+
+	return part, nil
+}
+
+// save save the split file and add it to manifest
+func (output splitOutput) save(logger *Logger, part *splitPart) error {
+	if err := part.Save(); err != nil {
+		return err
+	}
+
+	logger.Add(fmt.Sprintf("save file %s", part.filename))
+	return output.manifest.Add(part)
+}
+
+// splitPart is a split file written in streaming mode, the rows are written in order
+type splitPart struct {
+	filename string
+	key      string
+	sheet    string
+	writer   splitPartWriter
+	output   splitOutput
+	rowNum   int
+	// Rows is the number of data rows, the header rows are not included
+	Rows int
+	// Size is the estimated size of file, which is the serialized size of rows before compression
+	Size int64
+}
+
+// Add write a data row
+func (part *splitPart) Add(row splitRecord) error {
+	part.Rows++
+	return part.write(row)
+}
+
+// RowSize return the estimated size of writing row to the file
+func (part *splitPart) RowSize(row splitRecord) int64 {
+	return part.output.rowSize(part.rowNum+1, row)
+}
+
+func (part *splitPart) write(row splitRecord) error {
+	part.Size += part.RowSize(row)
+	part.rowNum++
+	return part.writer.Write(row)
+}
+
+// Save flush the rows and save the file
+func (part *splitPart) Save() error {
+	return part.writer.Save()
+}
+
+// Close discard the split file without saving
+func (part *splitPart) Close() error {
+	return part.writer.Close()
+}
+
+// xlsxPackageSize is the estimated size of the parts of xlsx file except the rows, such as the theme, styles and
+// the root element of worksheet
+const xlsxPackageSize = 22 * 1024
+
+// initialSize return the estimated size of an empty split file
+func (output splitOutput) initialSize() int64 {
+	if output.Format == splitFormatCSV {
+		return ternary.If(output.CSV.NoBOM, int64(0), int64(3))
+	}
+
+	return xlsxPackageSize
+}
+
+// rowSize return the estimated size of writing row to the split file, rowNum is the row number of the row in file.
+// The size of xlsx rows is the size of xml in worksheet before compression, so xlsx files are usually much smaller
+// than the estimated size, and the size of csv rows is the size in utf-8 encoding
+func (output splitOutput) rowSize(rowNum int, row splitRecord) int64 {
+	if output.Format == splitFormatCSV {
+		size := int64(ternary.If(output.CSV.UseCRLF, 2, 1))
+		for i, val := range row.Values {
+			field := ternary.IfElseLazy(val == nil, func() string { return "" }, func() string { return fmt.Sprintf("%v", val) })
+			size += int64(len(field) + ternary.If(i > 0, 1, 0))
+			if output.CSV.QuoteAll || strings.ContainsAny(field, "\"\r\n"+string(output.CSV.Comma)) || strings.HasPrefix(field, " ") {
+				size += int64(2 + strings.Count(field, `"`))
+			}
+		}
+
+		return size
+	}
+
+	// <row r="1"></row>，每个单元格为 <c r="A1" t="str"><v>...</v></c>
+	rowRef := int64(len(strconv.Itoa(rowNum)))
+	size := 16 + rowRef
+	if row.Height > 0 || row.Style > 0 {
+		size += 48
+	}
+
+	for i, val := range row.Values {
+		var n int
+		// 带样式的单元格增加 s="..." 属性
+		if cell, ok := val.(excelize.Cell); ok {
+			val, n = cell.Value, 4+len(strconv.Itoa(cell.StyleID))
+			if val == nil {
+				size += 16 + int64(columnNameLen(i+1)) + rowRef + int64(n)
+				continue
+			}
+		}
+
+		switch v := val.(type) {
+		case nil:
+			continue
+		case string:
+			if v == "" {
+				continue
+			}
+
+			n += len(v) + strings.Count(v, "&")*4 + strings.Count(v, "<")*3 + strings.Count(v, ">")*3 + strings.Count(v, `"`)*5
+		case time.Time:
+			// 日期保存为序列号以及样式
+			n += 24
+		default:
+			n += len(fmt.Sprintf("%v", v))
+		}
+
+		size += 27 + int64(columnNameLen(i+1)) + rowRef + int64(n)
+	}
+
+	return size
+}
+
+// columnNameLen return the length of column name such as A, AA of column number, which starts from 1
+func columnNameLen(col int) int {
+	switch {
+	case col <= 26:
+		return 1
+	case col <= 26+26*26:
+		return 2
+	}
+
+	return 3
+}
+
+// splitPartWriter write the rows of split file
+type splitPartWriter interface {
+	Write(row splitRecord) error
+	// Save flush the rows and save the file
+	Save() error
+	// Close discard the file, the content written is not saved
+	Close() error
+}
+
+// xlsxPartWriter write the rows to a xlsx file by the stream writer of excelize
+type xlsxPartWriter struct {
+	filename string
+	sheet    string
+	file     *excelize.File
+	writer   *excelize.StreamWriter
+	format   *splitSheetFormat
+	rowNum   int
+}
+
+func newXLSXPartWriter(filename string, sheet string, format *splitSheetFormat) (*xlsxPartWriter, error) {
+	f := excelize.NewFile()
+	// 重命名默认的 Sheet 名称为 src 文件中的名称
+	f.SetSheetName(f.GetSheetName(0), sheet)
+
+	if err := format.prepare(f, sheet); err != nil {
+		log.Warningf("copy formatting for %s failed: %v", sheet, err)
+	}
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	format.layout(sw, sheet)
+	return &xlsxPartWriter{filename: filename, sheet: sheet, file: f, writer: sw, format: format}, nil
+}
+
+func (w *xlsxPartWriter) Write(row splitRecord) error {
+	w.rowNum++
+	axis, err := excelize.CoordinatesToCellName(1, w.rowNum)
+	if err != nil {
+		return err
+	}
+
+	if row.Height > 0 || row.Style > 0 {
+		return w.writer.SetRow(axis, row.Values, excelize.RowOpts{Height: row.Height, StyleID: row.Style})
+	}
+
+	return w.writer.SetRow(axis, row.Values)
+}
+
+func (w *xlsxPartWriter) Save() error {
+	defer w.file.Close()
+
+	// 自动筛选和数据验证的范围与文件的行数有关，在写入全部行之后才能确定
+	w.format.finish(w.file, w.sheet, w.rowNum)
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+
+	return w.file.SaveAs(w.filename)
+}
+
+func (w *xlsxPartWriter) Close() error {
+	return w.file.Close()
+}
+
+// csvPartWriter write the rows to a csv file directly
+type csvPartWriter struct {
+	filename string
+	file     *os.File
+	buf      *bufio.Writer
+	encoder  io.WriteCloser
+	writer   render.CSVWriter
+}
+
+func newCSVPartWriter(filename string, output splitOutput) (*csvPartWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(f)
+	encoder, err := wrapOutputEncoding(buf, splitFormatCSV, output.Encoding)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(filename)
+		return nil, err
+	}
+
+	if !output.CSV.NoBOM {
+		if _, err := encoder.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			_ = f.Close()
+			_ = os.Remove(filename)
+			return nil, err
+		}
+	}
+
+	return &csvPartWriter{filename: filename, file: f, buf: buf, encoder: encoder, writer: render.NewCSVWriter(encoder, output.CSV)}, nil
+}
+
+func (w *csvPartWriter) Write(row splitRecord) error {
+	return w.writer.Write(array.Map(row.Values, func(val interface{}, _ int) string {
+		if val == nil {
+			return ""
+		}
+
+		return fmt.Sprintf("%v", val)
+	}))
+}
+
+func (w *csvPartWriter) Save() error {
+	// 写入失败时 bufio.Writer 会保留错误，在最后 Flush 时返回
+	w.writer.Flush()
+	if err := w.encoder.Close(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+
+	if err := w.buf.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+
+	return w.file.Close()
+}
+
+func (w *csvPartWriter) Close() error {
+	_ = w.file.Close()
+	return os.Remove(w.filename)
+}
+
+// splitHeaderMerges return the merged cells in the header rows
+func splitHeaderMerges(merges [][2]string, headerRowEndNum int) [][2]string {
+	return array.Filter(merges, func(merge [2]string, _ int) bool {
+		_, startRowNum, _ := excelize.SplitCellName(merge[0])
+		_, endRowNum, _ := excelize.SplitCellName(merge[1])
+
+		return startRowNum <= headerRowEndNum && endRowNum <= headerRowEndNum
+	})
+}
+
+// walkSplitRows read the rows of sheet, the first headerRowEndNum rows are passed to headerCB and the others to dataCB,
+// the empty rows at the end of sheet are ignored
+func walkSplitRows(
+	source splitSource,
+	sheet string,
+	headerRowEndNum int,
+	headerCB func(row splitRow) error,
+	dataCB func(row splitRow) error,
+) error {
+	emptyRows := make([]splitRow, 0)
+	return source.Rows(sheet, func(rowNum int, row splitRow) (bool, error) {
+		if rowNum <= headerRowEndNum {
+			return false, headerCB(row)
+		}
+
+		// 空行只有在后面还有数据时才写入
+		if len(row.Cells) == 0 {
+			emptyRows = append(emptyRows, row)
+			return false, nil
+		}
+
+		for _, empty := range emptyRows {
+			if err := dataCB(empty); err != nil {
+				return false, err
+			}
+		}
+		emptyRows = emptyRows[:0]
+
+		return false, dataCB(row)
+	})
+}
+
+// splitMaxOpenFiles is the maximum number of temporary files opened at the same time when splitting by column
+const splitMaxOpenFiles = 64
+
+// splitSpill keep the rows of each group in temporary files, the split files are written one by one after all rows are
+// read, so that the memory and the number of open files are bounded no matter how many groups there are
+type splitSpill struct {
+	dir    string
+	keys   []string
+	groups map[string]*spillGroup
+	// opened is the groups whose file is open, the most recently used one is at the end
+	opened []*spillGroup
+}
+
+type spillGroup struct {
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newSplitSpill() (*splitSpill, error) {
+	dir, err := os.MkdirTemp("", "heimdall-split-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &splitSpill{dir: dir, groups: make(map[string]*spillGroup)}, nil
+}
+
+// Keys return the keys of groups in the order of first appearance
+func (spill *splitSpill) Keys() []string {
+	return spill.keys
+}
+
+// Add append a row to the group
+func (spill *splitSpill) Add(key string, row splitRecord) error {
+	group, ok := spill.groups[key]
+	if !ok {
+		group = &spillGroup{path: filepath.Join(spill.dir, fmt.Sprintf("%d.jsonl", len(spill.keys)))}
+		spill.groups[key] = group
+		spill.keys = append(spill.keys, key)
+	}
+
+	if err := spill.open(group); err != nil {
+		return err
+	}
+
+	return group.encoder.Encode(spillRecord{
+		Values: array.Map(row.Values, func(val interface{}, _ int) interface{} { return encodeSpillValue(val) }),
+		Height: row.Height,
+		Style:  row.Style,
+	})
+}
+
+// spillRecord is the json form of splitRecord in temporary files
+type spillRecord struct {
+	Values []interface{} `json:"v"`
+	Height float64       `json:"h,omitempty"`
+	Style  int           `json:"s,omitempty"`
+}
+
+// encodeSpillValue convert the value to json value, the types which can not be represented by json directly are
+// converted to objects, so that they are different from strings
+func encodeSpillValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case time.Time:
+		return map[string]interface{}{"time": v.Format(time.RFC3339Nano)}
+	case excelize.Cell:
+		return map[string]interface{}{"style": v.StyleID, "value": encodeSpillValue(v.Value)}
+	}
+
+	return val
+}
+
+// decodeSpillValue convert the json value back to the value encoded by encodeSpillValue
+func decodeSpillValue(val interface{}) interface{} {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return val
+	}
+
+	if style, ok := obj["style"].(float64); ok {
+		return excelize.Cell{StyleID: int(style), Value: decodeSpillValue(obj["value"])}
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, fmt.Sprintf("%v", obj["time"])); err == nil {
+		return t
+	}
+
+	return val
+}
+
+// open open the file of group for appending, the least recently used file is closed if too many files are open
+func (spill *splitSpill) open(group *spillGroup) error {
+	if group.file != nil {
+		if spill.opened[len(spill.opened)-1] != group {
+			spill.opened = append(array.Filter(spill.opened, func(g *spillGroup, _ int) bool { return g != group }), group)
+		}
+
+		return nil
+	}
+
+	if len(spill.opened) >= splitMaxOpenFiles {
+		if err := spill.opened[0].close(); err != nil {
+			return err
+		}
+
+		spill.opened = spill.opened[1:]
+	}
+
+	f, err := os.OpenFile(group.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	group.file, group.writer = f, bufio.NewWriter(f)
+	group.encoder = json.NewEncoder(group.writer)
+	spill.opened = append(spill.opened, group)
+
+	return nil
+}
+
+func (group *spillGroup) close() error {
+	if group.file == nil {
+		return nil
+	}
+
+	defer func() { group.file, group.writer, group.encoder = nil, nil, nil }()
+	if err := group.writer.Flush(); err != nil {
+		_ = group.file.Close()
+		return err
+	}
+
+	return group.file.Close()
+}
+
+// Rows call fn with each row of the group in order
+func (spill *splitSpill) Rows(key string, fn func(row splitRecord) error) error {
+	group, ok := spill.groups[key]
+	if !ok {
+		return nil
+	}
+
+	if err := group.close(); err != nil {
+		return err
+	}
+	spill.opened = array.Filter(spill.opened, func(g *spillGroup, _ int) bool { return g != group })
+
+	f, err := os.Open(group.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var row spillRecord
+		if err := decoder.Decode(&row); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		values := array.Map(row.Values, func(val interface{}, _ int) interface{} { return decodeSpillValue(val) })
+		if err := fn(splitRecord{Values: values, Height: row.Height, Style: row.Style}); err != nil {
+			return err
+		}
+	}
+}
+
+// Close close all files and remove the temporary directory
+func (spill *splitSpill) Close() error {
+	for _, group := range spill.opened {
+		_ = group.close()
+	}
+
+	return os.RemoveAll(spill.dir)
+}
@@ -2,10 +2,8 @@ package commands
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/mylxsw/asteria/log"
-	"github.com/xuri/excelize/v2"
 )
 
 // NumRange 数字范围
@@ -31,70 +29,99 @@ func SplitNumToRange(total int, per int) []NumRange {
 	return ranges
 }
 
-// SplitExcelToParts 将 Excel 文件拆分为多个文件，每个文件的记录数不超过 perFileRecordCount
-func SplitExcelToParts(slient bool, src string, headerRowEndNum, perFileRecordCount int) error {
-	prg := NewProgressbar(!slient, "opening file ...")
+// SplitExcelToParts 将 Excel 或者 csv 文件拆分为多个文件，每个文件的记录数不超过 opt.PerfileLimit，
+// 指定了 opt.MaxSize 时，每个文件的预估大小也不超过 opt.MaxSize
+//
+// 源文件按行流式读取，拆分后的文件也以流式写入，同一时间只有一个拆分文件处于打开状态
+func SplitExcelToParts(opt SplitOption) error {
+	output, err := opt.output(splitRowNameTemplate)
+	if err != nil {
+		return err
+	}
+
+	prg := NewProgressbar(!opt.Slient, "opening file ...")
 	defer prg.Close()
 
-	srcFile, err := excelize.OpenFile(src)
+	source, err := openSplitSource(opt.InputFile, opt.ReaderOption, output.Format == splitFormatXLSX)
 	if err != nil {
 		return err
 	}
-	defer srcFile.Close()
+	defer source.Close()
 
 	logger := NewLogger()
 	defer logger.Flush()
 
-	prg.Reset(0, "loading ...")
-
-	for i, sheet := range srcFile.GetSheetList() {
-		rows, err := srcFile.GetRows(sheet)
-		if err != nil {
-			return err
-		}
-
-		if i > 0 && len(rows) > 0 {
-			log.Warningf("file has more than one sheet, only the first sheet will be processed")
-			break
-		}
-
-		if len(rows) < headerRowEndNum+1 {
-			continue
-		}
-
-		prg.Reset(len(rows[headerRowEndNum:]), "processing ...")
+	sheets := source.Sheets()
+	if len(sheets) == 0 {
+		return nil
+	}
 
-		for j, rang := range SplitNumToRange(len(rows)-headerRowEndNum, perFileRecordCount) {
-			if err := func(j int, rang NumRange) error {
-				destFile := excelize.NewFile()
-				defer destFile.Close()
+	if len(sheets) > 1 {
+		log.Warningf("file has more than one sheet, only the first sheet will be processed")
+	}
 
-				// 重命名默认的 Sheet 名称为 src 文件中的名称
-				destFile.SetSheetName(destFile.GetSheetName(0), sheet)
-				if err := copyExcelHeaders(srcFile, destFile, sheet, &rows, headerRowEndNum); err != nil {
+	sheet := sheets[0]
+	format, err := source.Format(sheet)
+	if err != nil {
+		return fmt.Errorf("read formatting of sheet %s failed: %w", sheet, err)
+	}
+	format.HeaderRows = opt.HeaderRowEndNum
+
+	prg.Reset(-1, "processing ...")
+
+	headers := make([]splitRecord, 0)
+	var part *splitPart
+	parts := 0
+	if err := walkSplitRows(
+		source, sheet, opt.HeaderRowEndNum,
+		func(row splitRow) error {
+			headers = append(headers, output.record(row))
+			return nil
+		},
+		func(row splitRow) error {
+			prg.Add(1)
+			record := output.record(row)
+
+			// 写入当前行后超过文件大小限制时，先保存当前文件，当前行写入下一个文件，每个文件至少包含一行数据
+			if part != nil && opt.MaxSize > 0 && part.Rows > 0 && part.Size+part.RowSize(record) > opt.MaxSize {
+				if err := output.save(logger, part); err != nil {
 					return err
 				}
 
-				for i, row := range rows[rang.Start+headerRowEndNum : rang.Start+headerRowEndNum+rang.Count] {
-					prg.Add(1)
-					if err := destFile.SetSheetRow(sheet, fmt.Sprintf("A%d", headerRowEndNum+i+1), &row); err != nil {
-						return err
-					}
-				}
+				part = nil
+			}
 
-				destFilename := fmt.Sprintf("%s.part%d.xlsx", strings.TrimSuffix(src, ".xlsx"), j+1)
-				if err := destFile.SaveAs(destFilename); err != nil {
+			if part == nil {
+				parts++
+				if part, err = output.newPart("", parts, sheet, headers, format); err != nil {
 					return err
 				}
+			}
 
-				logger.Add(fmt.Sprintf("save file %s", destFilename))
+			if err := part.Add(record); err != nil {
+				return err
+			}
 
+			if opt.PerfileLimit <= 0 || part.Rows < opt.PerfileLimit {
 				return nil
-			}(j, rang); err != nil {
-				return err
 			}
+
+			defer func() { part = nil }()
+			return output.save(logger, part)
+		},
+	); err != nil {
+		if part != nil {
+			_ = part.Close()
+		}
+
+		return err
+	}
+
+	if part != nil {
+		if err := output.save(logger, part); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	return output.manifest.Save()
 }
@@ -2,66 +2,64 @@ package commands
 
 import (
 	"fmt"
-	"strings"
-
-	"github.com/xuri/excelize/v2"
 )
 
-// SplitExcelBySheets 按照 Sheets 拆分 Excel 为多个文件
-func SplitExcelBySheets(slient bool, src string) error {
-	prg := NewProgressbar(!slient, "opening file ...")
+// SplitExcelBySheets 按照 Sheets 拆分 Excel 为多个文件，csv 文件只有一个 Sheet
+func SplitExcelBySheets(opt SplitOption) error {
+	output, err := opt.output(splitSheetNameTemplate)
+	if err != nil {
+		return err
+	}
+
+	prg := NewProgressbar(!opt.Slient, "opening file ...")
 	defer prg.Close()
 
-	srcFile, err := excelize.OpenFile(src)
+	source, err := openSplitSource(opt.InputFile, opt.ReaderOption, output.Format == splitFormatXLSX)
 	if err != nil {
 		return err
 	}
-	defer srcFile.Close()
+	defer source.Close()
 
 	logger := NewLogger()
 	defer logger.Flush()
 
-	for _, sheet := range srcFile.GetSheetList() {
-		prg.Reset(0, fmt.Sprintf("loading sheet %s ...", sheet))
+	parts := 0
+	for _, sheet := range source.Sheets() {
+		prg.Reset(-1, fmt.Sprintf("processing sheet %s ...", sheet))
 
-		rows, err := srcFile.GetRows(sheet)
+		// 按照 Sheet 拆分时行号不变，合并单元格等全部复制
+		format, err := source.Format(sheet)
 		if err != nil {
-			return err
-		}
-
-		if len(rows) == 0 {
-			continue
+			return fmt.Errorf("read formatting of sheet %s failed: %w", sheet, err)
 		}
-
-		prg.Reset(len(rows), fmt.Sprintf("processing sheet %s ...", sheet))
-
-		if err := func(sheet string, rows [][]string) error {
-
-			destFile := excelize.NewFile()
-			defer destFile.Close()
-
-			// 重命名默认的 Sheet 名称为 src 文件中的名称
-			destFile.SetSheetName(destFile.GetSheetName(0), sheet)
-
-			for i, row := range rows {
-				prg.Add(1)
-				if err := destFile.SetSheetRow(sheet, fmt.Sprintf("A%d", i+1), &row); err != nil {
+		format.KeepRows = true
+
+		// 空的 Sheet 不生成文件，因此在读取到第一行数据时才创建文件
+		var part *splitPart
+		if err := walkSplitRows(source, sheet, 0, nil, func(row splitRow) error {
+			prg.Add(1)
+			if part == nil {
+				parts++
+				if part, err = output.newPart(sheet, parts, sheet, nil, format); err != nil {
 					return err
 				}
 			}
 
-			destFilename := fmt.Sprintf("%s.%s.xlsx", strings.TrimSuffix(src, ".xlsx"), sheet)
-			if err := destFile.SaveAs(destFilename); err != nil {
-				return err
+			return part.Add(output.record(row))
+		}); err != nil {
+			if part != nil {
+				_ = part.Close()
 			}
 
-			logger.Add(fmt.Sprintf("save file %s", destFilename))
-
-			return nil
-		}(sheet, rows); err != nil {
 			return err
 		}
+
+		if part != nil {
+			if err := output.save(logger, part); err != nil {
+				return err
+			}
+		}
 	}
 
-	return nil
+	return output.manifest.Save()
 }
@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/xuri/excelize/v2"
+)
+
+// splitSource is the spreadsheet to split, the rows are read in streaming mode, so that large files can be split with
+// low memory
+type splitSource interface {
+	// Sheets return the names of all sheets in order
+	Sheets() []string
+	// Rows call fn with each row of the sheet in order, rowNum starts from 1, it stops when fn returns true or an error
+	Rows(sheet string, fn func(rowNum int, row splitRow) (stop bool, err error)) error
+	// Format return the formatting of the sheet which is copied to split files, such as the merged cells
+	Format(sheet string) (*splitSheetFormat, error)
+	Close() error
+}
+
+// openSplitSource open the file for splitting, the xlsx files are read by the rows iterator of excelize, the xls and
+// ods files are read by reader.Workbook, and the csv files are read record by record with the csv options of opt.
+// If styled is true, the row heights and the cell styles of xlsx files are read as well
+func openSplitSource(src string, opt reader.Options, styled bool) (splitSource, error) {
+	if reader.IsCSV(src, opt) {
+		if _, err := os.Stat(src); err != nil {
+			return nil, err
+		}
+
+		return csvSplitSource{path: src, opt: opt}, nil
+	}
+
+	if reader.IsWorkbook(src, opt) {
+		wb, err := reader.OpenWorkbook(src, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		return workbookSplitSource{Workbook: wb}, nil
+	}
+
+	f, err := excelize.OpenFile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxSplitSource{path: src, file: f, styled: styled}, nil
+}
+
+// workbookSplitSource is a xls or ods file to split, the cells are written to split files with their types
+type workbookSplitSource struct {
+	reader.Workbook
+}
+
+func (s workbookSplitSource) Rows(sheet string, fn func(rowNum int, row splitRow) (bool, error)) error {
+	return s.Workbook.Rows(sheet, func(rowNum int, row []reader.Cell) (bool, error) {
+		return fn(rowNum, splitRow{Cells: row})
+	})
+}
+
+// Format return nothing, because the formatting of xls and ods files is not copied
+func (s workbookSplitSource) Format(sheet string) (*splitSheetFormat, error) {
+	return &splitSheetFormat{}, nil
+}
+
+// csvSplitSheet is the sheet name of csv files, which is used as the sheet name of xlsx split files
+const csvSplitSheet = "Sheet1"
+
+// csvSplitSource is a csv file to split, it has only one sheet named csvSplitSheet
+type csvSplitSource struct {
+	path string
+	opt  reader.Options
+}
+
+func (s csvSplitSource) Sheets() []string {
+	return []string{csvSplitSheet}
+}
+
+func (s csvSplitSource) Rows(sheet string, fn func(rowNum int, row splitRow) (bool, error)) error {
+	return reader.WalkCSVFile(s.path, s.opt, func(index int, record []string) (bool, error) {
+		return fn(index, splitRow{Cells: reader.StringCells(record)})
+	})
+}
+
+// Format return nothing, because csv files have no formatting
+func (s csvSplitSource) Format(sheet string) (*splitSheetFormat, error) {
+	return &splitSheetFormat{}, nil
+}
+
+func (s csvSplitSource) Close() error {
+	return nil
+}
+
+// xlsxSplitSource is a xlsx file to split, the cells are written to split files as the formatted text, or as the raw
+// values with their styles if styled is true
+type xlsxSplitSource struct {
+	path   string
+	file   *excelize.File
+	styled bool
+}
+
+func (s *xlsxSplitSource) Sheets() []string {
+	return s.file.GetSheetList()
+}
+
+func (s *xlsxSplitSource) Rows(sheet string, fn func(rowNum int, row splitRow) (bool, error)) error {
+	rows, err := s.file.Rows(sheet)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// 样式和行高由另外一个解析器同步读取，excelize 的迭代器只提供格式化后的文本
+	var formats *xlsxRowFormatReader
+	if s.styled {
+		if formats, err = newXLSXRowFormatReader(s.path, sheet); err != nil {
+			return err
+		}
+		defer formats.Close()
+	}
+
+	// 迭代器会为不存在的行（空行）返回空的结果，因此行号与迭代次数一致
+	for rowNum := 1; rows.Next(); rowNum++ {
+		cols, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+
+		row := splitRow{Cells: reader.StringCells(cols)}
+		if formats != nil {
+			if row.Format, err = formats.Row(rowNum, row.Cells); err != nil {
+				return fmt.Errorf("read styles of row %d failed: %w", rowNum, err)
+			}
+		}
+
+		stop, err := fn(rowNum, row)
+		if err != nil {
+			return err
+		}
+
+		if stop {
+			break
+		}
+	}
+
+	return rows.Error()
+}
+
+func (s *xlsxSplitSource) Format(sheet string) (*splitSheetFormat, error) {
+	format, err := readXLSXSheetFormat(s.path, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.styled {
+		format.styles = s.file
+	}
+
+	return format, nil
+}
+
+func (s *xlsxSplitSource) Close() error {
+	return s.file.Close()
+}
+
+// walkXLSXSheet call fn with each child element of the worksheet in xlsx file except sheetData, fn must consume the
+// element. The namespace of elements is not resolved, only the local name should be used
+//
+// The rows in sheetData are skipped by scanning bytes instead of parsing xml, which is much faster for large sheets
+func walkXLSXSheet(filePath string, sheet string, fn func(decoder *xml.Decoder, elem xml.StartElement) error) error {
+	r, err := reader.OpenXLSXSheet(filePath, sheet)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// xml.Decoder 直接使用 io.ByteReader 读取，不会预读，因此可以在 sheetData 开始后直接扫描底层的字节
+	br := bufio.NewReaderSize(r, 64*1024)
+	decoder := xml.NewDecoder(br)
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				depth++
+				continue
+			}
+
+			if t.Name.Local != "sheetData" {
+				if err := fn(decoder, t); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			empty, err := xlsxSheetDataEmpty(decoder)
+			if err != nil {
+				return err
+			}
+
+			if empty {
+				continue
+			}
+
+			prefix, err := skipXLSXSheetData(br)
+			if err != nil {
+				return err
+			}
+
+			// sheetData 之后的内容使用新的解析器读取，并补充根元素
+			decoder = xml.NewDecoder(io.MultiReader(strings.NewReader("<"+prefix+"worksheet>"), br))
+			depth = 0
+		case xml.EndElement:
+			if depth--; depth <= 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// xlsxSheetDataEmpty read the tokens after the start of sheetData until the first row, it returns true if sheetData
+// has no rows. The first row must be read by decoder, because the text before it makes the decoder read one more byte
+func xlsxSheetDataEmpty(decoder *xml.Decoder) (bool, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			return false, nil
+		case xml.EndElement:
+			if t.Name.Local == "sheetData" {
+				return true, nil
+			}
+		}
+	}
+}
+
+// skipXLSXSheetData skip the content until the end of sheetData, it returns the namespace prefix of the end tag, such as x:
+func skipXLSXSheetData(br *bufio.Reader) (string, error) {
+	suffix := []byte("sheetData>")
+	for {
+		data, err := br.ReadSlice('>')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("end of sheetData not found: %w", err)
+		}
+
+		if !bytes.HasSuffix(data, suffix) {
+			continue
+		}
+
+		if start := bytes.LastIndexByte(data, '<'); start >= 0 && start+1 < len(data) && data[start+1] == '/' {
+			return string(data[start+2 : len(data)-len(suffix)]), nil
+		}
+	}
+}
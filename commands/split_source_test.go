@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/xuri/excelize/v2"
+)
+
+func createSplitTestFile(t *testing.T, rows int) string {
+	filename := filepath.Join(t.TempDir(), "data.xlsx")
+
+	f := excelize.NewFile()
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Info", "", "Score"}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A2", &[]interface{}{"name", "group", "value"}))
+	assert.NoError(t, f.MergeCell("Sheet1", "A1", "B1"))
+	for i := 0; i < rows; i++ {
+		assert.NoError(t, f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i+3), &[]interface{}{fmt.Sprintf("user-%d", i), fmt.Sprintf("g%d", i%80), i}))
+	}
+	assert.NoError(t, f.SaveAs(filename))
+
+	return filename
+}
+
+func TestXLSXSplitSource(t *testing.T) {
+	filename := createSplitTestFile(t, 3)
+
+	source, err := openSplitSource(filename, reader.Options{}, true)
+	assert.NoError(t, err)
+	defer source.Close()
+
+	format, err := source.Format("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]string{{"A1", "B1"}}, format.Merges)
+
+	rowNums := make([]int, 0)
+	assert.NoError(t, source.Rows("Sheet1", func(rowNum int, row splitRow) (bool, error) {
+		rowNums = append(rowNums, rowNum)
+		assert.True(t, row.Format != nil)
+		return false, nil
+	}))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, rowNums)
+}
+
+func TestSplitExcelStreaming(t *testing.T) {
+	filename := createSplitTestFile(t, 150)
+	prefix := splitFilePrefix(filename)
+
+	assert.NoError(t, SplitExcelToParts(SplitOption{InputFile: filename, Slient: true, HeaderRowEndNum: 2, PerfileLimit: 100, OutputFormat: splitFormatXLSX}))
+	for i, count := range []int{100, 50} {
+		f, err := excelize.OpenFile(fmt.Sprintf("%s.part%d.xlsx", prefix, i+1))
+		assert.NoError(t, err)
+
+		rows, err := f.GetRows("Sheet1")
+		assert.NoError(t, err)
+		assert.Equal(t, count+2, len(rows))
+		assert.Equal(t, []string{"name", "group", "value"}, rows[1])
+
+		merges, err := f.GetMergeCells("Sheet1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(merges))
+		assert.NoError(t, f.Close())
+	}
+
+	// 分组数量超过同时打开的临时文件数量
+	assert.NoError(t, SplitExcelByColumn(SplitOption{InputFile: filename, Slient: true, HeaderRowEndNum: 2, ColumnIndex: "B", OutputFormat: splitFormatXLSX}))
+	matches, err := filepath.Glob(prefix + ".g*.xlsx")
+	assert.NoError(t, err)
+	assert.Equal(t, 80, len(matches))
+
+	f, err := excelize.OpenFile(prefix + ".g5.xlsx")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	rows, err := f.GetRows("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Info", "", "Score"}, {"name", "group", "value"}, {"user-5", "g5", "5"}, {"user-85", "g5", "85"}}, rows)
+}
+
+func TestSplitCSV(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.csv")
+	assert.NoError(t, os.WriteFile(filename, []byte("name;group\nTom;a\nJerry;b\n\"Spike; Jr\";a\n"), 0644))
+	prefix := splitFilePrefix(filename)
+
+	opt := SplitOption{
+		InputFile:       filename,
+		Slient:          true,
+		HeaderRowEndNum: 1,
+		PerfileLimit:    2,
+		ColumnIndex:     "B",
+		OutputFormat:    splitFormatCSV,
+		ReaderOption:    reader.Options{CSVSepertor: reader.AutoDelimiter},
+		CSVOutput:       render.CSVOptions{Comma: ',', NoBOM: true},
+	}
+
+	assert.NoError(t, SplitExcelToParts(opt))
+	for i, expect := range []string{"name,group\nTom,a\nJerry,b\n", "name,group\nSpike; Jr,a\n"} {
+		data, err := os.ReadFile(fmt.Sprintf("%s.part%d.csv", prefix, i+1))
+		assert.NoError(t, err)
+		assert.Equal(t, expect, string(data))
+	}
+
+	assert.NoError(t, SplitExcelByColumn(opt))
+	data, err := os.ReadFile(prefix + ".a.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "name,group\nTom,a\nSpike; Jr,a\n", string(data))
+
+	// csv 文件拆分为 xlsx 文件时，表头同样写入每一个文件
+	opt.OutputFormat = splitFormatXLSX
+	assert.NoError(t, SplitExcelByColumn(opt))
+	f, err := excelize.OpenFile(prefix + ".b.xlsx")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	rows, err := f.GetRows(csvSplitSheet)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"name", "group"}, {"Jerry", "b"}}, rows)
+}
+
+func TestSplitMaxSize(t *testing.T) {
+	filename := createSplitTestFile(t, 500)
+	prefix := splitFilePrefix(filename)
+
+	maxSize := int64(2 * 1024)
+	assert.NoError(t, SplitExcelToParts(SplitOption{InputFile: filename, Slient: true, HeaderRowEndNum: 2, MaxSize: maxSize, OutputFormat: splitFormatCSV, CSVOutput: render.CSVOptions{Comma: ','}}))
+
+	matches, err := filepath.Glob(prefix + ".part*.csv")
+	assert.NoError(t, err)
+	assert.True(t, len(matches) > 1)
+
+	total := 0
+	for _, match := range matches {
+		data, err := os.ReadFile(match)
+		assert.NoError(t, err)
+		assert.True(t, int64(len(data)) <= maxSize)
+		total += len(strings.Split(strings.TrimSpace(string(data)), "\n")) - 2
+	}
+	assert.Equal(t, 500, total)
+}
+
+func TestSplitSpill(t *testing.T) {
+	spill, err := newSplitSpill()
+	assert.NoError(t, err)
+	defer spill.Close()
+
+	date := time.Date(2023, 7, 15, 10, 30, 0, 0, time.UTC)
+	for i := 0; i < splitMaxOpenFiles*2; i++ {
+		assert.NoError(t, spill.Add(fmt.Sprintf("k%d", i%(splitMaxOpenFiles+1)), splitRecord{
+			Values: []interface{}{"a", float64(i), true, date, nil, excelize.Cell{StyleID: 3, Value: float64(i)}},
+			Height: 20,
+		}))
+	}
+
+	assert.Equal(t, splitMaxOpenFiles+1, len(spill.Keys()))
+	assert.True(t, len(spill.opened) <= splitMaxOpenFiles)
+
+	rows := make([]splitRecord, 0)
+	assert.NoError(t, spill.Rows("k1", func(row splitRecord) error {
+		rows = append(rows, row)
+		return nil
+	}))
+	assert.Equal(t, []splitRecord{
+		{Values: []interface{}{"a", float64(1), true, date, nil, excelize.Cell{StyleID: 3, Value: float64(1)}}, Height: 20},
+		{Values: []interface{}{"a", float64(splitMaxOpenFiles + 2), true, date, nil, excelize.Cell{StyleID: 3, Value: float64(splitMaxOpenFiles + 2)}}, Height: 20},
+	}, rows)
+}
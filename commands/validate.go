@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mylxsw/asteria/event"
+	"github.com/mylxsw/asteria/filter"
+	"github.com/mylxsw/asteria/level"
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/go-utils/must"
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/extracter"
+	"github.com/mylxsw/heimdall/query"
+	"github.com/mylxsw/heimdall/reader"
+	"github.com/mylxsw/heimdall/render"
+	"github.com/mylxsw/heimdall/validator"
+	"github.com/urfave/cli/v2"
+)
+
+type ValidateOption struct {
+	InputFiles  []string
+	Rules       string
+	CSVSepertor rune
+	Slient      bool
+	Debug       bool
+	Beta        bool
+
+	Format                  string
+	Output                  string
+	NoHeader                bool
+	TargetTableForSQLFormat string
+}
+
+func BuildValidateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{Name: "file", Aliases: []string{"i", "input"}, Usage: "input excel or csv file path, this flag can be specified multiple times for validating multiple files", Required: true},
+		&cli.StringFlag{Name: "rules", Aliases: []string{"r"}, Usage: "validation rules file in yaml format", Required: true},
+		&cli.StringFlag{Name: "csv-sepertor", Aliases: []string{"delimiter"}, Value: ",", Usage: csvSepertorUsage},
+		&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Value: "table", Usage: "output format of the violations report, support " + strings.Join(query.SupportedStandardFormats, ", ")},
+		&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "", Usage: "write output to a file, default output directly to STDOUT"},
+		&cli.BoolFlag{Name: "no-header", Aliases: []string{"n"}, Value: false, Usage: "do not write table header"},
+		&cli.StringFlag{Name: "table", Value: "", Usage: "when the format is sql, specify the table name"},
+		&cli.BoolFlag{Name: "slient", Value: false, Usage: "do not print warning log"},
+		&cli.BoolFlag{Name: "debug", Aliases: []string{"D"}, Value: false, Usage: "Debug mode"},
+		&cli.BoolFlag{Name: "beta", Value: false, Usage: "enable beta feature, when this flag is set, the loading performance for large excel file will be improved, may be unstable, use at your own risk"},
+	}
+}
+
+func resolveValidateOption(c *cli.Context) ValidateOption {
+	return ValidateOption{
+		InputFiles:              array.Filter(c.StringSlice("file"), func(f string, _ int) bool { return f != "" }),
+		Rules:                   c.String("rules"),
+		CSVSepertor:             parseDelimiterFlag("csv-sepertor", c.String("csv-sepertor")),
+		Slient:                  c.Bool("slient"),
+		Debug:                   c.Bool("debug"),
+		Beta:                    c.Bool("beta"),
+		Format:                  c.String("format"),
+		Output:                  c.String("output"),
+		NoHeader:                c.Bool("no-header"),
+		TargetTableForSQLFormat: c.String("table"),
+	}
+}
+
+// ValidateCommand validate input files using rules, the exit code is 0 if no errors found,
+// 1 if errors found, and 2 if something went wrong
+func ValidateCommand(c *cli.Context) error {
+	found, err := validateInputFiles(c)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("😨 %s", err), 2)
+	}
+
+	if found {
+		return cli.Exit("", 1)
+	}
+
+	return nil
+}
+
+func validateInputFiles(c *cli.Context) (bool, error) {
+	opt := resolveValidateOption(c)
+	if !opt.Debug {
+		log.All().LogLevel(level.Info)
+	}
+
+	if opt.Slient {
+		log.AddGlobalFilter(func(filter filter.Filter) filter.Filter {
+			return func(evt event.Event) {
+				if evt.Level == level.Warning {
+					return
+				}
+
+				filter(evt)
+			}
+		})
+	}
+
+	if opt.Format == "sql" && opt.TargetTableForSQLFormat == "" {
+		return false, fmt.Errorf("when the format is sql, the table name (--table) is required")
+	}
+
+	v, err := validator.Load(opt.Rules)
+	if err != nil {
+		return false, err
+	}
+
+	walker := reader.MergeWalkers(array.Map(
+		opt.InputFiles,
+		func(f string, _ int) reader.FileWalker {
+			return reader.CreateFileWalker(f, opt.CSVSepertor, false, opt.Beta)
+		})...,
+	)
+	if walker == nil {
+		return false, fmt.Errorf("no file avaiable: only support csv, xlsx, xls or ods files")
+	}
+
+	violations, err := validateWalker(v, walker)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := renderViolations(opt.Format, opt.NoHeader, violations, opt.TargetTableForSQLFormat)
+	if err != nil {
+		return false, err
+	}
+
+	w := ternary.IfElseLazy(
+		opt.Output != "",
+		func() io.WriteCloser { return must.Must(os.Create(opt.Output)) },
+		func() io.WriteCloser { return os.Stdout },
+	)
+
+	// 命令退出时会直接调用 os.Exit，这里不能使用 defer 关闭文件
+	_, err = w.Write(res)
+	if opt.Output != "" {
+		if err1 := w.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+
+	return hasValidationErrors(violations), err
+}
+
+// validateWalker validate all rows in walker, the rows are processed one by one
+func validateWalker(v *validator.Validator, walker reader.FileWalker) ([]validator.Violation, error) {
+	violations := make([]validator.Violation, 0)
+	err := walker(
+		func(filepath string, headers []string) error {
+			violations = append(violations, v.Header(filepath, headers)...)
+			return nil
+		},
+		func(filepath string, id string, data []string) error {
+			// 跳过空行，与 import 命令的行为保持一致
+			if len(array.Filter(data, func(item string, _ int) bool { return strings.TrimSpace(item) != "" })) == 0 {
+				return nil
+			}
+
+			violations = append(violations, v.Row(filepath, id, data)...)
+			return nil
+		},
+	)
+
+	return violations, err
+}
+
+func hasValidationErrors(violations []validator.Violation) bool {
+	return len(array.Filter(violations, func(v validator.Violation, _ int) bool { return v.Severity == validator.SeverityError })) > 0
+}
+
+// renderViolations render the violations report
+func renderViolations(format string, noHeader bool, violations []validator.Violation, targetTableForSQLFormat string) ([]byte, error) {
+	cols := array.Map(
+		[]string{"file", "line", "column", "value", "rule", "severity", "message"},
+		func(name string, _ int) extracter.Column {
+			return extracter.Column{Name: name, Type: extracter.ColumnTypeVarchar}
+		},
+	)
+
+	kvs := array.Map(violations, func(v validator.Violation, _ int) map[string]interface{} {
+		return map[string]interface{}{
+			"file":     v.File,
+			"line":     v.Line,
+			"column":   v.Column,
+			"value":    v.Value,
+			"rule":     v.Rule,
+			"severity": v.Severity,
+			"message":  v.Message,
+		}
+	})
+
+	res, err := render.Render(format, noHeader, cols, kvs, "", targetTableForSQLFormat, render.CSVOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Bytes(), nil
+}
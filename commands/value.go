@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/heimdall/extracter"
+)
+
+// comparableValue convert value to string for comparing and counting, values from files are always strings,
+// but values from MySQL may be numbers or times
+func comparableValue(col extracter.Column, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if col.Type == extracter.ColumnTypeDate {
+			return v.Format("2006-01-02")
+		}
+
+		return v.Format("2006-01-02 15:04:05")
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%v", value))
+}
@@ -83,11 +83,46 @@ func main() {
 		},
 		{
 			Name:      "split",
-			Usage:     "split a large Excel file into multiple small files, each containing a specified number of rows at most",
+			Usage:     "split a large Excel or csv file into multiple small files, each containing a specified number of rows at most",
 			UsageText: `heimdall split --file data.xlsx --perfile-limit 1000 --header-row-num 2`,
 			Action:    commands.SplitCommand,
 			Flags:     commands.BuildSplitFlags(),
 		},
+		{
+			Name:      "merge",
+			Usage:     "merge multiple Excel or csv files into one file, the columns are aligned by header name",
+			UsageText: `heimdall merge --file 'regions/*.xlsx' --add-source -o all.xlsx`,
+			Action:    commands.MergeCommand,
+			Flags:     commands.BuildMergeFlags(),
+		},
+		{
+			Name:      "diff",
+			Usage:     "compare two datasets (xlsx/csv files or MySQL query results) by key",
+			UsageText: `heimdall diff --left data.xlsx --right "mysql:SELECT id, name, age FROM users" --key id --database example`,
+			Action:    commands.DiffCommand,
+			Flags:     commands.BuildDiffFlags(),
+		},
+		{
+			Name:      "validate",
+			Usage:     "validate xlsx/csv files using declarative rules, and output the violations report",
+			UsageText: `heimdall validate --file data.xlsx --rules rules.yaml --format csv --output violations.csv`,
+			Action:    commands.ValidateCommand,
+			Flags:     commands.BuildValidateFlags(),
+		},
+		{
+			Name:      "profile",
+			Usage:     "profile the columns of xlsx/csv files or MySQL query results: null rate, distinct count, min/max, type, top values, etc.",
+			UsageText: `heimdall profile --file data.xlsx --top 10 --format markdown`,
+			Action:    commands.ProfileCommand,
+			Flags:     commands.BuildProfileFlags(),
+		},
+		{
+			Name:      "serve",
+			Usage:     "start a http server, exposing export and fly as http api",
+			UsageText: `heimdall serve --listen 127.0.0.1:8080 --token secret --database example --connection report=root:root@tcp(127.0.0.1:3306)/report`,
+			Action:    commands.ServeCommand,
+			Flags:     commands.BuildServeFlags(),
+		},
 		{
 			Name:  "version",
 			Usage: "show version",
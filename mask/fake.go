@@ -0,0 +1,38 @@
+package mask
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+var (
+	fakeSurnames   = []rune("王李张刘陈杨黄赵吴周徐孙马朱胡郭何高林罗郑梁谢宋唐许韩冯邓曹彭曾肖田董袁潘于蒋蔡余杜叶程苏魏吕丁任沈姚卢姜崔钟谭陆汪范金石廖贾夏韦付方白邹孟熊秦邱江尹薛闫段雷侯龙史陶黎贺顾毛郝龚邵万钱严覃武戴莫孔向汤")
+	fakeGivenNames = []rune("伟芳娜敏静丽强磊军洋勇艳杰娟涛明超秀霞平刚桂英华玉兰萍红鹏辉建国文斌宇浩凯婷雪琳晨欣怡子涵梓轩思雨佳一诺")
+	phonePrefixes  = []string{"130", "131", "132", "133", "135", "136", "137", "138", "139", "150", "151", "152", "155", "156", "157", "158", "159", "166", "176", "177", "178", "180", "181", "185", "186", "187", "188", "189", "198", "199"}
+	emailDomains   = []string{"example.com", "example.net", "example.org"}
+)
+
+// fakers generate fake values using the random source, the random source is seeded by the original value,
+// so that the same value always generates the same fake value
+var fakers = map[string]func(r *rand.Rand) string{
+	"name": func(r *rand.Rand) string {
+		name := string(fakeSurnames[r.Intn(len(fakeSurnames))])
+		for i := 0; i < 1+r.Intn(2); i++ {
+			name += string(fakeGivenNames[r.Intn(len(fakeGivenNames))])
+		}
+
+		return name
+	},
+	"phone": func(r *rand.Rand) string {
+		return fmt.Sprintf("%s%08d", phonePrefixes[r.Intn(len(phonePrefixes))], r.Intn(100000000))
+	},
+	"email": func(r *rand.Rand) string {
+		const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+		user := make([]byte, 6+r.Intn(6))
+		for i := range user {
+			user[i] = letters[r.Intn(len(letters))]
+		}
+
+		return fmt.Sprintf("%s@%s", user, emailDomains[r.Intn(len(emailDomains))])
+	},
+}
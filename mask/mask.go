@@ -0,0 +1,241 @@
+package mask
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/heimdall/extracter"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is a reusable masking policy file
+//
+//	salt: s3cret
+//	seed: 2022
+//	rules:
+//	  - column: phone
+//	    rule: partial(3,4)
+//	  - column: "*idcard*"
+//	    rule: hash
+type Policy struct {
+	Salt  string       `yaml:"salt"`
+	Seed  int64        `yaml:"seed"`
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule is a masking rule for columns
+type PolicyRule struct {
+	// Column is the column name, support glob pattern such as *phone*
+	Column string `yaml:"column"`
+	// Rule is one of hash, partial(M,N), null, redact, fake:name, fake:phone, fake:email
+	Rule string `yaml:"rule"`
+}
+
+// Masker masks the values of sensitive columns
+type Masker struct {
+	salt  string
+	seed  int64
+	rules []columnRule
+}
+
+type columnRule struct {
+	column string
+	name   string
+	fn     func(m *Masker, value string) interface{}
+}
+
+// New create a Masker, specs are in the form of column:rule, it returns nil if no rule specified
+func New(policyFile string, specs []string, salt string, seed int64) (*Masker, error) {
+	policy := Policy{Salt: salt, Seed: seed}
+	if policyFile != "" {
+		data, err := os.ReadFile(policyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read mask policy failed: %w", err)
+		}
+
+		if err := yaml.Unmarshal(data, &policy); err != nil {
+			return nil, fmt.Errorf("parse mask policy %s failed: %w", policyFile, err)
+		}
+
+		// 命令行中明确指定的 salt 和 seed 优先
+		if salt != "" {
+			policy.Salt = salt
+		}
+		if seed != 0 {
+			policy.Seed = seed
+		}
+	}
+
+	for _, spec := range specs {
+		segs := strings.SplitN(spec, ":", 2)
+		if len(segs) != 2 || segs[0] == "" {
+			return nil, fmt.Errorf("invalid mask %s, should be column:rule", spec)
+		}
+
+		policy.Rules = append(policy.Rules, PolicyRule{Column: segs[0], Rule: segs[1]})
+	}
+
+	if len(policy.Rules) == 0 {
+		return nil, nil
+	}
+
+	m := &Masker{salt: policy.Salt, seed: policy.Seed}
+	for _, r := range policy.Rules {
+		fn, err := parseRule(r.Rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask rule for column %s: %w", r.Column, err)
+		}
+
+		// 没有 salt 时，可以通过对常见值（如手机号）穷举计算 hash 的方式还原原始值
+		if strings.TrimSpace(r.Rule) == "hash" && m.salt == "" {
+			return nil, fmt.Errorf("salt is required for hash rule of column %s, specify it by --mask-salt, HEIMDALL_MASK_SALT environment or salt in policy file", r.Column)
+		}
+
+		m.rules = append(m.rules, columnRule{column: r.Column, name: r.Rule, fn: fn})
+	}
+
+	return m, nil
+}
+
+func parseRule(rule string) (func(m *Masker, value string) interface{}, error) {
+	rule = strings.TrimSpace(rule)
+	switch {
+	case rule == "hash":
+		return func(m *Masker, value string) interface{} {
+			sum := sha256.Sum256([]byte(m.salt + value))
+			return hex.EncodeToString(sum[:])
+		}, nil
+	case rule == "null":
+		return func(m *Masker, value string) interface{} { return nil }, nil
+	case rule == "redact":
+		return func(m *Masker, value string) interface{} { return "******" }, nil
+	case strings.HasPrefix(rule, "partial(") && strings.HasSuffix(rule, ")"):
+		args := strings.Split(strings.TrimSuffix(strings.TrimPrefix(rule, "partial("), ")"), ",")
+		if len(args) != 2 {
+			return nil, fmt.Errorf("partial rule requires 2 arguments: partial(KEEP_FIRST,KEEP_LAST)")
+		}
+
+		first, err1 := strconv.Atoi(strings.TrimSpace(args[0]))
+		last, err2 := strconv.Atoi(strings.TrimSpace(args[1]))
+		if err1 != nil || err2 != nil || first < 0 || last < 0 {
+			return nil, fmt.Errorf("invalid arguments for partial rule: %s", rule)
+		}
+
+		return func(m *Masker, value string) interface{} { return partial(value, first, last) }, nil
+	case strings.HasPrefix(rule, "fake:"):
+		kind := strings.TrimPrefix(rule, "fake:")
+		gen, ok := fakers[kind]
+		if !ok {
+			return nil, fmt.Errorf("unsupported fake type %s, support name, phone, email", kind)
+		}
+
+		return func(m *Masker, value string) interface{} {
+			sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s", m.seed, kind, value)))
+			return gen(rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(sum[:8])))))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported rule %s", rule)
+}
+
+// partial keep the first and last characters, replace others with *
+func partial(value string, first, last int) string {
+	runes := []rune(value)
+	if len(runes) <= first+last {
+		return strings.Repeat("*", len(runes))
+	}
+
+	return string(runes[:first]) + strings.Repeat("*", len(runes)-first-last) + string(runes[len(runes)-last:])
+}
+
+// ruleFor return the matched rule for the column, the later rule has higher priority
+func (m *Masker) ruleFor(column string) *columnRule {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := &m.rules[i]
+		if strings.EqualFold(r.column, column) {
+			return r
+		}
+
+		if matched, _ := filepath.Match(strings.ToLower(r.column), strings.ToLower(column)); matched {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// Columns change the type of masked columns to VARCHAR, because the masked values are all strings
+func (m *Masker) Columns(cols []extracter.Column) []extracter.Column {
+	if m == nil {
+		return cols
+	}
+
+	res := make([]extracter.Column, len(cols))
+	for i, col := range cols {
+		res[i] = col
+		if m.ruleFor(col.Name) != nil {
+			res[i].Type = extracter.ColumnTypeVarchar
+		}
+	}
+
+	return res
+}
+
+// Apply mask the values in row, the row will be modified in place
+func (m *Masker) Apply(row map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return row
+	}
+
+	for k, v := range row {
+		if v == nil {
+			continue
+		}
+
+		if r := m.ruleFor(k); r != nil {
+			row[k] = r.fn(m, stringify(v))
+		}
+	}
+
+	return row
+}
+
+// Stream mask the rows in stream one by one
+func (m *Masker) Stream(stream <-chan map[string]interface{}) <-chan map[string]interface{} {
+	if m == nil {
+		return stream
+	}
+
+	res := make(chan map[string]interface{})
+	go func() {
+		defer close(res)
+		for row := range stream {
+			res <- m.Apply(row)
+		}
+	}()
+
+	return res
+}
+
+func stringify(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
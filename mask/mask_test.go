@@ -0,0 +1,50 @@
+package mask
+
+import (
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/mylxsw/heimdall/extracter"
+)
+
+func TestMasker(t *testing.T) {
+	m, err := New("", []string{"phone:partial(3,4)", "*card*:hash", "name:fake:name", "remark:redact", "email:null"}, "salt", 1)
+	assert.NoError(t, err)
+
+	row := m.Apply(map[string]interface{}{
+		"phone":   "13812345678",
+		"id_card": "110101199003077777",
+		"name":    "张三",
+		"remark":  "secret",
+		"email":   "foo@example.com",
+		"age":     int64(18),
+	})
+
+	assert.Equal(t, "138****5678", row["phone"])
+	assert.Equal(t, 64, len(row["id_card"].(string)))
+	assert.Equal(t, "******", row["remark"])
+	assert.Equal(t, nil, row["email"])
+	assert.Equal(t, int64(18), row["age"])
+
+	// fake 值对于相同的输入和 seed 是确定的
+	row2 := m.Apply(map[string]interface{}{"name": "张三"})
+	assert.Equal(t, row["name"], row2["name"])
+
+	cols := m.Columns([]extracter.Column{{Name: "phone", Type: extracter.ColumnTypeBigint}, {Name: "age", Type: extracter.ColumnTypeInt}})
+	assert.Equal(t, extracter.ColumnTypeVarchar, cols[0].Type)
+	assert.Equal(t, extracter.ColumnTypeInt, cols[1].Type)
+}
+
+func TestMaskerHashRequiresSalt(t *testing.T) {
+	_, err := New("", []string{"*card*:hash"}, "", 0)
+	assert.True(t, err != nil)
+
+	m, err := New("", []string{"phone:partial(3,4)"}, "", 0)
+	assert.NoError(t, err)
+	assert.True(t, m != nil)
+}
+
+func TestPartial(t *testing.T) {
+	assert.Equal(t, "张*", partial("张三", 1, 0))
+	assert.Equal(t, "***", partial("abc", 3, 4))
+}
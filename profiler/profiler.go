@@ -0,0 +1,346 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Inferred value types
+const (
+	TypeEmpty    = "empty"
+	TypeInteger  = "integer"
+	TypeFloat    = "float"
+	TypeBool     = "bool"
+	TypeDate     = "date"
+	TypeDatetime = "datetime"
+	TypeString   = "string"
+)
+
+var (
+	dateLayouts     = []string{"2006-01-02", "2006/01/02", "2006-1-2", "2006/1/2"}
+	datetimeLayouts = []string{"2006-01-02 15:04:05", "2006/01/02 15:04:05", "2006-01-02 15:04", "2006/01/02 15:04", time.RFC3339, "2006-01-02T15:04:05"}
+)
+
+// Column collect the statistics of a column, the memory usage is bounded by distinctLimit
+type Column struct {
+	Name string
+
+	topN          int
+	distinctLimit int
+
+	rows  int
+	empty int
+
+	// 精确计数阶段使用 counts 保存所有值，超过 distinctLimit 后切换到 HyperLogLog 和 Space-Saving 算法
+	counts map[string]int
+	hll    *hyperLogLog
+	approx bool
+
+	types map[string]int
+
+	numMin, numMax float64
+	hasNum         bool
+	strMin, strMax string
+	hasStr         bool
+
+	lengths  map[int]int
+	totalLen int
+}
+
+// NewColumn create a column profiler, topN is the number of most frequent values to report,
+// distinctLimit is the maximum number of distinct values to count exactly
+func NewColumn(name string, topN int, distinctLimit int) *Column {
+	return &Column{
+		Name:          name,
+		topN:          topN,
+		distinctLimit: distinctLimit,
+		counts:        make(map[string]int),
+		types:         make(map[string]int),
+		lengths:       make(map[int]int),
+	}
+}
+
+// Add add a value to the column, nil value is treated as empty
+func (c *Column) Add(value *string) {
+	c.rows++
+	if value == nil || strings.TrimSpace(*value) == "" {
+		c.empty++
+		return
+	}
+
+	val := strings.TrimSpace(*value)
+
+	typ := InferType(val)
+	c.types[typ]++
+
+	if typ == TypeInteger || typ == TypeFloat {
+		if num, err := strconv.ParseFloat(val, 64); err == nil {
+			if !c.hasNum || num < c.numMin {
+				c.numMin = num
+			}
+			if !c.hasNum || num > c.numMax {
+				c.numMax = num
+			}
+			c.hasNum = true
+		}
+	}
+
+	if !c.hasStr || val < c.strMin {
+		c.strMin = val
+	}
+	if !c.hasStr || val > c.strMax {
+		c.strMax = val
+	}
+	c.hasStr = true
+
+	length := utf8.RuneCountInString(val)
+	c.lengths[length]++
+	c.totalLen += length
+
+	c.count(val)
+}
+
+func (c *Column) count(val string) {
+	if !c.approx {
+		c.counts[val]++
+		if len(c.counts) <= c.distinctLimit {
+			return
+		}
+
+		// 切换到近似计数模式
+		c.approx = true
+		c.hll = newHyperLogLog()
+		for v := range c.counts {
+			c.hll.Add(v)
+		}
+
+		c.shrinkCounts()
+		return
+	}
+
+	c.hll.Add(val)
+
+	// Space-Saving: 计数器已满时替换计数最小的值
+	if _, ok := c.counts[val]; ok || len(c.counts) < c.counterCapacity() {
+		c.counts[val]++
+		return
+	}
+
+	minVal, minCount := "", math.MaxInt
+	for v, cnt := range c.counts {
+		if cnt < minCount {
+			minVal, minCount = v, cnt
+		}
+	}
+
+	delete(c.counts, minVal)
+	c.counts[val] = minCount + 1
+}
+
+func (c *Column) counterCapacity() int {
+	capacity := c.topN * 20
+	if capacity < 100 {
+		capacity = 100
+	}
+
+	return capacity
+}
+
+// shrinkCounts keep only the most frequent values as the counters of Space-Saving algorithm
+func (c *Column) shrinkCounts() {
+	top := c.top(c.counterCapacity())
+	c.counts = make(map[string]int, len(top))
+	for _, vc := range top {
+		c.counts[vc.Value] = vc.Count
+	}
+}
+
+// ValueCount is a value and the number of its occurrences
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+func (c *Column) top(n int) []ValueCount {
+	items := make([]ValueCount, 0, len(c.counts))
+	for v, cnt := range c.counts {
+		items = append(items, ValueCount{Value: v, Count: cnt})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count == items[j].Count {
+			return items[i].Value < items[j].Value
+		}
+
+		return items[i].Count > items[j].Count
+	})
+
+	if len(items) > n {
+		items = items[:n]
+	}
+
+	return items
+}
+
+// Result is the statistics of a column
+type Result struct {
+	Name          string
+	Rows          int
+	Empty         int
+	EmptyPercent  float64
+	Distinct      int
+	DistinctExact bool
+	Type          string
+	Min           string
+	Max           string
+	Top           []ValueCount
+	LenMin        int
+	LenMax        int
+	LenAvg        float64
+	LenP50        int
+	LenP90        int
+}
+
+// Result return the statistics of the column
+func (c *Column) Result() Result {
+	res := Result{
+		Name:          c.Name,
+		Rows:          c.rows,
+		Empty:         c.empty,
+		Distinct:      len(c.counts),
+		DistinctExact: !c.approx,
+		Type:          c.inferredType(),
+		Top:           c.top(c.topN),
+	}
+
+	if c.rows > 0 {
+		res.EmptyPercent = math.Round(float64(c.empty)*10000/float64(c.rows)) / 100
+	}
+
+	if c.approx {
+		res.Distinct = int(c.hll.Count())
+	}
+
+	if (res.Type == TypeInteger || res.Type == TypeFloat) && c.hasNum {
+		res.Min = strconv.FormatFloat(c.numMin, 'f', -1, 64)
+		res.Max = strconv.FormatFloat(c.numMax, 'f', -1, 64)
+	} else if c.hasStr {
+		res.Min, res.Max = c.strMin, c.strMax
+	}
+
+	nonEmpty := c.rows - c.empty
+	if nonEmpty > 0 {
+		lengths := make([]int, 0, len(c.lengths))
+		for l := range c.lengths {
+			lengths = append(lengths, l)
+		}
+		sort.Ints(lengths)
+
+		res.LenMin, res.LenMax = lengths[0], lengths[len(lengths)-1]
+		res.LenAvg = math.Round(float64(c.totalLen)*100/float64(nonEmpty)) / 100
+
+		var acc int
+		for _, l := range lengths {
+			acc += c.lengths[l]
+			if res.LenP50 == 0 && acc*2 >= nonEmpty {
+				res.LenP50 = l
+			}
+			if res.LenP90 == 0 && acc*10 >= nonEmpty*9 {
+				res.LenP90 = l
+			}
+		}
+	}
+
+	return res
+}
+
+// inferredType return the type matches all values in the column
+func (c *Column) inferredType() string {
+	if len(c.types) == 0 {
+		return TypeEmpty
+	}
+
+	if len(c.types) == 1 {
+		for typ := range c.types {
+			return typ
+		}
+	}
+
+	onlyIn := func(types ...string) bool {
+		for typ := range c.types {
+			found := false
+			for _, t := range types {
+				if t == typ {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	switch {
+	case onlyIn(TypeInteger, TypeFloat):
+		return TypeFloat
+	case onlyIn(TypeDate, TypeDatetime):
+		return TypeDatetime
+	}
+
+	return TypeString
+}
+
+// InferType infer the type of a non-empty value
+func InferType(val string) string {
+	if _, err := strconv.ParseInt(val, 10, 64); err == nil {
+		// 以 0 开头的多位数字（如编号、身份证号）作为字符串处理
+		if len(val) > 1 && val[0] == '0' {
+			return TypeString
+		}
+
+		return TypeInteger
+	}
+
+	// ParseFloat 会将 NaN、Inf 等视为合法数字，这里要求至少包含一个数字
+	if _, err := strconv.ParseFloat(val, 64); err == nil && strings.ContainsAny(val, "0123456789") {
+		return TypeFloat
+	}
+
+	switch strings.ToLower(val) {
+	case "true", "false":
+		return TypeBool
+	}
+
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, val); err == nil {
+			return TypeDate
+		}
+	}
+
+	for _, layout := range datetimeLayouts {
+		if _, err := time.Parse(layout, val); err == nil {
+			return TypeDatetime
+		}
+	}
+
+	return TypeString
+}
+
+// FormatTop format the top values as value(count), ...
+func FormatTop(items []ValueCount) string {
+	res := make([]string, 0, len(items))
+	for _, item := range items {
+		res = append(res, fmt.Sprintf("%s(%d)", item.Value, item.Count))
+	}
+
+	return strings.Join(res, ", ")
+}
@@ -0,0 +1,58 @@
+package profiler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestColumn(t *testing.T) {
+	col := NewColumn("age", 2, 100)
+	for _, v := range []string{"18", "20", "", "18", "3.5", " "} {
+		col.Add(ptr(v))
+	}
+	col.Add(nil)
+
+	res := col.Result()
+	assert.Equal(t, 7, res.Rows)
+	assert.Equal(t, 3, res.Empty)
+	assert.Equal(t, 42.86, res.EmptyPercent)
+	assert.Equal(t, 3, res.Distinct)
+	assert.True(t, res.DistinctExact)
+	assert.Equal(t, TypeFloat, res.Type)
+	assert.Equal(t, "3.5", res.Min)
+	assert.Equal(t, "20", res.Max)
+	assert.Equal(t, "18(2), 20(1)", FormatTop(res.Top))
+	assert.Equal(t, 2, res.LenMin)
+	assert.Equal(t, 3, res.LenMax)
+}
+
+func TestColumnApproximate(t *testing.T) {
+	col := NewColumn("id", 3, 1000)
+	for i := 0; i < 50000; i++ {
+		col.Add(ptr(fmt.Sprintf("v%d", i)))
+		if i%10 == 0 {
+			col.Add(ptr("hot"))
+		}
+	}
+
+	res := col.Result()
+	assert.True(t, !res.DistinctExact)
+	assert.True(t, res.Distinct > 49000 && res.Distinct < 51000)
+	assert.Equal(t, "hot", res.Top[0].Value)
+	assert.Equal(t, TypeString, res.Type)
+}
+
+func TestInferType(t *testing.T) {
+	assert.Equal(t, TypeInteger, InferType("123"))
+	assert.Equal(t, TypeString, InferType("0123"))
+	assert.Equal(t, TypeFloat, InferType("1.5"))
+	assert.Equal(t, TypeString, InferType("NaN"))
+	assert.Equal(t, TypeBool, InferType("TRUE"))
+	assert.Equal(t, TypeDate, InferType("2022-01-02"))
+	assert.Equal(t, TypeDatetime, InferType("2022-01-02 10:11:12"))
+	assert.Equal(t, TypeString, InferType("hello"))
+}
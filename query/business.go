@@ -9,6 +9,7 @@ import (
 
 	"github.com/mylxsw/go-utils/array"
 	"github.com/mylxsw/heimdall/extracter"
+	"github.com/mylxsw/heimdall/mask"
 	"github.com/mylxsw/heimdall/render"
 )
 
@@ -23,7 +24,8 @@ type QueryWriteHandler func(sqlStr string, args []interface{}, format string, ou
 // NewStreamingQueryWriter create a function that executes SQL in the database
 // and writes the returned results to a file in the specified format.
 // The SQL query and the writing of the results are all streamed to reduce memory usage
-func NewStreamingQueryWriter(dbConnStr string, targetTableForSQLFormat string, connectTimeout time.Duration) QueryWriteHandler {
+// The values of sensitive columns will be masked by masker if it is not nil, csvOpt is used when the format is csv
+func NewStreamingQueryWriter(dbConnStr string, targetTableForSQLFormat string, connectTimeout time.Duration, masker *mask.Masker, csvOpt render.CSVOptions) QueryWriteHandler {
 	return func(sqlStr string, args []interface{}, format string, output io.Writer, noHeader bool) (int, error) {
 		if !array.In(format, SupportedStreamingFormats) {
 			return 0, fmt.Errorf("streaming only supports csv/json/plain/xlsx/sql format, the current format is %s", format)
@@ -49,21 +51,24 @@ func NewStreamingQueryWriter(dbConnStr string, targetTableForSQLFormat string, c
 			return 0, err
 		}
 
-		return render.StreamingRender(output, format, noHeader, cols, stream, targetTableForSQLFormat)
+		return render.StreamingRender(output, format, noHeader, masker.Columns(cols), masker.Stream(stream), targetTableForSQLFormat, csvOpt)
 	}
 }
 
 // NewStandardQueryWriter create a function that executes SQL in the database
 // and writes the returned results to a file in the specified format.
 // Querying and writing are done at one time, and all intermediate process data will be loaded into memory
-func NewStandardQueryWriter(dbConnStr string, targetTableForSQLFormat string, connectTimeout time.Duration, queryTimeout time.Duration) QueryWriteHandler {
+// The values of sensitive columns will be masked by masker if it is not nil, csvOpt is used when the format is csv
+func NewStandardQueryWriter(dbConnStr string, targetTableForSQLFormat string, connectTimeout time.Duration, queryTimeout time.Duration, masker *mask.Masker, csvOpt render.CSVOptions) QueryWriteHandler {
 	return func(sqlStr string, args []interface{}, format string, output io.Writer, noHeader bool) (int, error) {
 		rs, err := Query(dbConnStr, sqlStr, args, connectTimeout, queryTimeout)
 		if err != nil {
 			return 0, err
 		}
 
-		writer, err := render.Render(format, noHeader, rs.Columns, rs.DataSets, sqlStr, targetTableForSQLFormat)
+		MaskRows(rs, masker)
+
+		writer, err := render.Render(format, noHeader, rs.Columns, rs.DataSets, sqlStr, targetTableForSQLFormat, csvOpt)
 		if err != nil {
 			return 0, err
 		}
@@ -79,7 +84,8 @@ func NewStandardQueryWriter(dbConnStr string, targetTableForSQLFormat string, co
 // NewStandardQueryWriterWithDB create a function that executes SQL in the database
 // and writes the returned results to a file in the specified format.
 // Querying and writing are done at one time, and all intermediate process data will be loaded into memory
-func NewStandardQueryWriterWithDB(db *sql.DB, targetTableForSQLFormat string, queryTimeout time.Duration) func(sqlStr string, args []interface{}, format string, output io.Writer, noHeader bool, dataProcesser func(*extracter.Rows)) (int, error) {
+// The values of sensitive columns will be masked by masker if it is not nil, csvOpt is used when the format is csv
+func NewStandardQueryWriterWithDB(db *sql.DB, targetTableForSQLFormat string, queryTimeout time.Duration, masker *mask.Masker, csvOpt render.CSVOptions) func(sqlStr string, args []interface{}, format string, output io.Writer, noHeader bool, dataProcesser func(*extracter.Rows)) (int, error) {
 	return func(sqlStr string, args []interface{}, format string, output io.Writer, noHeader bool, dataProcesser func(*extracter.Rows)) (int, error) {
 		rs, err := QueryDB(db, sqlStr, args, queryTimeout)
 		if err != nil {
@@ -90,7 +96,9 @@ func NewStandardQueryWriterWithDB(db *sql.DB, targetTableForSQLFormat string, qu
 			dataProcesser(rs)
 		}
 
-		writer, err := render.Render(format, noHeader, rs.Columns, rs.DataSets, sqlStr, targetTableForSQLFormat)
+		MaskRows(rs, masker)
+
+		writer, err := render.Render(format, noHeader, rs.Columns, rs.DataSets, sqlStr, targetTableForSQLFormat, csvOpt)
 		if err != nil {
 			return 0, err
 		}
@@ -102,3 +110,15 @@ func NewStandardQueryWriterWithDB(db *sql.DB, targetTableForSQLFormat string, qu
 		return len(rs.DataSets), nil
 	}
 }
+
+// MaskRows mask the values of sensitive columns in rs
+func MaskRows(rs *extracter.Rows, masker *mask.Masker) {
+	if masker == nil {
+		return
+	}
+
+	rs.Columns = masker.Columns(rs.Columns)
+	for _, row := range rs.DataSets {
+		masker.Apply(row)
+	}
+}
@@ -1,8 +1,62 @@
 package query
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ConnOption is the options for building a MySQL database connection string
+type ConnOption struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	// Socket is the unix socket path, if set, Host and Port will be ignored
+	Socket string
+	// Net is the network name registered by RegisterSSHTunnel, default is tcp or unix
+	Net string
+	// TLS is the value of tls param, such as false, skip-verify, preferred or a name returned by RegisterTLSConfig
+	TLS string
+	// Params are extra DSN params, such as charset, collation, timeout, readTimeout
+	Params map[string]string
+}
+
+// ConnStr build a MySQL database connection string
+func (opt ConnOption) ConnStr() string {
+	conf := mysql.NewConfig()
+	conf.User = opt.User
+	conf.Passwd = opt.Password
+	conf.DBName = opt.Database
+	conf.Loc = time.Local
+	conf.ParseTime = true
+	conf.TLSConfig = opt.TLS
+	conf.Params = opt.Params
+
+	if opt.Socket != "" {
+		conf.Net = "unix"
+		conf.Addr = opt.Socket
+	} else {
+		conf.Net = "tcp"
+		conf.Addr = fmt.Sprintf("%s:%d", opt.Host, opt.Port)
+	}
+
+	if opt.Net != "" {
+		conf.Net = opt.Net
+	}
+
+	return conf.FormatDSN()
+}
 
 // BuildConnStr build a MySQL database connection string
 func BuildConnStr(mysqlDB, mysqlUser, mysqlPassword, mysqlHost string, mysqlPort int) string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?loc=Local&parseTime=true", mysqlUser, mysqlPassword, mysqlHost, mysqlPort, mysqlDB)
+	return ConnOption{
+		Host:     mysqlHost,
+		Port:     mysqlPort,
+		User:     mysqlUser,
+		Password: mysqlPassword,
+		Database: mysqlDB,
+	}.ConnStr()
 }
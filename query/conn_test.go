@@ -0,0 +1,129 @@
+package query
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestConnStr(t *testing.T) {
+	cases := []struct {
+		opt      ConnOption
+		expected string
+	}{
+		{
+			opt:      ConnOption{Host: "127.0.0.1", Port: 3306, User: "root", Password: "p@ss", Database: "example"},
+			expected: "root:p@ss@tcp(127.0.0.1:3306)/example?loc=Local&parseTime=true",
+		},
+		{
+			opt:      ConnOption{Host: "127.0.0.1", Port: 3306, User: "root", Socket: "/var/run/mysqld/mysqld.sock"},
+			expected: "root@unix(/var/run/mysqld/mysqld.sock)/?loc=Local&parseTime=true",
+		},
+		{
+			opt:      ConnOption{Host: "db.prod", Port: 3307, User: "readonly", Database: "example", TLS: "skip-verify"},
+			expected: "readonly@tcp(db.prod:3307)/example?loc=Local&parseTime=true&tls=skip-verify",
+		},
+		{
+			opt:      ConnOption{Host: "db.prod", Port: 3306, User: "readonly", Net: "heimdall-ssh-1"},
+			expected: "readonly@heimdall-ssh-1(db.prod:3306)/?loc=Local&parseTime=true",
+		},
+		{
+			opt:      ConnOption{Host: "db.prod", Port: 3306, User: "readonly", Params: map[string]string{"timeout": "5s", "charset": "utf8mb4"}},
+			expected: "readonly@tcp(db.prod:3306)/?loc=Local&parseTime=true&charset=utf8mb4&timeout=5s",
+		},
+	}
+
+	for _, c := range cases {
+		dsn := c.opt.ConnStr()
+		assert.Equal(t, c.expected, dsn)
+
+		conf, err := mysql.ParseDSN(dsn)
+		assert.NoError(t, err)
+		assert.Equal(t, c.opt.User, conf.User)
+		assert.True(t, conf.ParseTime)
+		assert.Equal(t, time.Local, conf.Loc)
+	}
+
+	// 与原有的连接字符串保持一致
+	assert.Equal(t, "root:root@tcp(127.0.0.1:3306)/example?loc=Local&parseTime=true", BuildConnStr("example", "root", "root", "127.0.0.1", 3306))
+}
+
+func TestRegisterTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.pem")
+	writeTestCA(t, ca)
+
+	cases := []struct {
+		opt      TLSOption
+		expected string
+	}{
+		{opt: TLSOption{}, expected: ""},
+		{opt: TLSOption{Mode: "disabled", CA: ca}, expected: "false"},
+		{opt: TLSOption{Mode: "PREFERRED"}, expected: "preferred"},
+		{opt: TLSOption{Mode: "required"}, expected: "skip-verify"},
+		{opt: TLSOption{Mode: "verify_ca", CA: ca}, expected: "heimdall-tls-"},
+		{opt: TLSOption{Mode: "verify-identity", CA: ca}, expected: "heimdall-tls-"},
+		{opt: TLSOption{CA: ca}, expected: "heimdall-tls-"},
+	}
+
+	for _, c := range cases {
+		name, err := RegisterTLSConfig(c.opt, "db.prod")
+		assert.NoError(t, err)
+		if c.expected == "heimdall-tls-" {
+			assert.True(t, strings.HasPrefix(name, c.expected))
+			assert.True(t, name != c.expected)
+		} else {
+			assert.Equal(t, c.expected, name)
+		}
+	}
+
+	// 注册的名称可以直接在连接字符串中使用
+	name, err := RegisterTLSConfig(TLSOption{Mode: "verify-identity", CA: ca}, "db.prod")
+	assert.NoError(t, err)
+	conf, err := mysql.ParseDSN(ConnOption{Host: "db.prod", Port: 3306, TLS: name}.ConnStr())
+	assert.NoError(t, err)
+	assert.Equal(t, name, conf.TLSConfig)
+
+	_, err = RegisterTLSConfig(TLSOption{Mode: "verify-all"}, "")
+	assert.True(t, err != nil)
+
+	_, err = RegisterTLSConfig(TLSOption{Mode: "verify-ca", CA: filepath.Join(dir, "missing.pem")}, "")
+	assert.True(t, err != nil)
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	assert.NoError(t, os.WriteFile(invalid, []byte("not a certificate"), 0644))
+	_, err = RegisterTLSConfig(TLSOption{Mode: "verify-ca", CA: invalid}, "")
+	assert.True(t, err != nil)
+}
+
+// writeTestCA write a self-signed CA certificate in PEM format to path
+func writeTestCA(t *testing.T, path string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "heimdall test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+}
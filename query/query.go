@@ -47,7 +47,12 @@ func QueryDB(db *sql.DB, sqlStr string, args []interface{}, queryTimeout time.Du
 
 // StreamQuery query data from MySQL database, and return the result one by one using channel
 func StreamQueryDB(db *sql.DB, sqlStr string, args []interface{}) ([]extracter.Column, <-chan map[string]interface{}, error) {
-	rows, err := db.Query(sqlStr, args...)
+	return StreamQueryDBContext(context.Background(), db, sqlStr, args)
+}
+
+// StreamQueryDBContext is the same as StreamQueryDB, but the query will be canceled when ctx is done
+func StreamQueryDBContext(ctx context.Context, db *sql.DB, sqlStr string, args []interface{}) ([]extracter.Column, <-chan map[string]interface{}, error) {
+	rows, err := db.QueryContext(ctx, sqlStr, args...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,192 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHOption is the options for connecting to MySQL through a SSH tunnel
+type SSHOption struct {
+	// Address is the ssh server address, in the form of user@host[:port]
+	Address string
+	// KeyFile is the private key file, if not set, ssh-agent and ~/.ssh/id_* will be used
+	KeyFile string
+	// KnownHostsFile is used to verify the host key of ssh server, default is ~/.ssh/known_hosts
+	KnownHostsFile string
+	// Insecure skip the host key verification
+	Insecure bool
+}
+
+var sshTunnelCount int32
+
+// RegisterSSHTunnel register a network to mysql driver, the connections to MySQL using this network
+// will be forwarded by the ssh server. It returns the network name used in DSN
+func RegisterSSHTunnel(opt SSHOption) (string, error) {
+	user, addr, ok := strings.Cut(opt.Address, "@")
+	if !ok || user == "" || addr == "" {
+		return "", fmt.Errorf("invalid ssh address %s, should be user@host[:port]", opt.Address)
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	auths, err := sshAuthMethods(opt.KeyFile)
+	if err != nil {
+		return "", err
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !opt.Insecure {
+		knownHostsFile := opt.KnownHostsFile
+		if knownHostsFile == "" {
+			knownHostsFile = filepath.Join(sshHomeDir(), "known_hosts")
+		}
+
+		if hostKeyCallback, err = knownhosts.New(knownHostsFile); err != nil {
+			return "", fmt.Errorf("load ssh known hosts failed: %w", err)
+		}
+	}
+
+	conf := &ssh.ClientConfig{User: user, Auth: auths, HostKeyCallback: hostKeyCallback}
+
+	var lock sync.Mutex
+	var client *ssh.Client
+
+	connect := func(ctx context.Context) (*ssh.Client, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("connect to ssh server %s failed: %w", addr, err)
+		}
+
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, conf)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("ssh handshake with %s failed: %w", addr, err)
+		}
+
+		return ssh.NewClient(c, chans, reqs), nil
+	}
+
+	name := fmt.Sprintf("heimdall-ssh-%d", atomic.AddInt32(&sshTunnelCount, 1))
+	mysql.RegisterDialContext(name, func(ctx context.Context, target string) (net.Conn, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if client == nil {
+			c, err := connect(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			client = c
+		}
+
+		// 以 / 开头的地址为 unix socket
+		network := "tcp"
+		if strings.HasPrefix(target, "/") {
+			network = "unix"
+		}
+
+		conn, err := client.Dial(network, target)
+		if err == nil || sshClientAlive(client) {
+			return conn, err
+		}
+
+		// ssh 连接已经断开（如网络中断、服务端重启），重新建立连接后重试一次
+		_ = client.Close()
+		client = nil
+
+		c, err := connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		client = c
+		return client.Dial(network, target)
+	})
+
+	return name, nil
+}
+
+// sshClientAlive check whether the ssh connection is still alive by sending a keepalive request
+func sshClientAlive(client *ssh.Client) bool {
+	_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
+
+// sshAuthMethods create public key auth methods from key file or ssh-agent and default key files
+func sshAuthMethods(keyFile string) ([]ssh.AuthMethod, error) {
+	if keyFile != "" {
+		signer, err := loadSSHKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	auths := make([]ssh.AuthMethod, 0)
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			auths = append(auths, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	signers := make([]ssh.Signer, 0)
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		signer, err := loadSSHKey(filepath.Join(sshHomeDir(), name))
+		if err != nil {
+			continue
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if len(signers) > 0 {
+		auths = append(auths, ssh.PublicKeys(signers...))
+	}
+
+	if len(auths) == 0 {
+		return nil, fmt.Errorf("no ssh key available, specify a private key file or start ssh-agent")
+	}
+
+	return auths, nil
+}
+
+func loadSSHKey(keyFile string) (ssh.Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ssh key failed: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		var passphraseErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseErr) {
+			return nil, fmt.Errorf("ssh key %s is protected by passphrase, please add it to ssh-agent", keyFile)
+		}
+
+		return nil, fmt.Errorf("parse ssh key %s failed: %w", keyFile, err)
+	}
+
+	return signer, nil
+}
+
+func sshHomeDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh")
+}
@@ -0,0 +1,127 @@
+package query
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SSL modes, same as the --ssl-mode option of MySQL client
+const (
+	SSLModeDisabled       = "disabled"
+	SSLModePreferred      = "preferred"
+	SSLModeRequired       = "required"
+	SSLModeVerifyCA       = "verify-ca"
+	SSLModeVerifyIdentity = "verify-identity"
+)
+
+// TLSOption is the options for MySQL TLS connection
+type TLSOption struct {
+	// Mode is one of disabled, preferred, required, verify-ca, verify-identity
+	Mode string
+	// CA is the path of CA certificate file
+	CA string
+	// Cert is the path of client certificate file
+	Cert string
+	// Key is the path of client private key file
+	Key string
+}
+
+var tlsConfigCount int32
+
+// RegisterTLSConfig register a tls config to mysql driver, and return the value of tls param used in DSN.
+// serverName is used for verifying the hostname of server certificate when mode is verify-identity
+func RegisterTLSConfig(opt TLSOption, serverName string) (string, error) {
+	mode := strings.ReplaceAll(strings.ToLower(opt.Mode), "_", "-")
+	if mode == "" {
+		switch {
+		case opt.CA != "":
+			mode = SSLModeVerifyCA
+		case opt.Cert != "":
+			mode = SSLModeRequired
+		default:
+			return "", nil
+		}
+	}
+
+	switch mode {
+	case SSLModeDisabled:
+		return "false", nil
+	case SSLModePreferred:
+		return "preferred", nil
+	case SSLModeRequired, SSLModeVerifyCA, SSLModeVerifyIdentity:
+	default:
+		return "", fmt.Errorf("invalid ssl mode %s, support %s, %s, %s, %s, %s", opt.Mode, SSLModeDisabled, SSLModePreferred, SSLModeRequired, SSLModeVerifyCA, SSLModeVerifyIdentity)
+	}
+
+	if mode == SSLModeRequired && opt.Cert == "" {
+		return "skip-verify", nil
+	}
+
+	conf := &tls.Config{ServerName: serverName}
+
+	if opt.CA != "" {
+		data, err := os.ReadFile(opt.CA)
+		if err != nil {
+			return "", fmt.Errorf("read ssl ca failed: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return "", fmt.Errorf("invalid ssl ca %s: no certificate found", opt.CA)
+		}
+
+		conf.RootCAs = pool
+	}
+
+	if opt.Cert != "" || opt.Key != "" {
+		cert, err := tls.LoadX509KeyPair(opt.Cert, opt.Key)
+		if err != nil {
+			return "", fmt.Errorf("load ssl client certificate failed: %w", err)
+		}
+
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	switch mode {
+	case SSLModeRequired:
+		conf.InsecureSkipVerify = true
+	case SSLModeVerifyCA:
+		// 只校验证书链，不校验主机名
+		conf.InsecureSkipVerify = true
+		conf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			certs := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+
+			if len(certs) == 0 {
+				return fmt.Errorf("no server certificate")
+			}
+
+			intermediates := x509.NewCertPool()
+			for _, cert := range certs[1:] {
+				intermediates.AddCert(cert)
+			}
+
+			_, err := certs[0].Verify(x509.VerifyOptions{Roots: conf.RootCAs, Intermediates: intermediates})
+			return err
+		}
+	}
+
+	name := fmt.Sprintf("heimdall-tls-%d", atomic.AddInt32(&tlsConfigCount, 1))
+	if err := mysql.RegisterTLSConfig(name, conf); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
@@ -0,0 +1,285 @@
+package reader
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/thedatashed/xlsxreader"
+	"github.com/xuri/excelize/v2"
+)
+
+// CellType is the type of cell value
+type CellType int
+
+const (
+	// CellTypeString is a text cell, all cells of csv files are text
+	CellTypeString CellType = iota
+	// CellTypeNumber is a number cell
+	CellTypeNumber
+	// CellTypeDate is a date, time or datetime cell
+	CellTypeDate
+	// CellTypeBool is a boolean cell
+	CellTypeBool
+	// CellTypeError is an error cell, such as #DIV/0!
+	CellTypeError
+	// CellTypeEmpty is an empty cell
+	CellTypeEmpty
+)
+
+func (t CellType) String() string {
+	switch t {
+	case CellTypeNumber:
+		return "number"
+	case CellTypeDate:
+		return "date"
+	case CellTypeBool:
+		return "bool"
+	case CellTypeError:
+		return "error"
+	case CellTypeEmpty:
+		return "empty"
+	}
+
+	return "string"
+}
+
+// Cell is a cell with type information, for formula cells the type and value are of the cached result
+type Cell struct {
+	// Value is the text of cell, which is the same as the value passed to FileWalker
+	Value string
+	Type  CellType
+	// Number is the value of number cells
+	Number float64
+	// Time is the value of date cells
+	Time time.Time
+	// Bool is the value of bool cells
+	Bool bool
+	// Formula is the formula of cell without the leading =, formulas of xls files are not decoded, only the cached
+	// result is available
+	Formula string
+}
+
+// StringCell create a text cell
+func StringCell(val string) Cell {
+	return Cell{Value: val, Type: ternary.If(val == "", CellTypeEmpty, CellTypeString)}
+}
+
+// NumberCell create a number cell, text is the display text of cell, the number is used if it is empty
+func NumberCell(val float64, text string) Cell {
+	if text == "" {
+		text = strconv.FormatFloat(val, 'f', -1, 64)
+	}
+
+	return Cell{Value: text, Type: CellTypeNumber, Number: val}
+}
+
+// DateCell create a date cell, text is the display text of cell
+func DateCell(val time.Time, text string) Cell {
+	if text == "" {
+		text = formatCellTime(val)
+	}
+
+	return Cell{Value: text, Type: CellTypeDate, Time: val}
+}
+
+// BoolCell create a boolean cell
+func BoolCell(val bool) Cell {
+	return Cell{Value: ternary.If(val, "TRUE", "FALSE"), Type: CellTypeBool, Bool: val}
+}
+
+// IsFormula return whether the cell is a formula cell
+func (c Cell) IsFormula() bool {
+	return c.Formula != ""
+}
+
+// Interface return the typed value of cell: float64 for numbers, time.Time for dates, bool for booleans,
+// nil for empty cells and string for others
+func (c Cell) Interface() interface{} {
+	switch c.Type {
+	case CellTypeNumber:
+		return c.Number
+	case CellTypeDate:
+		return c.Time
+	case CellTypeBool:
+		return c.Bool
+	case CellTypeEmpty:
+		return nil
+	}
+
+	return c.Value
+}
+
+// Normalized return the text of cell independent of the number format: numbers without formatting, dates in the form
+// of 2006-01-02 15:04:05 (or 2006-01-02, 15:04:05 for dates or times only) and booleans as TRUE or FALSE
+func (c Cell) Normalized() string {
+	switch c.Type {
+	case CellTypeNumber:
+		return strconv.FormatFloat(c.Number, 'f', -1, 64)
+	case CellTypeDate:
+		return formatCellTime(c.Time)
+	case CellTypeBool:
+		return ternary.If(c.Bool, "TRUE", "FALSE")
+	}
+
+	return c.Value
+}
+
+// CellValues return the text of cells
+func CellValues(cells []Cell) []string {
+	values := make([]string, len(cells))
+	for i, cell := range cells {
+		values[i] = cell.Value
+	}
+
+	return values
+}
+
+// StringCells create text cells from values
+func StringCells(values []string) []Cell {
+	cells := make([]Cell, len(values))
+	for i, val := range values {
+		cells[i] = StringCell(val)
+	}
+
+	return cells
+}
+
+// formatCellTime format the time as 2006-01-02 if it is a date without time part, or 15:04:05 if it is a time
+// without date part (before 1900-01-01 in excel), otherwise 2006-01-02 15:04:05
+func formatCellTime(t time.Time) string {
+	switch {
+	case t.Year() < 1900:
+		return t.Format("15:04:05")
+	case t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0:
+		return t.Format("2006-01-02")
+	}
+
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// excelSerialCell create a number or date cell from the serial number of excel, layout is the result of excelDateLayout
+func excelSerialCell(val float64, layout string, date1904 bool, text string) Cell {
+	if layout == "" {
+		return NumberCell(val, text)
+	}
+
+	t, err := excelize.ExcelDateToTime(val, date1904)
+	if err != nil {
+		return NumberCell(val, text)
+	}
+
+	// Excel 中的时间精度为毫秒，浮点数转换后可能存在误差
+	return DateCell(t.Round(time.Second), ternary.If(text == "", t.Round(time.Second).Format(layout), text))
+}
+
+// parseCellTime parse the ISO 8601 date or datetime, such as 2006-01-02, 2006-01-02T15:04:05 or RFC3339
+func parseCellTime(val string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(val)); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseNumberCell parse the number, it returns a text cell if val is not a number
+func parseNumberCell(val string, text string) Cell {
+	num, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+		return StringCell(text)
+	}
+
+	return NumberCell(num, text)
+}
+
+// excelRowCells create cells with type information for a row of xlsx file, row is the formatted text, raw is the raw
+// value of cells, and info is the types, the styles and the formulas of cells by column number
+func excelRowCells(f *excelize.File, row []string, raw []string, info map[int]xlsxCellInfo) []Cell {
+	date1904 := f.WorkBook != nil && f.WorkBook.WorkbookPr != nil && f.WorkBook.WorkbookPr.Date1904
+
+	cells := make([]Cell, len(row))
+	for i, text := range row {
+		if i >= len(raw) || raw[i] == "" {
+			cells[i] = StringCell(text)
+			continue
+		}
+
+		cell := info[i+1]
+		switch cell.typ {
+		case "b":
+			cells[i] = BoolCell(raw[i] == "1" || strings.EqualFold(raw[i], "true"))
+		case "d":
+			if t, ok := parseCellTime(raw[i]); ok {
+				cells[i] = DateCell(t, text)
+			} else {
+				cells[i] = StringCell(text)
+			}
+		case "e":
+			cells[i] = Cell{Type: CellTypeError}
+		case "s", "str", "inlineStr":
+			cells[i] = StringCell(text)
+		default:
+			num, err := strconv.ParseFloat(raw[i], 64)
+			if err != nil {
+				cells[i] = StringCell(text)
+				break
+			}
+
+			formatID, format := excelNumberFormat(f, cell.style)
+			cells[i] = excelSerialCell(num, excelDateLayout(uint16(formatID), format), date1904, text)
+		}
+
+		cells[i].Value = text
+		cells[i].Formula = strings.TrimPrefix(cell.formula, "=")
+	}
+
+	return cells
+}
+
+// excelNumberFormat return the number format id and the format code of custom format for the cell style
+func excelNumberFormat(f *excelize.File, style int) (int, string) {
+	if f.Styles == nil || f.Styles.CellXfs == nil || style < 0 || style >= len(f.Styles.CellXfs.Xf) {
+		return 0, ""
+	}
+
+	formatID := f.Styles.CellXfs.Xf[style].NumFmtID
+	if formatID == nil {
+		return 0, ""
+	}
+
+	if f.Styles.NumFmts != nil {
+		for _, format := range f.Styles.NumFmts.NumFmt {
+			if format.NumFmtID == *formatID {
+				return *formatID, format.FormatCode
+			}
+		}
+	}
+
+	return *formatID, ""
+}
+
+// streamCell create a cell with type information from the cell read in streaming mode
+func streamCell(cell xlsxreader.Cell) Cell {
+	var typed Cell
+	switch cell.Type {
+	case xlsxreader.TypeNumerical:
+		typed = parseNumberCell(cell.Value, cell.Value)
+	case xlsxreader.TypeDateTime:
+		if t, ok := parseCellTime(cell.Value); ok {
+			typed = DateCell(t, cell.Value)
+		} else {
+			typed = StringCell(cell.Value)
+		}
+	case xlsxreader.TypeBoolean:
+		typed = BoolCell(cell.Value == "1" || strings.EqualFold(cell.Value, "true"))
+	default:
+		typed = StringCell(cell.Value)
+	}
+
+	typed.Value = cell.Value
+	return typed
+}
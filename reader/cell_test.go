@@ -0,0 +1,98 @@
+package reader
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestCreateTypedFileWalker(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+
+	f := excelize.NewFile()
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"name", "amount", "created_at", "active", "total"}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A2", &[]interface{}{"a", 12.5, time.Date(2023, 7, 15, 10, 30, 0, 0, time.UTC), true, 25}))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "E2", "=B2*2"))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A3", &[]interface{}{"b", "n/a"}))
+	assert.NoError(t, f.SaveAs(filename))
+
+	for _, beta := range []bool{false, true} {
+		rows := make([][]Cell, 0)
+		assert.NoError(t, CreateTypedFileWalker(filename, Options{Beta: beta})(
+			func(filepath string, headers []string) error { return nil },
+			func(filepath string, id string, data []Cell) error { rows = append(rows, data); return nil },
+		))
+
+		assert.Equal(t, 2, len(rows))
+		assert.Equal(t, []interface{}{"a", 12.5, time.Date(2023, 7, 15, 10, 30, 0, 0, time.UTC), true, float64(25)}, cellInterfaces(rows[0]))
+		assert.Equal(t, []interface{}{"b", "n/a"}, cellInterfaces(rows[1]))
+		assert.Equal(t, CellTypeDate, rows[0][2].Type)
+		if !beta {
+			assert.Equal(t, "B2*2", rows[0][4].Formula)
+		}
+	}
+
+	// 文本形式读取时，单元格的值不受影响
+	var data []string
+	assert.NoError(t, CreateFileWalkerWithOptions(filename, Options{})(
+		func(filepath string, headers []string) error { return nil },
+		func(filepath string, id string, row []string) error {
+			if data == nil {
+				data = row
+			}
+			return nil
+		},
+	))
+	assert.Equal(t, "12.5", data[1])
+	assert.Equal(t, "TRUE", data[3])
+}
+
+func cellInterfaces(cells []Cell) []interface{} {
+	values := make([]interface{}, len(cells))
+	for i, cell := range cells {
+		values[i] = cell.Interface()
+	}
+
+	return values
+}
+
+func TestCreateTypedFileWalkerSharedFormula(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+
+	f := excelize.NewFile()
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"amount", "total", "percent"}))
+	for i := 2; i <= 4; i++ {
+		assert.NoError(t, f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", i), &[]interface{}{i, i * 2, 0.5}))
+	}
+
+	formulaType, ref := excelize.STCellFormulaTypeShared, "B2:B4"
+	assert.NoError(t, f.SetCellFormula("Sheet1", "B2", "=A2*2+$A$2", excelize.FormulaOpts{Type: &formulaType, Ref: &ref}))
+
+	style, err := f.NewStyle(&excelize.Style{NumFmt: 10})
+	assert.NoError(t, err)
+	assert.NoError(t, f.SetCellStyle("Sheet1", "C2", "C4", style))
+	assert.NoError(t, f.SaveAs(filename))
+
+	rows := make([][]Cell, 0)
+	assert.NoError(t, CreateTypedFileWalker(filename, Options{})(
+		func(filepath string, headers []string) error { return nil },
+		func(filepath string, id string, data []Cell) error { rows = append(rows, data); return nil },
+	))
+
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, []string{"A2*2+$A$2", "A3*2+$A$2", "A4*2+$A$2"}, []string{rows[0][1].Formula, rows[1][1].Formula, rows[2][1].Formula})
+	assert.Equal(t, CellTypeNumber, rows[2][2].Type)
+	assert.Equal(t, "50.00%", rows[2][2].Value)
+}
+
+func TestShiftFormula(t *testing.T) {
+	assert.Equal(t, "SUM(B3:C4)+$A$1+B$1+$A3", shiftFormula("SUM(A1:B2)+$A$1+A$1+$A1", 1, 2))
+	assert.Equal(t, `CONCAT("A1",B2)`, shiftFormula(`CONCAT("A1",A1)`, 1, 1))
+	assert.Equal(t, "Sheet2!B2", shiftFormula("Sheet2!A1", 1, 1))
+	assert.Equal(t, "A1", shiftFormula("A2", 0, -1))
+	assert.Equal(t, "A2", shiftFormula("A2", 0, -2))
+}
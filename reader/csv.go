@@ -0,0 +1,140 @@
+package reader
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// AutoDelimiter is the csv sepertor which means detecting the delimiter from the first lines of file,
+// it is not a valid character, so the zero value of Options still means ','
+const AutoDelimiter rune = -1
+
+// delimiterSniffSize is the size of sample used for detecting the delimiter
+const delimiterSniffSize = 16 * 1024
+
+// delimiterCandidates is the delimiters can be detected automatically
+var delimiterCandidates = []rune{',', '\t', ';', '|'}
+
+// sniffDelimiter detect the delimiter of csv content, complete means the sample is the whole content, otherwise
+// the last record may be truncated and is ignored
+//
+// The delimiter which appears the same number of times in the most records is chosen, ',' is returned if no candidate found.
+// The quote of sample must be '"', the custom quote character is excluded from the candidates
+func sniffDelimiter(sample []byte, complete bool, quote rune, comment rune) rune {
+	records := splitSampleRecords(sample, complete, comment)
+
+	best, bestFreq, bestCount := ',', 0, 0
+	for _, delimiter := range delimiterCandidates {
+		if delimiter == quote {
+			continue
+		}
+
+		counts := make(map[int]int)
+		for _, record := range records {
+			if n := countOutsideQuotes(record, byte(delimiter)); n > 0 {
+				counts[n]++
+			}
+		}
+
+		for count, freq := range counts {
+			if freq > bestFreq || (freq == bestFreq && count > bestCount) {
+				best, bestFreq, bestCount = delimiter, freq, count
+			}
+		}
+	}
+
+	return best
+}
+
+// splitSampleRecords split sample into records, the line breaks in quoted fields are not treated as the end of record
+func splitSampleRecords(sample []byte, complete bool, comment rune) [][]byte {
+	records := make([][]byte, 0)
+
+	var start int
+	var quoted bool
+	for i, b := range sample {
+		switch {
+		case b == '"':
+			quoted = !quoted
+		case b == '\n' && !quoted:
+			records = append(records, sample[start:i])
+			start = i + 1
+		}
+	}
+
+	if complete && start < len(sample) {
+		records = append(records, sample[start:])
+	}
+
+	return filterRecords(records, comment)
+}
+
+func filterRecords(records [][]byte, comment rune) [][]byte {
+	result := make([][]byte, 0, len(records))
+	for _, record := range records {
+		record = bytes.TrimRight(record, "\r")
+		if len(record) == 0 || (comment != 0 && strings.HasPrefix(string(record), string(comment))) {
+			continue
+		}
+
+		result = append(result, record)
+	}
+
+	return result
+}
+
+// countOutsideQuotes count the occurrences of delimiter which are not in quoted fields
+func countOutsideQuotes(record []byte, delimiter byte) int {
+	var count int
+	var quoted bool
+	for _, b := range record {
+		switch {
+		case b == '"':
+			quoted = !quoted
+		case b == delimiter && !quoted:
+			count++
+		}
+	}
+
+	return count
+}
+
+// quoteSwapReader swap the custom quote character with '"' in the stream, because encoding/csv only supports '"' as quote,
+// the fields read from it must be swapped back by swapQuote
+type quoteSwapReader struct {
+	r     io.Reader
+	quote byte
+}
+
+func (sr *quoteSwapReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	for i := 0; i < n; i++ {
+		switch p[i] {
+		case sr.quote:
+			p[i] = '"'
+		case '"':
+			p[i] = sr.quote
+		}
+	}
+
+	return n, err
+}
+
+// swapQuote swap the custom quote character with '"' in the field
+func swapQuote(field string, quote rune) string {
+	if !strings.ContainsRune(field, quote) && !strings.ContainsRune(field, '"') {
+		return field
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case quote:
+			return '"'
+		case '"':
+			return quote
+		}
+
+		return r
+	}, field)
+}
@@ -0,0 +1,56 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestSniffDelimiter(t *testing.T) {
+	assert.Equal(t, ',', sniffDelimiter([]byte("id,name\n1,a\n2,b\n"), true, '"', 0))
+	assert.Equal(t, ';', sniffDelimiter([]byte("id;name;remark\n1;\"a,b,c\";x\n2;b;y"), true, '"', 0))
+	assert.Equal(t, '\t', sniffDelimiter([]byte("# a,b,c,d\nid\tname\n1\ta;b\n2\tb\n3\tc|d"), true, '"', '#'))
+	assert.Equal(t, '|', sniffDelimiter([]byte("id|name|remark\n1|a|x\n2|b|y\n3|c,d,e"), false, '"', 0))
+	assert.Equal(t, ';', sniffDelimiter([]byte("id|name;x\n1|a;b\n"), true, '|', 0))
+	assert.Equal(t, ',', sniffDelimiter([]byte("id\n1\n"), true, '"', 0))
+}
+
+func TestCSVFileWalker(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.csv")
+	assert.NoError(t, os.WriteFile(filename, []byte("# comment\nid; name; remark\n1; 'a;b'; say \"hi\"\n2; 'it''s'\n"), 0644))
+
+	var headers []string
+	rows := make([][]string, 0)
+	walker := CreateFileWalkerWithOptions(filename, Options{
+		CSVSepertor:      AutoDelimiter,
+		CSVQuote:         '\'',
+		CSVComment:       '#',
+		TrimLeadingSpace: true,
+	})
+
+	assert.NoError(t, walker(
+		func(filepath string, h []string) error { headers = h; return nil },
+		func(filepath string, id string, data []string) error { rows = append(rows, data); return nil },
+	))
+
+	assert.Equal(t, []string{"id", "name", "remark"}, headers)
+	assert.Equal(t, [][]string{{"1", "a;b", `say "hi"`}, {"2", "it's"}}, rows)
+}
+
+func TestCSVDefaultDelimiter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.csv")
+	assert.NoError(t, os.WriteFile(filename, []byte("id;name;remark\n1;a,b;x\n2;c;y\n"), 0644))
+
+	// Options 的零值使用 ',' 作为分隔符，只有 AutoDelimiter 才会自动识别
+	for sepertor, expected := range map[rune][]string{0: {"id;name;remark"}, AutoDelimiter: {"id", "name", "remark"}} {
+		var headers []string
+		walker := CreateFileWalkerWithOptions(filename, Options{CSVSepertor: sepertor})
+		assert.NoError(t, walker(
+			func(filepath string, h []string) error { headers = h; return nil },
+			func(filepath string, id string, data []string) error { return nil },
+		))
+		assert.Equal(t, expected, headers)
+	}
+}
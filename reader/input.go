@@ -0,0 +1,191 @@
+package reader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/mylxsw/go-utils/array"
+)
+
+// Stdin is the file path which means reading from STDIN
+const Stdin = "-"
+
+// Supported input formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatXLS  = "xls"
+	FormatODS  = "ods"
+)
+
+// SupportedFormats is the formats of input files supported
+var SupportedFormats = []string{FormatCSV, FormatXLSX, FormatXLS, FormatODS}
+
+// formatAliases is the file extensions which are read as one of the supported formats
+var formatAliases = map[string]string{
+	"xlsm": FormatXLSX,
+	"xltx": FormatXLSX,
+	"xltm": FormatXLSX,
+}
+
+// fileFormat return the format of file, the Format option takes precedence over the file extension
+func fileFormat(filePath string, opt Options) string {
+	format := strings.ToLower(opt.Format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), ".")
+	}
+
+	if alias, ok := formatAliases[format]; ok {
+		return alias
+	}
+
+	return format
+}
+
+// IsSupported return whether the format of file is supported
+func IsSupported(filePath string, opt Options) bool {
+	return array.In(fileFormat(filePath, opt), SupportedFormats)
+}
+
+// IsCSV return whether the file is a csv file
+func IsCSV(filePath string, opt Options) bool {
+	return fileFormat(filePath, opt) == FormatCSV
+}
+
+// IsPattern return whether the path is a glob pattern or a directory, which may match multiple files
+func IsPattern(path string) bool {
+	if path == Stdin {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		// 文件名中本身可能包含 [ 等字符，只有文件不存在时才认为是 glob 表达式
+		return strings.ContainsAny(path, "*?[")
+	}
+
+	return info.IsDir()
+}
+
+// ExpandPath return the files matched by a glob pattern or in a directory (not recursive) in order of name,
+// only the files of supported formats are returned unless the Format option is specified
+func ExpandPath(path string, opt Options) ([]string, error) {
+	if !IsPattern(path) {
+		return []string{path}, nil
+	}
+
+	var matches []string
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("read directory %s failed: %w", path, err)
+		}
+
+		for _, entry := range entries {
+			matches = append(matches, filepath.Join(path, entry.Name()))
+		}
+	} else {
+		if matches, err = filepath.Glob(path); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", path, err)
+		}
+	}
+
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if strings.HasPrefix(filepath.Base(match), ".") {
+			continue
+		}
+
+		if info, err := os.Stat(match); err != nil || info.IsDir() {
+			continue
+		}
+
+		if !IsSupported(match, opt) {
+			continue
+		}
+
+		files = append(files, match)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// errorWalker return a walker which always fails with err
+func errorWalker(err error) TypedFileWalker {
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
+		return err
+	}
+}
+
+func createPatternWalker(pattern string, opt Options) TypedFileWalker {
+	files, err := ExpandPath(pattern, opt)
+	if err != nil {
+		return errorWalker(err)
+	}
+
+	if len(files) == 0 {
+		log.Warningf("no file matched %s", pattern)
+		return nil
+	}
+
+	return MergeTypedWalkers(array.Map(files, func(f string, _ int) TypedFileWalker { return createFileWalker(f, opt) })...)
+}
+
+// createStdinWalker create a walker for STDIN, the format must be specified, spreadsheet content is saved to a temporary file
+// before reading, because those formats can not be read in streaming mode.
+// STDIN can only be consumed once, so walking it again returns an error instead of an empty file
+func createStdinWalker(opt Options) TypedFileWalker {
+	var walked bool
+	walker := createStdinFormatWalker(opt)
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
+		if walked {
+			return fmt.Errorf("STDIN can only be read once")
+		}
+
+		walked = true
+		return walker(headerCB, dataCB)
+	}
+}
+
+func createStdinFormatWalker(opt Options) TypedFileWalker {
+	switch format := fileFormat(Stdin, opt); format {
+	case FormatCSV:
+		return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
+			return walkCSV(Stdin, os.Stdin, opt, headerCB, dataCB)
+		}
+	case FormatXLSX, FormatXLS, FormatODS:
+		return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
+			tmp, err := os.CreateTemp("", "heimdall-stdin-*."+format)
+			if err != nil {
+				return fmt.Errorf("create temporary file failed: %w", err)
+			}
+			defer os.Remove(tmp.Name())
+
+			if _, err := io.Copy(tmp, os.Stdin); err != nil {
+				_ = tmp.Close()
+				return fmt.Errorf("read from STDIN failed: %w", err)
+			}
+
+			if err := tmp.Close(); err != nil {
+				return err
+			}
+
+			// 回调中的文件名使用 - 代替临时文件名
+			rename := func(source string) string { return Stdin + strings.TrimPrefix(source, tmp.Name()) }
+			return createFileWalker(tmp.Name(), opt)(
+				func(source string, headers []string) error { return headerCB(rename(source), headers) },
+				func(source string, id string, data []Cell) error { return dataCB(rename(source), id, data) },
+			)
+		}
+	case "":
+		return errorWalker(fmt.Errorf("the input format is required when reading from STDIN, support %s", strings.Join(SupportedFormats, ", ")))
+	}
+
+	return errorWalker(fmt.Errorf("unsupported input format %s, support %s", opt.Format, strings.Join(SupportedFormats, ", ")))
+}
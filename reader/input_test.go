@@ -0,0 +1,67 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestExpandPath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2024-02.csv", "2024-01.csv", "2023-12.xlsx", "readme.txt", ".hidden.csv"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("id\n1\n"), 0644))
+	}
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub.csv"), 0755))
+
+	assert.True(t, IsPattern(dir))
+	assert.True(t, IsPattern(filepath.Join(dir, "*.csv")))
+	assert.False(t, IsPattern(filepath.Join(dir, "2024-01.csv")))
+	assert.False(t, IsPattern(Stdin))
+
+	files, err := ExpandPath(dir, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "2023-12.xlsx"), filepath.Join(dir, "2024-01.csv"), filepath.Join(dir, "2024-02.csv")}, files)
+
+	files, err = ExpandPath(filepath.Join(dir, "2024-*"), Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "2024-01.csv"), filepath.Join(dir, "2024-02.csv")}, files)
+
+	files, err = ExpandPath(filepath.Join(dir, "*.txt"), Options{Format: FormatCSV})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "readme.txt")}, files)
+
+	ids := make([]string, 0)
+	assert.NoError(t, CreateFileWalkerWithOptions(filepath.Join(dir, "2024-*.csv"), Options{CSVSepertor: ','})(
+		func(filepath string, headers []string) error { return nil },
+		func(filepath string, id string, data []string) error { ids = append(ids, data[0]); return nil },
+	))
+	assert.Equal(t, []string{"1", "1"}, ids)
+}
+
+func TestStdinWalkerReadOnce(t *testing.T) {
+	stdin := filepath.Join(t.TempDir(), "stdin.csv")
+	assert.NoError(t, os.WriteFile(stdin, []byte("id\n1\n2\n"), 0644))
+
+	f, err := os.Open(stdin)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	origin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = origin }()
+
+	walker := CreateFileWalkerWithOptions(Stdin, Options{Format: FormatCSV, CSVSepertor: ','})
+
+	ids := make([]string, 0)
+	headerCB := func(filepath string, headers []string) error { return nil }
+	dataCB := func(filepath string, id string, data []string) error { ids = append(ids, data[0]); return nil }
+
+	assert.NoError(t, walker(headerCB, dataCB))
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	// 再次遍历时 STDIN 已经读取完毕，返回错误而不是静默地返回空数据
+	assert.True(t, walker(headerCB, dataCB) != nil)
+	assert.Equal(t, []string{"1", "2"}, ids)
+}
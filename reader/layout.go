@@ -0,0 +1,158 @@
+package reader
+
+import (
+	"sort"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// sheetLayout is the merged cells, hidden rows and hidden columns of a sheet, which are used by the FillMerged and
+// SkipHidden options
+type sheetLayout struct {
+	// merged is the merged cell ranges sorted by the start row
+	merged []cellRange
+	// hiddenRows is the row numbers (start from 1) of hidden rows, including the rows hidden by autofilter
+	hiddenRows map[int]bool
+	// hiddenCols is the column numbers (start from 1) of hidden columns
+	hiddenCols map[int]bool
+}
+
+func newSheetLayout() *sheetLayout {
+	return &sheetLayout{hiddenRows: make(map[int]bool), hiddenCols: make(map[int]bool)}
+}
+
+// addMerged add a merged cell range, the ranges of a single cell are ignored
+func (layout *sheetLayout) addMerged(startCol, startRow, endCol, endRow int) {
+	if startCol == endCol && startRow == endRow {
+		return
+	}
+
+	layout.merged = append(layout.merged, cellRange{startCol: startCol, startRow: startRow, endCol: endCol, endRow: endRow})
+}
+
+// sort sort the merged cell ranges by the start row, which is required by mergedFiller
+func (layout *sheetLayout) sort() {
+	sort.SliceStable(layout.merged, func(i, j int) bool { return layout.merged[i].startRow < layout.merged[j].startRow })
+}
+
+// layoutWorkbook is implemented by the workbooks which can read the layout of sheets
+type layoutWorkbook interface {
+	layout(sheet string) (*sheetLayout, error)
+}
+
+// needLayout return whether the layout of sheets is required by the options
+func (opt Options) needLayout() bool {
+	return opt.FillMerged || opt.SkipHidden
+}
+
+// excelSheetLayout read the layout of a sheet in xlsx file, rows is the result of GetRows
+func excelSheetLayout(f *excelize.File, sheet string, rows [][]string) (*sheetLayout, error) {
+	layout := newSheetLayout()
+
+	cells, err := f.GetMergeCells(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	width := 0
+	for _, cell := range cells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(cell.GetStartAxis())
+		if err != nil {
+			return nil, err
+		}
+
+		endCol, endRow, err := excelize.CellNameToCoordinates(cell.GetEndAxis())
+		if err != nil {
+			return nil, err
+		}
+
+		layout.addMerged(startCol, startRow, endCol, endRow)
+		if endCol > width {
+			width = endCol
+		}
+	}
+
+	// 被自动筛选隐藏的行在文件中也被标记为隐藏行
+	for i, row := range rows {
+		if visible, err := f.GetRowVisible(sheet, i+1); err == nil && !visible {
+			layout.hiddenRows[i+1] = true
+		}
+
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	for col := 1; col <= width; col++ {
+		name, err := excelize.ColumnNumberToName(col)
+		if err != nil {
+			return nil, err
+		}
+
+		if visible, err := f.GetColVisible(sheet, name); err == nil && !visible {
+			layout.hiddenCols[col] = true
+		}
+	}
+
+	layout.sort()
+	return layout, nil
+}
+
+// mergedFiller fill the values of merged cells to all cells covered, the rows must be passed in order
+type mergedFiller struct {
+	merged []cellRange
+	next   int
+	active []filledRange
+}
+
+type filledRange struct {
+	rng cellRange
+	val Cell
+}
+
+func newMergedFiller(layout *sheetLayout) *mergedFiller {
+	return &mergedFiller{merged: layout.merged}
+}
+
+// fill return the row with the values of merged cells filled, the row passed is not modified
+func (filler *mergedFiller) fill(rowNum int, row []Cell) []Cell {
+	for ; filler.next < len(filler.merged) && filler.merged[filler.next].startRow <= rowNum; filler.next++ {
+		rng := filler.merged[filler.next]
+		// 起始行不存在时（如流式读取时的空行），合并单元格的值为空，无需填充
+		if rng.startRow != rowNum || rng.startCol > len(row) || row[rng.startCol-1].Value == "" {
+			continue
+		}
+
+		// 只填充单元格的值，公式仍然只属于第一个单元格
+		val := row[rng.startCol-1]
+		val.Formula = ""
+		filler.active = append(filler.active, filledRange{rng: rng, val: val})
+	}
+
+	active := filler.active[:0]
+	for _, item := range filler.active {
+		if item.rng.endRow >= rowNum {
+			active = append(active, item)
+		}
+	}
+	filler.active = active
+
+	if len(filler.active) == 0 {
+		return row
+	}
+
+	filled := append([]Cell{}, row...)
+	for _, item := range filler.active {
+		for len(filled) < item.rng.endCol {
+			filled = append(filled, StringCell(""))
+		}
+
+		for col := item.rng.startCol; col <= item.rng.endCol; col++ {
+			if rowNum != item.rng.startRow || col != item.rng.startCol {
+				filled[col-1] = item.val
+			}
+		}
+	}
+
+	return filled
+}
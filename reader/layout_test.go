@@ -0,0 +1,66 @@
+package reader
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadSheetLayout(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+
+	f := excelize.NewFile()
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"department", "name", "remark", "score"}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A2", &[]interface{}{"Sales", "a", "x", 1}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "B3", &[]interface{}{"b", "y", 2}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "B4", &[]interface{}{"c", "z", 3}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "A5", &[]interface{}{"HR", "d", "w", 4}))
+	assert.NoError(t, f.MergeCell("Sheet1", "A2", "A4"))
+	assert.NoError(t, f.SetColVisible("Sheet1", "C", false))
+	assert.NoError(t, f.SetRowVisible("Sheet1", 4, false))
+	assert.NoError(t, f.SaveAs(filename))
+
+	read := func(opt Options) ([]string, [][]string) {
+		var headers []string
+		rows := make([][]string, 0)
+		assert.NoError(t, CreateFileWalkerWithOptions(filename, opt)(
+			func(filepath string, h []string) error { headers = h; return nil },
+			func(filepath string, id string, data []string) error { rows = append(rows, data); return nil },
+		))
+
+		return headers, rows
+	}
+
+	headers, rows := read(Options{})
+	assert.Equal(t, []string{"department", "name", "remark", "score"}, headers)
+	assert.Equal(t, []string{"", "b", "y", "2"}, rows[1])
+
+	_, rows = read(Options{FillMerged: true})
+	assert.Equal(t, [][]string{
+		{"Sales", "a", "x", "1"},
+		{"Sales", "b", "y", "2"},
+		{"Sales", "c", "z", "3"},
+		{"HR", "d", "w", "4"},
+	}, rows)
+
+	headers, rows = read(Options{FillMerged: true, SkipHidden: true, Range: "B1:D5"})
+	assert.Equal(t, []string{"name", "score"}, headers)
+	assert.Equal(t, [][]string{{"a", "1"}, {"b", "2"}, {"d", "4"}}, rows)
+}
+
+func TestMergedFiller(t *testing.T) {
+	layout := newSheetLayout()
+	layout.addMerged(1, 1, 2, 2)
+	layout.addMerged(3, 3, 3, 3)
+	filler := newMergedFiller(layout)
+
+	row := StringCells([]string{"a"})
+	assert.Equal(t, []string{"a", "a"}, CellValues(filler.fill(1, row)))
+	assert.Equal(t, []string{"a"}, CellValues(row))
+	assert.Equal(t, []string{"a", "a", "x"}, CellValues(filler.fill(2, StringCells([]string{"", "", "x"}))))
+	assert.Equal(t, []string{"", "", "y"}, CellValues(filler.fill(3, StringCells([]string{"", "", "y"}))))
+	// 单个单元格的合并范围会被忽略
+	assert.Equal(t, 1, len(layout.merged))
+}
@@ -0,0 +1,514 @@
+package reader
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenDocument namespaces
+const (
+	odsNamespaceOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsNamespaceTable  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsNamespaceText   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// odsWorkbook is an OpenDocument spreadsheet file, the content.xml is read in streaming mode
+type odsWorkbook struct {
+	zr      *zip.ReadCloser
+	content *zip.File
+	sheets  []string
+}
+
+// openODSWorkbook open an ods file and read the names of sheets
+func openODSWorkbook(filePath string) (*odsWorkbook, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open ods file %s failed: %w", filePath, err)
+	}
+
+	wb := &odsWorkbook{zr: zr}
+	for _, f := range zr.File {
+		if f.Name == "content.xml" {
+			wb.content = f
+			break
+		}
+	}
+
+	if wb.content == nil {
+		_ = zr.Close()
+		return nil, fmt.Errorf("invalid ods file %s: content.xml not found", filePath)
+	}
+
+	if err := wb.walk(func(decoder *xml.Decoder, table xml.StartElement) error {
+		wb.sheets = append(wb.sheets, odsAttr(table, odsNamespaceTable, "name"))
+		return decoder.Skip()
+	}); err != nil {
+		_ = zr.Close()
+		return nil, fmt.Errorf("read ods file %s failed: %w", filePath, err)
+	}
+
+	return wb, nil
+}
+
+// walk call fn with each table:table element in content.xml, fn must consume the element
+func (wb *odsWorkbook) walk(fn func(decoder *xml.Decoder, table xml.StartElement) error) error {
+	r, err := wb.content.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if start, ok := token.(xml.StartElement); ok && start.Name.Space == odsNamespaceTable && start.Name.Local == "table" {
+			if err := fn(decoder, start); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (wb *odsWorkbook) Sheets() []string {
+	return wb.sheets
+}
+
+func (wb *odsWorkbook) Close() error {
+	return wb.zr.Close()
+}
+
+// errODSStop is used to stop walking after the sheet is read
+var errODSStop = errors.New("stop")
+
+func (wb *odsWorkbook) Rows(sheet string, fn func(rowNum int, row []Cell) (bool, error)) error {
+	found := false
+	err := wb.walk(func(decoder *xml.Decoder, table xml.StartElement) error {
+		if odsAttr(table, odsNamespaceTable, "name") != sheet {
+			return decoder.Skip()
+		}
+
+		found = true
+		if err := readODSTable(decoder, fn); err != nil {
+			return err
+		}
+
+		return errODSStop
+	})
+	if err != nil && !errors.Is(err, errODSStop) {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("sheet %s not found", sheet)
+	}
+
+	return nil
+}
+
+func (wb *odsWorkbook) layout(sheet string) (*sheetLayout, error) {
+	var layout *sheetLayout
+	err := wb.walk(func(decoder *xml.Decoder, table xml.StartElement) error {
+		if layout != nil || odsAttr(table, odsNamespaceTable, "name") != sheet {
+			return decoder.Skip()
+		}
+
+		var err error
+		layout, err = readODSLayout(decoder)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if layout == nil {
+		return nil, fmt.Errorf("sheet %s not found", sheet)
+	}
+
+	return layout, nil
+}
+
+// odsRowContainers is the elements which contain rows in table:table
+var odsRowContainers = map[string]bool{"table-header-rows": true, "table-rows": true, "table-row-group": true}
+
+// readODSTable read rows of a table:table element, the repeated empty rows and cells at the end are not materialized,
+// because the ods files usually end with a row repeated to the max row number of sheet
+func readODSTable(decoder *xml.Decoder, fn func(rowNum int, row []Cell) (bool, error)) error {
+	rowNum, emptyRows := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			if t.Name.Space == odsNamespaceTable && t.Name.Local == "table" {
+				return nil
+			}
+		case xml.StartElement:
+			if t.Name.Space != odsNamespaceTable || t.Name.Local != "table-row" {
+				// table:table-header-rows 与 table:table-row-group 等容器中的行也需要读取，只跳过其它内容
+				if t.Name.Space == odsNamespaceTable && odsRowContainers[t.Name.Local] {
+					continue
+				}
+
+				if err := decoder.Skip(); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			row, err := readODSRow(decoder)
+			if err != nil {
+				return err
+			}
+
+			repeated := odsRepeated(t, "number-rows-repeated", odsMaxRows)
+			if len(row) == 0 {
+				// 末尾重复的空行（如重复到 sheet 最大行号）不会被读取，只累计行数
+				if emptyRows += repeated; emptyRows > odsMaxRows {
+					emptyRows = odsMaxRows + 1
+				}
+
+				continue
+			}
+
+			if rowNum+emptyRows+repeated > odsMaxRows {
+				return fmt.Errorf("the rows exceed the maximum rows (%d) of sheet", odsMaxRows)
+			}
+
+			for i := 0; i < emptyRows; i++ {
+				rowNum++
+				if stop, err := fn(rowNum, []Cell{}); err != nil || stop {
+					return err
+				}
+			}
+
+			emptyRows = 0
+			for i := 0; i < repeated; i++ {
+				rowNum++
+				if stop, err := fn(rowNum, row); err != nil || stop {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// readODSRow read cells of a table:table-row element
+func readODSRow(decoder *xml.Decoder) ([]Cell, error) {
+	row, emptyCells := make([]Cell, 0), 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			if t.Name.Space == odsNamespaceTable && t.Name.Local == "table-row" {
+				return row, nil
+			}
+		case xml.StartElement:
+			if t.Name.Space != odsNamespaceTable || (t.Name.Local != "table-cell" && t.Name.Local != "covered-table-cell") {
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			val, err := readODSCell(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+
+			repeated := odsRepeated(t, "number-columns-repeated", odsMaxColumns)
+			if val.Value == "" {
+				if emptyCells += repeated; emptyCells > odsMaxColumns {
+					emptyCells = odsMaxColumns + 1
+				}
+
+				continue
+			}
+
+			if len(row)+emptyCells+repeated > odsMaxColumns {
+				return nil, fmt.Errorf("the columns exceed the maximum columns (%d) of sheet", odsMaxColumns)
+			}
+
+			for i := 0; i < emptyCells; i++ {
+				row = append(row, StringCell(""))
+			}
+
+			emptyCells = 0
+			for i := 0; i < repeated; i++ {
+				row = append(row, val)
+			}
+		}
+	}
+}
+
+// readODSCell read the value of a cell, the value attributes are used for numbers, dates and booleans, and the text content
+// is used for others
+func readODSCell(decoder *xml.Decoder, elem xml.StartElement) (Cell, error) {
+	text, err := readODSText(decoder, elem.Name)
+	if err != nil {
+		return Cell{}, err
+	}
+
+	var cell Cell
+	switch odsAttr(elem, odsNamespaceOffice, "value-type") {
+	case "float", "percentage", "currency":
+		val := odsAttr(elem, odsNamespaceOffice, "value")
+		cell = parseNumberCell(val, val)
+	case "date":
+		val := odsAttr(elem, odsNamespaceOffice, "date-value")
+		text := strings.Replace(strings.TrimSuffix(val, "T00:00:00"), "T", " ", 1)
+		if t, ok := parseCellTime(val); ok {
+			cell = DateCell(t, text)
+		} else {
+			cell = StringCell(text)
+		}
+	case "time":
+		cell = parseODSDuration(odsAttr(elem, odsNamespaceOffice, "time-value"))
+	case "boolean":
+		cell = BoolCell(strings.EqualFold(odsAttr(elem, odsNamespaceOffice, "boolean-value"), "true"))
+	default:
+		cell = StringCell(text)
+	}
+
+	// 公式的格式为 of:=SUM([.A1:.A3])，其中 of: 为公式语法的命名空间前缀
+	if formula := odsAttr(elem, odsNamespaceTable, "formula"); formula != "" {
+		if pos := strings.Index(formula, "="); pos >= 0 {
+			formula = formula[pos+1:]
+		}
+
+		cell.Formula = formula
+	}
+
+	return cell, nil
+}
+
+// readODSText read the text content of an element until its end, paragraphs are joined with a line break
+func readODSText(decoder *xml.Decoder, name xml.Name) (string, error) {
+	var sb strings.Builder
+	paragraphs, depth := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			if t.Name == name {
+				return sb.String(), nil
+			}
+
+			if t.Name.Space == odsNamespaceText && t.Name.Local == "p" {
+				depth--
+			}
+		case xml.CharData:
+			// 只读取段落中的文本，忽略元素之间的空白
+			if depth > 0 {
+				sb.Write(t)
+			}
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsNamespaceOffice && t.Name.Local == "annotation":
+				// 批注内容不作为单元格的值
+				if err := decoder.Skip(); err != nil {
+					return "", err
+				}
+			case t.Name.Space == odsNamespaceText && t.Name.Local == "p":
+				if paragraphs > 0 {
+					sb.WriteString("\n")
+				}
+				paragraphs++
+				depth++
+			case t.Name.Space == odsNamespaceText && t.Name.Local == "s":
+				count, err := strconv.Atoi(odsAttr(t, odsNamespaceText, "c"))
+				if err != nil || count < 1 {
+					count = 1
+				}
+
+				sb.WriteString(strings.Repeat(" ", count))
+			case t.Name.Space == odsNamespaceText && t.Name.Local == "tab":
+				sb.WriteString("\t")
+			case t.Name.Space == odsNamespaceText && t.Name.Local == "line-break":
+				sb.WriteString("\n")
+			}
+		}
+	}
+}
+
+var odsDurationRegexp = regexp.MustCompile(`^-?PT?(\d+)H(\d+)M(\d+)(\.\d+)?S$`)
+
+// parseODSDuration parse the time value such as PT12H30M00S, which is formatted as 12:30:00
+func parseODSDuration(val string) Cell {
+	matches := odsDurationRegexp.FindStringSubmatch(val)
+	if matches == nil {
+		return StringCell(val)
+	}
+
+	hour, _ := strconv.Atoi(matches[1])
+	minute, _ := strconv.Atoi(matches[2])
+	second, _ := strconv.Atoi(matches[3])
+
+	// 与 Excel 保持一致，时间使用 1899-12-30 作为日期部分
+	t := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second)
+	return DateCell(t, fmt.Sprintf("%02d:%02d:%02d", hour, minute, second))
+}
+
+func odsAttr(elem xml.StartElement, space, local string) string {
+	for _, attr := range elem.Attr {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// The maximum rows and columns of a sheet
+const (
+	odsMaxRows    = 1048576
+	odsMaxColumns = 16384
+)
+
+// odsRepeated return the repeated count of a row or cell, which is at least 1, the count larger than limit is clamped
+// to limit+1, so that callers can detect that the sheet exceeds the limit without producing billions of rows or cells
+func odsRepeated(elem xml.StartElement, attr string, limit int) int {
+	repeated, err := strconv.Atoi(odsAttr(elem, odsNamespaceTable, attr))
+	if err != nil || repeated < 1 {
+		return 1
+	}
+
+	if repeated > limit {
+		return limit + 1
+	}
+
+	return repeated
+}
+
+// odsColumnContainers is the elements which contain columns in table:table
+var odsColumnContainers = map[string]bool{"table-header-columns": true, "table-columns": true, "table-column-group": true}
+
+// readODSLayout read the merged cells, hidden rows and hidden columns of a table:table element, the rows and columns
+// hidden by filter are also marked as table:visibility="filter"
+func readODSLayout(decoder *xml.Decoder) (*sheetLayout, error) {
+	layout := newSheetLayout()
+	rowNum, colNum := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			if t.Name.Space == odsNamespaceTable && t.Name.Local == "table" {
+				layout.sort()
+				return layout, nil
+			}
+		case xml.StartElement:
+			if t.Name.Space == odsNamespaceTable && (odsRowContainers[t.Name.Local] || odsColumnContainers[t.Name.Local]) {
+				continue
+			}
+
+			switch {
+			case t.Name.Space == odsNamespaceTable && t.Name.Local == "table-column":
+				repeated, hidden := odsRepeated(t, "number-columns-repeated", odsMaxColumns), odsHidden(t)
+				for i := 0; i < repeated && colNum < odsMaxColumns; i++ {
+					colNum++
+					if hidden {
+						layout.hiddenCols[colNum] = true
+					}
+				}
+			case t.Name.Space == odsNamespaceTable && t.Name.Local == "table-row":
+				repeated := odsRepeated(t, "number-rows-repeated", odsMaxRows)
+				empty, err := readODSLayoutRow(decoder, layout, rowNum+1)
+				if err != nil {
+					return nil, err
+				}
+
+				// 重复的空行（如重复到 sheet 最大行号的末尾行）不会被读取，无需记录
+				if odsHidden(t) && (!empty || repeated == 1) {
+					for i := 1; i <= repeated && rowNum+i <= odsMaxRows; i++ {
+						layout.hiddenRows[rowNum+i] = true
+					}
+				}
+
+				rowNum += repeated
+				continue
+			}
+
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readODSLayoutRow read the merged cells of a table:table-row element, it returns whether the row is empty
+func readODSLayoutRow(decoder *xml.Decoder, layout *sheetLayout, rowNum int) (bool, error) {
+	colNum, empty := 0, true
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false, err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			if t.Name.Space == odsNamespaceTable && t.Name.Local == "table-row" {
+				return empty, nil
+			}
+		case xml.StartElement:
+			if t.Name.Space != odsNamespaceTable || (t.Name.Local != "table-cell" && t.Name.Local != "covered-table-cell") {
+				if err := decoder.Skip(); err != nil {
+					return false, err
+				}
+
+				continue
+			}
+
+			cell, err := readODSCell(decoder, t)
+			if err != nil {
+				return false, err
+			}
+
+			if cell.Value != "" {
+				empty = false
+			}
+
+			cols, rows := odsRepeated(t, "number-columns-spanned", odsMaxColumns), odsRepeated(t, "number-rows-spanned", odsMaxRows)
+			layout.addMerged(colNum+1, rowNum, colNum+cols, rowNum+rows-1)
+
+			colNum += odsRepeated(t, "number-columns-repeated", odsMaxColumns)
+		}
+	}
+}
+
+// odsHidden return whether the row or column is hidden, table:visibility is collapse for hidden and filter for the
+// rows hidden by filter
+func odsHidden(elem xml.StartElement) bool {
+	visibility := odsAttr(elem, odsNamespaceTable, "visibility")
+	return visibility == "collapse" || visibility == "filter"
+}
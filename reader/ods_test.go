@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+const odsTestContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet>
+<table:table table:name="users">
+<table:table-column table:number-columns-repeated="3"/>
+<table:table-column table:visibility="collapse"/>
+<table:table-header-rows><table:table-row>
+<table:table-cell office:value-type="string"><text:p>id</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>name</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>birthday</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>active</text:p></table:table-cell>
+</table:table-row></table:table-header-rows>
+<table:table-row table:visibility="filter">
+<table:table-cell office:value-type="float" office:value="1"><text:p>1.00</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><office:annotation><text:p>note</text:p></office:annotation><text:p>a<text:s text:c="2"/>b</text:p><text:p>c</text:p></table:table-cell>
+<table:table-cell office:value-type="date" office:date-value="2020-01-02"><text:p>01/02/20</text:p></table:table-cell>
+<table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>
+<table:table-cell table:number-columns-repeated="1020"/>
+</table:table-row>
+<table:table-row table:number-rows-repeated="2"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+<table:table-row>
+<table:table-cell table:formula="of:=SUM([.A2];1)" office:value-type="float" office:value="2" table:number-columns-spanned="2"><text:p>2</text:p></table:table-cell>
+<table:covered-table-cell/>
+<table:table-cell office:value-type="date" office:date-value="2020-01-02T10:30:00"><text:p>x</text:p></table:table-cell>
+</table:table-row>
+<table:table-row table:number-rows-repeated="1048570"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+</table:table>
+<table:table table:name="empty"><table:table-row><table:table-cell/></table:table-row></table:table>
+</office:spreadsheet></office:body>
+</office:document-content>`
+
+func TestODSWorkbook(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.ods")
+	f, err := os.Create(filename)
+	assert.NoError(t, err)
+
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("content.xml")
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(odsTestContent))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, f.Close())
+
+	wb, err := OpenWorkbook(filename, Options{})
+	assert.NoError(t, err)
+	defer wb.Close()
+
+	assert.Equal(t, []string{"users", "empty"}, wb.Sheets())
+
+	rows := make([][]string, 0)
+	cells := make([][]Cell, 0)
+	assert.NoError(t, wb.Rows("users", func(rowNum int, row []Cell) (bool, error) {
+		assert.Equal(t, len(rows)+1, rowNum)
+		rows = append(rows, CellValues(row))
+		cells = append(cells, row)
+		return false, nil
+	}))
+	assert.Equal(t, [][]string{
+		{"id", "name", "birthday", "active"},
+		{"1", "a  b\nc", "2020-01-02", "TRUE"},
+		{},
+		{},
+		{"2", "", "2020-01-02 10:30:00"},
+	}, rows)
+
+	assert.Equal(t, CellTypeNumber, cells[1][0].Type)
+	assert.Equal(t, float64(1), cells[1][0].Number)
+	assert.Equal(t, CellTypeString, cells[1][1].Type)
+	assert.Equal(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), cells[1][2].Interface())
+	assert.Equal(t, true, cells[1][3].Interface())
+	assert.Equal(t, "SUM([.A2];1)", cells[4][0].Formula)
+	assert.Equal(t, time.Date(2020, 1, 2, 10, 30, 0, 0, time.UTC), cells[4][2].Time)
+
+	layout, err := wb.(layoutWorkbook).layout("users")
+	assert.NoError(t, err)
+	assert.Equal(t, []cellRange{{startCol: 1, startRow: 5, endCol: 2, endRow: 5}}, layout.merged)
+	assert.Equal(t, map[int]bool{4: true}, layout.hiddenCols)
+	assert.Equal(t, map[int]bool{2: true}, layout.hiddenRows)
+
+	err = wb.Rows("not-exist", func(rowNum int, row []Cell) (bool, error) { return false, nil })
+	assert.True(t, err != nil)
+}
+
+func TestODSRepeatedLimit(t *testing.T) {
+	readTable := func(rows string) (int, error) {
+		decoder := xml.NewDecoder(strings.NewReader(`<table:table xmlns:table="` + odsNamespaceTable + `" xmlns:office="` + odsNamespaceOffice + `" xmlns:text="` + odsNamespaceText + `">` + rows + `</table:table>`))
+		if _, err := decoder.Token(); err != nil {
+			return 0, err
+		}
+
+		count := 0
+		return count, readODSTable(decoder, func(rowNum int, row []Cell) (bool, error) {
+			count++
+			return false, nil
+		})
+	}
+
+	cell := `<table:table-cell office:value-type="string" table:number-columns-repeated="%s"><text:p>x</text:p></table:table-cell>`
+	row := `<table:table-row table:number-rows-repeated="%s">%s</table:table-row>`
+
+	// 末尾重复的空行和空单元格不受限制
+	_, err := readTable(fmt.Sprintf(row, "1", fmt.Sprintf(cell, "16384")) + fmt.Sprintf(row, "2000000000", `<table:table-cell table:number-columns-repeated="2000000000"/>`))
+	assert.NoError(t, err)
+
+	_, err = readTable(fmt.Sprintf(row, "1", fmt.Sprintf(cell, "2000000000")))
+	assert.True(t, err != nil)
+
+	_, err = readTable(fmt.Sprintf(row, "1", `<table:table-cell table:number-columns-repeated="16384"/>`+fmt.Sprintf(cell, "1")))
+	assert.True(t, err != nil)
+
+	_, err = readTable(fmt.Sprintf(row, "2000000000", fmt.Sprintf(cell, "1")))
+	assert.True(t, err != nil)
+
+	count, err := readTable(fmt.Sprintf(row, "2000000000", "") + fmt.Sprintf(row, "1", fmt.Sprintf(cell, "1")))
+	assert.True(t, err != nil)
+	assert.Equal(t, 0, count)
+}
@@ -1,20 +1,37 @@
 package reader
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/mylxsw/asteria/log"
 	"github.com/mylxsw/go-utils/array"
+	"github.com/mylxsw/go-utils/ternary"
+	"github.com/mylxsw/heimdall/charset"
 	"github.com/thedatashed/xlsxreader"
 	"github.com/xuri/excelize/v2"
 )
 
 type FileWalker func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []string) error) error
 
+// TypedFileWalker is the same as FileWalker, but the data rows are passed as cells with type information
+type TypedFileWalker func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error
+
+// Texts convert the walker to a FileWalker which passes the text of cells
+func (walker TypedFileWalker) Texts() FileWalker {
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []string) error) error {
+		return walker(headerCB, func(filepath string, id string, data []Cell) error {
+			return dataCB(filepath, id, CellValues(data))
+		})
+	}
+}
+
 func MergeWalkers(walkers ...FileWalker) FileWalker {
 	walkers = array.Filter(walkers, func(walker FileWalker, i int) bool { return walker != nil })
 	if len(walkers) == 0 {
@@ -32,154 +49,418 @@ func MergeWalkers(walkers ...FileWalker) FileWalker {
 	}
 }
 
-func CreateFileWalker(filePath string, csvSepertor rune, onlyHeader bool, beta bool) FileWalker {
-	if strings.HasSuffix(filePath, ".xlsx") {
-		if beta {
-			return createExcelFileStreamWalker(filePath, onlyHeader)
+// MergeTypedWalkers is the same as MergeWalkers for TypedFileWalker
+func MergeTypedWalkers(walkers ...TypedFileWalker) TypedFileWalker {
+	walkers = array.Filter(walkers, func(walker TypedFileWalker, i int) bool { return walker != nil })
+	if len(walkers) == 0 {
+		return nil
+	}
+
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
+		for _, walker := range walkers {
+			if err := walker(headerCB, dataCB); err != nil {
+				return err
+			}
 		}
 
-		return createExcelFileWalker(filePath, onlyHeader)
+		return nil
+	}
+}
+
+// Options is the options for creating file walker
+type Options struct {
+	// CSVSepertor is the delimiter of csv file, AutoDelimiter means detecting from the first lines, default is ','
+	CSVSepertor rune
+	OnlyHeader  bool
+	Beta        bool
+
+	// CSVQuote is the quote character of csv file, default is '"'
+	CSVQuote rune
+	// CSVComment is the comment character, lines beginning with it are ignored
+	CSVComment rune
+	// LazyQuotes allow quotes appear in unquoted field and non-doubled quotes appear in quoted field
+	LazyQuotes bool
+	// TrimLeadingSpace ignore the leading white space of fields
+	TrimLeadingSpace bool
+
+	// Sheet is the sheet name or index (start from 1) of excel file to read, the first sheet is read if empty
+	Sheet string
+	// AllSheets read all sheets of excel file, the filepath passed to callbacks is in the form of FILE#SHEET, only the
+	// sheet specified by Sheet is read if it is not empty
+	AllSheets bool
+
+	// HeaderRow is the row number (start from 1) of the header, default is the first row of Range
+	HeaderRow int
+	// HeaderRows is the number of header rows, multi-row headers are merged into names in the form of Parent_Child
+	HeaderRows int
+	// SkipRows is the row numbers or ranges (such as 5,8-10) to skip
+	SkipRows string
+	// SkipFooter is the number of rows to skip at the end of file or sheet
+	SkipFooter int
+	// Range is the cell range to read, such as A3:K500
+	Range string
+	// Encoding is the encoding of csv file, such as gbk, utf-16le, detected automatically if empty or auto
+	Encoding string
+	// Format is the format of input files, such as csv, xlsx, detected by file extension if empty, it is required for STDIN
+	Format string
+	// FillMerged fill the value of merged cells to all cells covered, by default only the first cell has the value
+	FillMerged bool
+	// SkipHidden ignore the hidden rows and columns of excel files, including the rows hidden by autofilter
+	SkipHidden bool
+
+	// typed is set by CreateTypedFileWalker, the type information of xlsx cells is only read when it is set,
+	// because it is much slower than reading the text
+	typed bool
+}
+
+func CreateFileWalker(filePath string, csvSepertor rune, onlyHeader bool, beta bool) FileWalker {
+	return CreateFileWalkerWithOptions(filePath, Options{CSVSepertor: csvSepertor, OnlyHeader: onlyHeader, Beta: beta})
+}
+
+// CreateFileWalkerWithOptions create a file walker for xlsx, xls, ods or csv file, it returns nil if the file type is not supported
+//
+// The filePath can also be - for STDIN, a glob pattern or a directory, the matched files are read one by one
+func CreateFileWalkerWithOptions(filePath string, opt Options) FileWalker {
+	walker := createFileWalker(filePath, opt)
+	if walker == nil {
+		return nil
+	}
+
+	return walker.Texts()
+}
+
+// CreateTypedFileWalker create a file walker which passes the cells with type information, such as numbers, dates and
+// the cached values of formulas, all cells of csv files are text
+func CreateTypedFileWalker(filePath string, opt Options) TypedFileWalker {
+	opt.typed = true
+	return createFileWalker(filePath, opt)
+}
+
+func createFileWalker(filePath string, opt Options) TypedFileWalker {
+	if filePath == Stdin {
+		return createStdinWalker(opt)
 	}
 
-	if strings.HasSuffix(filePath, ".csv") {
-		return createCSVFileWalker(filePath, csvSepertor, onlyHeader)
+	if IsPattern(filePath) {
+		return createPatternWalker(filePath, opt)
+	}
+
+	switch fileFormat(filePath, opt) {
+	case FormatXLSX:
+		if opt.Beta {
+			return createExcelFileStreamWalker(filePath, opt)
+		}
+
+		return createExcelFileWalker(filePath, opt)
+	case FormatXLS, FormatODS:
+		return createWorkbookWalker(filePath, opt)
+	case FormatCSV:
+		return createCSVFileWalker(filePath, opt)
 	}
 
 	return nil
 }
 
-func createCSVFileWalker(filePath string, csvSepertor rune, onlyHeader bool) FileWalker {
-	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []string) error) error {
+// SheetSource return the source name of a sheet, which is passed to callbacks when reading all sheets
+func SheetSource(filePath string, sheet string) string {
+	return filePath + "#" + sheet
+}
+
+// selectSheets return the sheets to read according to options
+func selectSheets(filePath string, sheets []string, opt Options) ([]string, error) {
+	if len(sheets) == 0 || (opt.AllSheets && opt.Sheet == "") {
+		return sheets, nil
+	}
+
+	if opt.Sheet == "" {
+		if len(sheets) > 1 {
+			log.Warningf("file %s has more than one sheet, only the first sheet will be processed", filePath)
+		}
+
+		return sheets[:1], nil
+	}
+
+	for _, sheet := range sheets {
+		if sheet == opt.Sheet {
+			return []string{sheet}, nil
+		}
+	}
+
+	for _, sheet := range sheets {
+		if strings.EqualFold(sheet, opt.Sheet) {
+			return []string{sheet}, nil
+		}
+	}
+
+	if index, err := strconv.Atoi(opt.Sheet); err == nil && index >= 1 && index <= len(sheets) {
+		return []string{sheets[index-1]}, nil
+	}
+
+	return nil, fmt.Errorf("sheet %s not found in file %s, available sheets: %s", opt.Sheet, filePath, strings.Join(sheets, ", "))
+}
+
+func createCSVFileWalker(filePath string, opt Options) TypedFileWalker {
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
 		f, err := os.OpenFile(filePath, os.O_RDONLY, 0644)
 		if err != nil {
 			return err
 		}
+		defer f.Close()
 
-		csvReader := csv.NewReader(f)
-		csvReader.Comma = csvSepertor
-		index := 0
-		for {
-			index++
-			record, err := csvReader.Read()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
+		return walkCSV(filePath, f, opt, headerCB, dataCB)
+	}
+}
 
-				return err
-			}
+// walkCSV read csv content from in, source is the filepath passed to callbacks
+func walkCSV(
+	source string,
+	in io.Reader,
+	opt Options,
+	headerCB func(filepath string, headers []string) error,
+	dataCB func(filepath string, id string, data []Cell) error,
+) error {
+	processor, err := newRowProcessor(source, opt, headerCB, dataCB)
+	if err != nil {
+		return err
+	}
+
+	if err := readCSVRecords(source, in, opt, func(index int, record []string) (bool, error) {
+		return processor.Add(index, fmt.Sprintf("%d", index), StringCells(record))
+	}); err != nil {
+		return err
+	}
+
+	return processor.Close()
+}
+
+// WalkCSVFile call fn with each record of csv file in order, the header is not processed, index starts from 1.
+// The csv options such as CSVSepertor, CSVQuote and Encoding are used, it stops when fn returns true or an error
+func WalkCSVFile(filePath string, opt Options, fn func(index int, record []string) (stop bool, err error)) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readCSVRecords(filePath, f, opt, fn)
+}
 
-			if onlyHeader && index > 1 {
+// readCSVRecords read the records of csv content from in, source is the filepath used in logs and errors
+func readCSVRecords(source string, in io.Reader, opt Options, fn func(index int, record []string) (bool, error)) error {
+	r, encoding, err := charset.NewReader(in, ternary.If(opt.Encoding == "", charset.Auto, opt.Encoding))
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"file": source, "encoding": encoding}).Debugf("read csv file")
+
+	// encoding/csv 只支持 '"' 作为引号，其它引号字符需要与 '"' 互换后读取，读取后再换回
+	customQuote := opt.CSVQuote != 0 && opt.CSVQuote != '"'
+	if customQuote {
+		if opt.CSVQuote >= utf8.RuneSelf {
+			return fmt.Errorf("invalid quote character %q, only ascii character is supported", opt.CSVQuote)
+		}
+
+		r = &quoteSwapReader{r: r, quote: byte(opt.CSVQuote)}
+	}
+
+	sepertor := opt.CSVSepertor
+	if sepertor == 0 {
+		sepertor = ','
+	}
+
+	if sepertor == AutoDelimiter {
+		br := bufio.NewReaderSize(r, delimiterSniffSize)
+		sample, err := br.Peek(delimiterSniffSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		sepertor = sniffDelimiter(sample, err == io.EOF, opt.CSVQuote, opt.CSVComment)
+		r = br
+
+		log.WithFields(log.Fields{"file": source, "delimiter": string(sepertor)}).Debugf("detect csv delimiter")
+	}
+
+	csvReader := csv.NewReader(r)
+	csvReader.Comma = sepertor
+	csvReader.Comment = opt.CSVComment
+	csvReader.LazyQuotes = opt.LazyQuotes
+	csvReader.TrimLeadingSpace = opt.TrimLeadingSpace
+	// 允许每一行的字段数量不一致，缺失的字段由调用方处理
+	csvReader.FieldsPerRecord = -1
+
+	index := 0
+	for {
+		index++
+		record, err := csvReader.Read()
+		if err != nil {
+			if err == io.EOF {
 				break
 			}
 
-			if index == 1 {
-				if err := headerCB(filePath, array.Map(record, func(item string, _ int) string { return clean(item) })); err != nil {
-					log.WithFields(log.Fields{"file": filePath}).Errorf("handle header failed: %s", err)
-					return err
-				}
+			return fmt.Errorf("read csv file %s failed: %w", source, err)
+		}
 
-				continue
+		if customQuote {
+			for i := range record {
+				record[i] = swapQuote(record[i], opt.CSVQuote)
 			}
+		}
 
-			if err := dataCB(filePath, fmt.Sprintf("%d", index), record); err != nil {
-				log.WithFields(log.Fields{"index": index, "file": filePath}).Errorf("handle data failed: %s", err)
-			}
+		stop, err := fn(index, record)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		if stop {
+			return nil
+		}
 	}
+
+	return nil
 }
 
-func createExcelFileWalker(filePath string, onlyHeader bool) FileWalker {
-	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []string) error) error {
+func createExcelFileWalker(filePath string, opt Options) TypedFileWalker {
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
 		f, err := excelize.OpenFile(filePath)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
 
-		for i, sheet := range f.GetSheetList() {
-			rows, err := f.GetRows(sheet)
-			if err != nil {
+		sheets, err := selectSheets(filePath, f.GetSheetList(), opt)
+		if err != nil {
+			return err
+		}
+
+		for _, sheet := range sheets {
+			if err := walkExcelSheet(f, filePath, sheet, opt, headerCB, dataCB); err != nil {
 				return err
 			}
+		}
 
-			if i > 0 && len(rows) > 0 {
-				log.Warningf("file %s has more than one sheet, only the first sheet will be processed", filePath)
-				break
-			}
+		return nil
+	}
+}
 
-			if len(rows) < 2 {
-				continue
-			}
+// walkExcelSheet read the rows of a sheet in xlsx file, the types, the styles and the formulas of cells are read from
+// the sheet xml in one streaming pass when the typed cells are required
+func walkExcelSheet(
+	f *excelize.File,
+	filePath string,
+	sheet string,
+	opt Options,
+	headerCB func(filepath string, headers []string) error,
+	dataCB func(filepath string, id string, data []Cell) error,
+) error {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return err
+	}
 
-			if err := headerCB(filePath, array.Map(rows[0], func(item string, _ int) string { return clean(item) })); err != nil {
-				log.WithFields(log.Fields{"file": filePath}).Errorf("handle header failed: %s", err)
-				return err
-			}
+	var rawRows [][]string
+	var cellInfo *xlsxCellInfoReader
+	if opt.typed {
+		if rawRows, err = f.GetRows(sheet, excelize.Options{RawCellValue: true}); err != nil {
+			return err
+		}
 
-			if !onlyHeader {
-				for rowNum, row := range rows[1:] {
-					if err := dataCB(filePath, fmt.Sprintf("%s#%d", sheet, rowNum), row); err != nil {
-						log.WithFields(log.Fields{"sheet": sheet, "row": rowNum, "file": filePath}).Errorf("handle data failed: %s", err)
-					}
-				}
+		if cellInfo, err = newXLSXCellInfoReader(filePath, sheet); err != nil {
+			return fmt.Errorf("read cells of sheet %s failed: %w", sheet, err)
+		}
+		defer cellInfo.Close()
+	}
+
+	processor, err := newRowProcessor(ternary.If(opt.AllSheets, SheetSource(filePath, sheet), filePath), opt, headerCB, dataCB)
+	if err != nil {
+		return err
+	}
+
+	if opt.needLayout() {
+		layout, err := excelSheetLayout(f, sheet, rows)
+		if err != nil {
+			return fmt.Errorf("read layout of sheet %s failed: %w", sheet, err)
+		}
+
+		processor.SetLayout(layout)
+	}
+
+	for i, row := range rows {
+		cells := StringCells(row)
+		if cellInfo != nil && i < len(rawRows) {
+			info, err := cellInfo.Row(i + 1)
+			if err != nil {
+				return fmt.Errorf("read cells of sheet %s failed: %w", sheet, err)
 			}
+
+			cells = excelRowCells(f, row, rawRows[i], info)
 		}
 
-		return nil
+		stop, err := processor.Add(i+1, fmt.Sprintf("%s#%d", sheet, i+1), cells)
+		if err != nil {
+			return err
+		}
+
+		if stop {
+			break
+		}
 	}
+
+	return processor.Close()
 }
 
-func createExcelFileStreamWalker(filePath string, onlyHeader bool) FileWalker {
-	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []string) error) error {
+func createExcelFileStreamWalker(filePath string, opt Options) TypedFileWalker {
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
 		xl, err := xlsxreader.OpenFile(filePath)
 		if err != nil {
 			return err
 		}
 		defer xl.Close()
 
-		for i, sheet := range xl.Sheets {
-			var columnCount, index int
-			for row := range xl.ReadRows(sheet) {
-				index++
-				if i > 0 {
-					log.Warningf("file %s has more than one sheet, only the first sheet will be processed", filePath)
-					return nil
-				}
-
-				if onlyHeader && index > 1 {
-					break
-				}
+		sheets, err := selectSheets(filePath, xl.Sheets, opt)
+		if err != nil {
+			return err
+		}
 
-				if index == 1 {
-					values := array.Map(row.Cells, func(cell xlsxreader.Cell, _ int) string { return clean(cell.Value) })
-					columnCount = len(values)
+		if opt.needLayout() {
+			log.Warningf("merged cells and hidden rows or columns are not supported when reading xlsx file %s in streaming mode, ignored", filePath)
+		}
 
-					if err := headerCB(filePath, values); err != nil {
-						log.WithFields(log.Fields{"file": filePath}).Errorf("handle header failed: %s", err)
-						return err
-					}
+		for _, sheet := range sheets {
+			processor, err := newRowProcessor(ternary.If(opt.AllSheets, SheetSource(filePath, sheet), filePath), opt, headerCB, dataCB)
+			if err != nil {
+				return err
+			}
 
-					continue
+			for row := range xl.ReadRows(sheet) {
+				if row.Error != nil {
+					return row.Error
 				}
 
-				values := make([]string, columnCount)
+				// 流式读取时，空单元格会被忽略，需要根据单元格的列号还原为完整的行
+				values := make([]Cell, 0, len(row.Cells))
 				for i, cell := range row.Cells {
-					if cell.ColumnIndex() == -1 {
-						values[i] = cell.Value
-					} else {
-						if cell.ColumnIndex() >= columnCount {
-							continue
-						}
-						values[cell.ColumnIndex()] = cell.Value
+					index := ternary.If(cell.ColumnIndex() == -1, i, cell.ColumnIndex())
+					for len(values) <= index {
+						values = append(values, StringCell(""))
 					}
+
+					values[index] = streamCell(cell)
 				}
 
-				if err := dataCB(filePath, fmt.Sprintf("%s#%d", sheet, row.Index), values); err != nil {
-					log.WithFields(log.Fields{"index": row.Index, "file": filePath}).Errorf("handle data failed: %s", err)
+				stop, err := processor.Add(row.Index, fmt.Sprintf("%s#%d", sheet, row.Index), values)
+				if err != nil {
+					return err
 				}
+
+				if stop {
+					break
+				}
+			}
+
+			if err := processor.Close(); err != nil {
+				return err
 			}
 		}
 
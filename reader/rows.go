@@ -0,0 +1,364 @@
+package reader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/xuri/excelize/v2"
+)
+
+// cellRange is a cell range such as A3:K500, row or column is 0 if not limited
+type cellRange struct {
+	startCol, startRow int
+	endCol, endRow     int
+}
+
+var cellRefRegexp = regexp.MustCompile(`^([A-Za-z]*)(\d*)$`)
+
+// parseCellRange parse cell range in the form of A3:K500, the row numbers or column names can be omitted, such as A:K or 3:500
+func parseCellRange(val string) (*cellRange, error) {
+	segs := strings.SplitN(strings.TrimSpace(val), ":", 2)
+	if len(segs) != 2 {
+		return nil, fmt.Errorf("invalid range %s, should be in the form of A3:K500", val)
+	}
+
+	parseRef := func(ref string) (col int, row int, err error) {
+		match := cellRefRegexp.FindStringSubmatch(strings.TrimSpace(ref))
+		if match == nil || (match[1] == "" && match[2] == "") {
+			return 0, 0, fmt.Errorf("invalid range %s, should be in the form of A3:K500", val)
+		}
+
+		if match[1] != "" {
+			if col, err = excelize.ColumnNameToNumber(match[1]); err != nil {
+				return 0, 0, fmt.Errorf("invalid range %s: %w", val, err)
+			}
+		}
+
+		if match[2] != "" {
+			row, _ = strconv.Atoi(match[2])
+		}
+
+		return col, row, nil
+	}
+
+	var rng cellRange
+	var err error
+	if rng.startCol, rng.startRow, err = parseRef(segs[0]); err != nil {
+		return nil, err
+	}
+
+	if rng.endCol, rng.endRow, err = parseRef(segs[1]); err != nil {
+		return nil, err
+	}
+
+	if (rng.endCol > 0 && rng.endCol < rng.startCol) || (rng.endRow > 0 && rng.endRow < rng.startRow) {
+		return nil, fmt.Errorf("invalid range %s, the end cell should be after the start cell", val)
+	}
+
+	return &rng, nil
+}
+
+// columns return the cells of row in the range
+func (rng *cellRange) columns(row []Cell) []Cell {
+	start := rng.startCol - 1
+	if start < 0 {
+		start = 0
+	}
+
+	end := len(row)
+	if rng.endCol > 0 && rng.endCol < end {
+		end = rng.endCol
+	}
+
+	if start >= end {
+		return []Cell{}
+	}
+
+	return row[start:end]
+}
+
+// parseRowNumbers parse row numbers or ranges separated by comma, such as 5,8-10
+func parseRowNumbers(val string) (func(rowNum int) bool, error) {
+	type span struct{ from, to int }
+
+	spans := make([]span, 0)
+	for _, seg := range strings.Split(val, ",") {
+		seg = strings.TrimSpace(seg)
+		if seg == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(seg, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid row number %s", seg)
+		}
+
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil || to < from {
+				return nil, fmt.Errorf("invalid row range %s", seg)
+			}
+		}
+
+		spans = append(spans, span{from: from, to: to})
+	}
+
+	return func(rowNum int) bool {
+		for _, s := range spans {
+			if rowNum >= s.from && rowNum <= s.to {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+type pendingRow struct {
+	rowNum int
+	id     string
+	data   []Cell
+}
+
+// rowProcessor apply the header row, skipped rows, footer and range options to the raw rows of a csv file or a sheet
+type rowProcessor struct {
+	source   string
+	opt      Options
+	headerCB func(filepath string, headers []string) error
+	dataCB   func(filepath string, id string, data []Cell) error
+
+	rng        *cellRange
+	skip       func(rowNum int) bool
+	headerRow  int
+	headerRows int
+
+	headerLines [][]string
+	headerDone  bool
+	footer      []pendingRow
+
+	// layout is the merged cells and hidden rows and columns of the sheet, it is nil for csv files
+	layout *sheetLayout
+	filler *mergedFiller
+}
+
+func newRowProcessor(
+	source string,
+	opt Options,
+	headerCB func(filepath string, headers []string) error,
+	dataCB func(filepath string, id string, data []Cell) error,
+) (*rowProcessor, error) {
+	p := &rowProcessor{
+		source:     source,
+		opt:        opt,
+		headerCB:   headerCB,
+		dataCB:     dataCB,
+		skip:       func(int) bool { return false },
+		headerRow:  1,
+		headerRows: 1,
+	}
+
+	if opt.Range != "" {
+		rng, err := parseCellRange(opt.Range)
+		if err != nil {
+			return nil, err
+		}
+
+		p.rng = rng
+		if rng.startRow > 0 {
+			p.headerRow = rng.startRow
+		}
+	}
+
+	if opt.SkipRows != "" {
+		skip, err := parseRowNumbers(opt.SkipRows)
+		if err != nil {
+			return nil, err
+		}
+
+		p.skip = skip
+	}
+
+	if opt.HeaderRow > 0 {
+		p.headerRow = opt.HeaderRow
+	}
+
+	if opt.HeaderRows > 1 {
+		p.headerRows = opt.HeaderRows
+	}
+
+	return p, nil
+}
+
+// SetLayout set the layout of the sheet, which is used by the FillMerged and SkipHidden options
+func (p *rowProcessor) SetLayout(layout *sheetLayout) {
+	p.layout = layout
+	if p.opt.FillMerged {
+		p.filler = newMergedFiller(layout)
+	}
+}
+
+// Add process a raw row, rowNum is the row number in file (start from 1), it returns true if the following rows can be ignored
+func (p *rowProcessor) Add(rowNum int, id string, row []Cell) (bool, error) {
+	if p.filler != nil {
+		row = p.filler.fill(rowNum, row)
+	}
+
+	offset := 0
+	if p.rng != nil {
+		if rowNum < p.rng.startRow {
+			return false, nil
+		}
+
+		if p.rng.endRow > 0 && rowNum > p.rng.endRow {
+			return true, nil
+		}
+
+		row = p.rng.columns(row)
+		if p.rng.startCol > 1 {
+			offset = p.rng.startCol - 1
+		}
+	}
+
+	if p.opt.SkipHidden && p.layout != nil && len(p.layout.hiddenCols) > 0 {
+		row = p.visibleColumns(row, offset)
+	}
+
+	if rowNum < p.headerRow {
+		return false, nil
+	}
+
+	if !p.headerDone {
+		headerEnd := p.headerRow + p.headerRows - 1
+		if rowNum <= headerEnd {
+			p.headerLines = append(p.headerLines, CellValues(row))
+			if rowNum < headerEnd {
+				return false, nil
+			}
+
+			return p.opt.OnlyHeader, p.emitHeader()
+		}
+
+		// 流式读取时，空行会被忽略，因此表头的最后一行可能不存在
+		if err := p.emitHeader(); err != nil {
+			return false, err
+		}
+	}
+
+	if p.opt.OnlyHeader {
+		return true, nil
+	}
+
+	if p.skip(rowNum) || (p.opt.SkipHidden && p.layout != nil && p.layout.hiddenRows[rowNum]) {
+		return false, nil
+	}
+
+	if p.opt.SkipFooter <= 0 {
+		return false, p.emitData(pendingRow{rowNum: rowNum, id: id, data: row})
+	}
+
+	// 最后的 N 行需要跳过，因此数据行需要延迟 N 行才能确定是否输出
+	p.footer = append(p.footer, pendingRow{rowNum: rowNum, id: id, data: row})
+	if len(p.footer) > p.opt.SkipFooter {
+		pending := p.footer[0]
+		p.footer = p.footer[1:]
+		return false, p.emitData(pending)
+	}
+
+	return false, nil
+}
+
+// Close finish processing, the rows reserved for footer are dropped
+func (p *rowProcessor) Close() error {
+	if !p.headerDone && len(p.headerLines) > 0 {
+		return p.emitHeader()
+	}
+
+	return nil
+}
+
+// visibleColumns remove the hidden columns of row, offset is the number of columns before the first cell of row
+func (p *rowProcessor) visibleColumns(row []Cell, offset int) []Cell {
+	visible := make([]Cell, 0, len(row))
+	for i, cell := range row {
+		if !p.layout.hiddenCols[offset+i+1] {
+			visible = append(visible, cell)
+		}
+	}
+
+	return visible
+}
+
+func (p *rowProcessor) emitHeader() error {
+	p.headerDone = true
+	if err := p.headerCB(p.source, mergeHeaders(p.headerLines)); err != nil {
+		log.WithFields(log.Fields{"file": p.source}).Errorf("handle header failed: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// emitData pass the row to data callback, the error returned by callback stops walking the file
+func (p *rowProcessor) emitData(row pendingRow) error {
+	if err := p.dataCB(p.source, row.id, row.data); err != nil {
+		log.WithFields(log.Fields{"row": row.rowNum, "file": p.source}).Errorf("handle data failed: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// mergeHeaders merge multi-row headers into names in the form of Parent_Child
+//
+// The value of merged cells only exists in the first cell, so the empty cells of parent rows are filled with
+// the value on the left, until the value of the row above changes
+func mergeHeaders(lines [][]string) []string {
+	var width int
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+
+	filled := make([][]string, len(lines))
+	for r, line := range lines {
+		filled[r] = make([]string, width)
+
+		var last string
+		for c := 0; c < width; c++ {
+			var val string
+			if c < len(line) {
+				val = clean(line[c])
+			}
+
+			if r > 0 && c > 0 && filled[r-1][c] != filled[r-1][c-1] {
+				last = ""
+			}
+
+			if val == "" && r < len(lines)-1 {
+				val = last
+			}
+
+			filled[r][c] = val
+			last = val
+		}
+	}
+
+	headers := make([]string, width)
+	for c := 0; c < width; c++ {
+		parts := make([]string, 0, len(lines))
+		for r := range lines {
+			if val := filled[r][c]; val != "" && (len(parts) == 0 || parts[len(parts)-1] != val) {
+				parts = append(parts, val)
+			}
+		}
+
+		headers[c] = strings.Join(parts, "_")
+	}
+
+	return headers
+}
@@ -0,0 +1,82 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestMergeHeaders(t *testing.T) {
+	headers := mergeHeaders([][]string{
+		{"地区", "收入", "", "支出", "", "备注"},
+		{"", "金额", "占比", "金额", "占比"},
+	})
+
+	assert.Equal(t, []string{"地区", "收入_金额", "收入_占比", "支出_金额", "支出_占比", "备注"}, headers)
+	assert.Equal(t, []string{"id", "name"}, mergeHeaders([][]string{{" id", "\uFEFFname"}}))
+}
+
+func TestRowProcessor(t *testing.T) {
+	rows := [][]string{
+		{"title"},
+		{"x", "id", "name", "y"},
+		{"x", "1", "a", "y"},
+		{"x", "2", "b", "y"},
+		{"x", "3", "c", "y"},
+		{"x", "4", "d", "y"},
+		{"x", "total", "", "y"},
+	}
+
+	var headers []string
+	ids := make([]string, 0)
+	processor, err := newRowProcessor(
+		"test.csv",
+		Options{Range: "B2:C", SkipRows: "4", SkipFooter: 1},
+		func(filepath string, h []string) error { headers = h; return nil },
+		func(filepath string, id string, data []Cell) error {
+			ids = append(ids, fmt.Sprintf("%s:%s", id, data[1].Value))
+			return nil
+		},
+	)
+	assert.NoError(t, err)
+
+	for i, row := range rows {
+		stop, err := processor.Add(i+1, fmt.Sprintf("%d", i+1), StringCells(row))
+		assert.NoError(t, err)
+		assert.True(t, !stop)
+	}
+	assert.NoError(t, processor.Close())
+
+	assert.Equal(t, []string{"id", "name"}, headers)
+	assert.Equal(t, []string{"3:a", "5:c", "6:d"}, ids)
+
+	_, err = parseCellRange("A")
+	assert.True(t, err != nil)
+
+	rng, err := parseCellRange("C3:K500")
+	assert.NoError(t, err)
+	assert.Equal(t, cellRange{startCol: 3, startRow: 3, endCol: 11, endRow: 500}, *rng)
+}
+
+func TestRowProcessorDataError(t *testing.T) {
+	for _, skipFooter := range []int{0, 1} {
+		count := 0
+		err := walkCSV("test.csv", strings.NewReader("id\n1\n2\n3\n4\n"), Options{CSVSepertor: ',', SkipFooter: skipFooter},
+			func(filepath string, headers []string) error { return nil },
+			func(filepath string, id string, data []Cell) error {
+				count++
+				if data[0].Value == "2" {
+					return errors.New("insert failed")
+				}
+				return nil
+			},
+		)
+
+		// 数据回调返回错误时停止读取，并将错误返回给调用方
+		assert.True(t, err != nil)
+		assert.Equal(t, 2, count)
+	}
+}
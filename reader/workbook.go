@@ -0,0 +1,88 @@
+package reader
+
+import (
+	"fmt"
+
+	"github.com/mylxsw/go-utils/ternary"
+)
+
+// Workbook is a spreadsheet file read by sheet, it is implemented for the formats not supported by excelize, such as xls and ods
+type Workbook interface {
+	// Sheets return the names of all sheets in order
+	Sheets() []string
+	// Rows call fn with each row of the sheet in order, rowNum starts from 1, empty rows between the rows with data are included,
+	// it stops when fn returns true or an error
+	Rows(sheet string, fn func(rowNum int, row []Cell) (stop bool, err error)) error
+	Close() error
+}
+
+// IsWorkbook return whether the file is read by Workbook, such as xls and ods files
+func IsWorkbook(filePath string, opt Options) bool {
+	format := fileFormat(filePath, opt)
+	return format == FormatXLS || format == FormatODS
+}
+
+// OpenWorkbook open a xls or ods file
+func OpenWorkbook(filePath string, opt Options) (Workbook, error) {
+	switch fileFormat(filePath, opt) {
+	case FormatXLS:
+		return openXLSWorkbook(filePath)
+	case FormatODS:
+		return openODSWorkbook(filePath)
+	}
+
+	return nil, fmt.Errorf("unsupported workbook %s, only support xls and ods files", filePath)
+}
+
+func createWorkbookWalker(filePath string, opt Options) TypedFileWalker {
+	return func(headerCB func(filepath string, headers []string) error, dataCB func(filepath string, id string, data []Cell) error) error {
+		wb, err := OpenWorkbook(filePath, opt)
+		if err != nil {
+			return err
+		}
+		defer wb.Close()
+
+		sheets, err := selectSheets(filePath, wb.Sheets(), opt)
+		if err != nil {
+			return err
+		}
+
+		for _, sheet := range sheets {
+			processor, err := newRowProcessor(ternary.If(opt.AllSheets, SheetSource(filePath, sheet), filePath), opt, headerCB, dataCB)
+			if err != nil {
+				return err
+			}
+
+			if lw, ok := wb.(layoutWorkbook); ok && opt.needLayout() {
+				layout, err := lw.layout(sheet)
+				if err != nil {
+					return fmt.Errorf("read layout of sheet %s failed: %w", sheet, err)
+				}
+
+				processor.SetLayout(layout)
+			}
+
+			if err := wb.Rows(sheet, func(rowNum int, row []Cell) (bool, error) {
+				return processor.Add(rowNum, fmt.Sprintf("%s#%d", sheet, rowNum), row)
+			}); err != nil {
+				return err
+			}
+
+			if err := processor.Close(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// trimRow remove the empty cells at the end of row
+func trimRow(row []Cell) []Cell {
+	end := len(row)
+	for end > 0 && row[end-1].Value == "" {
+		end--
+	}
+
+	return row[:end]
+}
@@ -0,0 +1,752 @@
+package reader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// BIFF8 record types, see https://learn.microsoft.com/en-us/openspecs/office_file_formats/ms-xls
+const (
+	xlsRecordEOF          = 0x000A
+	xlsRecordFormula      = 0x0006
+	xlsRecordDateMode     = 0x0022
+	xlsRecordContinue     = 0x003C
+	xlsRecordColInfo      = 0x007D
+	xlsRecordBoundSheet   = 0x0085
+	xlsRecordMulRK        = 0x00BD
+	xlsRecordRString      = 0x00D6
+	xlsRecordXF           = 0x00E0
+	xlsRecordMergedCells  = 0x00E5
+	xlsRecordSST          = 0x00FC
+	xlsRecordLabelSST     = 0x00FD
+	xlsRecordNumber       = 0x0203
+	xlsRecordLabel        = 0x0204
+	xlsRecordBoolErr      = 0x0205
+	xlsRecordString       = 0x0207
+	xlsRecordRow          = 0x0208
+	xlsRecordRK           = 0x027E
+	xlsRecordFormat       = 0x041E
+	xlsRecordBOF          = 0x0809
+	xlsBIFF8Version       = 0x0600
+	xlsSheetTypeWorksheet = 0x00
+)
+
+type xlsSheet struct {
+	name   string
+	offset int
+	hidden bool
+}
+
+// xlsWorkbook is a legacy excel 97-2003 file in BIFF8 format
+type xlsWorkbook struct {
+	stream   []byte
+	sheets   []xlsSheet
+	sst      []string
+	xfs      []uint16
+	formats  map[uint16]string
+	date1904 bool
+}
+
+// openXLSWorkbook open a xls file, the Workbook stream is read from the compound file and parsed
+func openXLSWorkbook(filePath string) (*xlsWorkbook, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	doc, err := mscfb.New(f)
+	if err != nil {
+		return nil, fmt.Errorf("open xls file %s failed: %w", filePath, err)
+	}
+
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		switch entry.Name {
+		case "Workbook":
+			stream, err := io.ReadAll(entry)
+			if err != nil {
+				return nil, fmt.Errorf("read xls file %s failed: %w", filePath, err)
+			}
+
+			wb, err := parseXLSWorkbook(stream)
+			if err != nil {
+				return nil, fmt.Errorf("parse xls file %s failed: %w", filePath, err)
+			}
+
+			return wb, nil
+		case "Book":
+			return nil, fmt.Errorf("xls file %s is in BIFF5 format (Excel 5.0/95), only Excel 97-2003 format is supported", filePath)
+		}
+	}
+
+	return nil, fmt.Errorf("invalid xls file %s: workbook stream not found", filePath)
+}
+
+// xlsRecord is a record of BIFF8 stream
+type xlsRecord struct {
+	typ  uint16
+	data []byte
+}
+
+// readXLSRecord read the record at offset, and return the offset of next record
+func readXLSRecord(stream []byte, offset int) (xlsRecord, int, error) {
+	if offset+4 > len(stream) {
+		return xlsRecord{}, 0, io.ErrUnexpectedEOF
+	}
+
+	typ := binary.LittleEndian.Uint16(stream[offset:])
+	size := int(binary.LittleEndian.Uint16(stream[offset+2:]))
+	if offset+4+size > len(stream) {
+		return xlsRecord{}, 0, io.ErrUnexpectedEOF
+	}
+
+	return xlsRecord{typ: typ, data: stream[offset+4 : offset+4+size]}, offset + 4 + size, nil
+}
+
+// readXLSRecords read records from offset until the EOF record, the CONTINUE records are passed to fn as they are
+func readXLSRecords(stream []byte, offset int, fn func(rec xlsRecord) error) error {
+	for offset < len(stream) {
+		rec, next, err := readXLSRecord(stream, offset)
+		if err != nil {
+			return err
+		}
+
+		if rec.typ == xlsRecordEOF {
+			return nil
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+
+		offset = next
+	}
+
+	return nil
+}
+
+// parseXLSWorkbook parse the globals substream of workbook, the sheets are parsed when reading
+func parseXLSWorkbook(stream []byte) (*xlsWorkbook, error) {
+	bof, offset, err := readXLSRecord(stream, 0)
+	if err != nil || bof.typ != xlsRecordBOF || len(bof.data) < 2 {
+		return nil, errors.New("invalid workbook stream")
+	}
+
+	if binary.LittleEndian.Uint16(bof.data) != xlsBIFF8Version {
+		return nil, errors.New("only Excel 97-2003 (BIFF8) format is supported")
+	}
+
+	wb := &xlsWorkbook{stream: stream, formats: make(map[uint16]string)}
+
+	// SST 记录的内容可能通过多个 CONTINUE 记录延续
+	var sstChunks [][]byte
+	var lastType uint16
+	err = readXLSRecords(stream, offset, func(rec xlsRecord) error {
+		if rec.typ == xlsRecordContinue {
+			if lastType == xlsRecordSST {
+				sstChunks = append(sstChunks, rec.data)
+			}
+
+			return nil
+		}
+
+		lastType = rec.typ
+		switch rec.typ {
+		case xlsRecordBoundSheet:
+			if len(rec.data) < 8 {
+				return errors.New("invalid BOUNDSHEET record")
+			}
+
+			if rec.data[5] != xlsSheetTypeWorksheet {
+				return nil
+			}
+
+			r := newXLSDataReader(rec.data[6:])
+			name, err := r.shortString()
+			if err != nil {
+				return err
+			}
+
+			wb.sheets = append(wb.sheets, xlsSheet{
+				name:   name,
+				offset: int(binary.LittleEndian.Uint32(rec.data)),
+				hidden: rec.data[4]&0x03 != 0,
+			})
+		case xlsRecordSST:
+			sstChunks = [][]byte{rec.data}
+		case xlsRecordXF:
+			if len(rec.data) >= 4 {
+				wb.xfs = append(wb.xfs, binary.LittleEndian.Uint16(rec.data[2:]))
+			}
+		case xlsRecordFormat:
+			if len(rec.data) >= 2 {
+				r := newXLSDataReader(rec.data[2:])
+				format, err := r.string()
+				if err != nil {
+					return err
+				}
+
+				wb.formats[binary.LittleEndian.Uint16(rec.data)] = format
+			}
+		case xlsRecordDateMode:
+			wb.date1904 = len(rec.data) >= 2 && binary.LittleEndian.Uint16(rec.data) == 1
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sstChunks) > 0 {
+		if wb.sst, err = parseXLSSST(sstChunks); err != nil {
+			return nil, err
+		}
+	}
+
+	return wb, nil
+}
+
+// parseXLSSST parse the shared string table
+func parseXLSSST(chunks [][]byte) ([]string, error) {
+	r := newXLSDataReader(chunks...)
+	if _, err := r.uint32(); err != nil {
+		return nil, err
+	}
+
+	count, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	// count 来自文件，不能直接用于分配内存，每个字符串至少占用 3 个字节（长度和选项标记）
+	capacity := r.remaining() / 3
+	if uint64(count) < uint64(capacity) {
+		capacity = int(count)
+	}
+
+	strs := make([]string, 0, capacity)
+	for i := uint32(0); i < count; i++ {
+		str, err := r.string()
+		if err != nil {
+			return nil, fmt.Errorf("invalid shared string table: %w", err)
+		}
+
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+func (wb *xlsWorkbook) Sheets() []string {
+	names := make([]string, 0, len(wb.sheets))
+	for _, sheet := range wb.sheets {
+		names = append(names, sheet.name)
+	}
+
+	return names
+}
+
+func (wb *xlsWorkbook) Close() error {
+	return nil
+}
+
+func (wb *xlsWorkbook) Rows(sheet string, fn func(rowNum int, row []Cell) (bool, error)) error {
+	for _, s := range wb.sheets {
+		if s.name != sheet {
+			continue
+		}
+
+		rows, _, err := wb.readSheet(s)
+		if err != nil {
+			return fmt.Errorf("read sheet %s failed: %w", sheet, err)
+		}
+
+		for i, row := range rows {
+			stop, err := fn(i+1, row)
+			if err != nil {
+				return err
+			}
+
+			if stop {
+				break
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("sheet %s not found", sheet)
+}
+
+func (wb *xlsWorkbook) layout(sheet string) (*sheetLayout, error) {
+	for _, s := range wb.sheets {
+		if s.name == sheet {
+			_, layout, err := wb.readSheet(s)
+			return layout, err
+		}
+	}
+
+	return nil, fmt.Errorf("sheet %s not found", sheet)
+}
+
+// readSheet read all cells and the layout of the sheet
+func (wb *xlsWorkbook) readSheet(sheet xlsSheet) ([][]Cell, *sheetLayout, error) {
+	layout := newSheetLayout()
+	rows := make([][]Cell, 0)
+	setCell := func(row, col int, val Cell) {
+		for len(rows) <= row {
+			rows = append(rows, []Cell{})
+		}
+
+		for len(rows[row]) <= col {
+			rows[row] = append(rows[row], StringCell(""))
+		}
+
+		rows[row][col] = val
+	}
+
+	bof, offset, err := readXLSRecord(wb.stream, sheet.offset)
+	if err != nil || bof.typ != xlsRecordBOF {
+		return nil, nil, errors.New("invalid sheet substream")
+	}
+
+	// 字符串类型的公式结果保存在 FORMULA 之后的 STRING 记录中
+	pendingRow, pendingCol := -1, -1
+	var stringChunks [][]byte
+	flushString := func() error {
+		if stringChunks == nil {
+			return nil
+		}
+
+		str, err := newXLSDataReader(stringChunks...).string()
+		if err != nil {
+			return err
+		}
+
+		setCell(pendingRow, pendingCol, StringCell(str))
+		pendingRow, pendingCol, stringChunks = -1, -1, nil
+		return nil
+	}
+
+	err = readXLSRecords(wb.stream, offset, func(rec xlsRecord) error {
+		if rec.typ == xlsRecordContinue {
+			if stringChunks != nil {
+				stringChunks = append(stringChunks, rec.data)
+			}
+
+			return nil
+		}
+
+		if err := flushString(); err != nil {
+			return err
+		}
+
+		if rec.typ == xlsRecordString {
+			if pendingRow >= 0 {
+				stringChunks = [][]byte{rec.data}
+			}
+
+			return nil
+		}
+
+		if len(rec.data) < 6 {
+			return nil
+		}
+
+		row, col, xf := int(binary.LittleEndian.Uint16(rec.data)), int(binary.LittleEndian.Uint16(rec.data[2:])), binary.LittleEndian.Uint16(rec.data[4:])
+
+		switch rec.typ {
+		case xlsRecordRow:
+			// 被隐藏（包括被自动筛选隐藏）的行，flags 中的 fDyZero 位为 1
+			if len(rec.data) >= 16 && binary.LittleEndian.Uint32(rec.data[12:])&0x20 != 0 {
+				layout.hiddenRows[row+1] = true
+			}
+		case xlsRecordColInfo:
+			if len(rec.data) >= 10 && binary.LittleEndian.Uint16(rec.data[8:])&0x01 != 0 {
+				// COLINFO 的前两个字段为起始列和结束列
+				for c := int(binary.LittleEndian.Uint16(rec.data)); c <= int(binary.LittleEndian.Uint16(rec.data[2:])) && c < 256; c++ {
+					layout.hiddenCols[c+1] = true
+				}
+			}
+		case xlsRecordMergedCells:
+			count := int(binary.LittleEndian.Uint16(rec.data))
+			for i, pos := 0, 2; i < count && pos+8 <= len(rec.data); i, pos = i+1, pos+8 {
+				layout.addMerged(
+					int(binary.LittleEndian.Uint16(rec.data[pos+4:]))+1,
+					int(binary.LittleEndian.Uint16(rec.data[pos:]))+1,
+					int(binary.LittleEndian.Uint16(rec.data[pos+6:]))+1,
+					int(binary.LittleEndian.Uint16(rec.data[pos+2:]))+1,
+				)
+			}
+		case xlsRecordLabelSST:
+			if len(rec.data) >= 10 {
+				if index := int(binary.LittleEndian.Uint32(rec.data[6:])); index < len(wb.sst) {
+					setCell(row, col, StringCell(wb.sst[index]))
+				}
+			}
+		case xlsRecordLabel, xlsRecordRString:
+			str, err := newXLSDataReader(rec.data[6:]).string()
+			if err != nil {
+				return err
+			}
+
+			setCell(row, col, StringCell(str))
+		case xlsRecordNumber:
+			if len(rec.data) >= 14 {
+				setCell(row, col, wb.numberCell(xf, math.Float64frombits(binary.LittleEndian.Uint64(rec.data[6:]))))
+			}
+		case xlsRecordRK:
+			if len(rec.data) >= 10 {
+				setCell(row, col, wb.numberCell(xf, decodeRK(binary.LittleEndian.Uint32(rec.data[6:]))))
+			}
+		case xlsRecordMulRK:
+			// MULRK 中 xf 的位置为第一个单元格的 xf，后续每 6 个字节为一个单元格
+			for i, pos := 0, 4; pos+6 <= len(rec.data)-2; i, pos = i+1, pos+6 {
+				cellXF := binary.LittleEndian.Uint16(rec.data[pos:])
+				setCell(row, col+i, wb.numberCell(cellXF, decodeRK(binary.LittleEndian.Uint32(rec.data[pos+2:]))))
+			}
+		case xlsRecordBoolErr:
+			if len(rec.data) >= 8 {
+				setCell(row, col, xlsBoolErrCell(rec.data[6], rec.data[7] == 1))
+			}
+		case xlsRecordFormula:
+			if len(rec.data) < 14 {
+				return nil
+			}
+
+			result := rec.data[6:14]
+			if result[6] != 0xFF || result[7] != 0xFF {
+				setCell(row, col, wb.numberCell(xf, math.Float64frombits(binary.LittleEndian.Uint64(result))))
+				return nil
+			}
+
+			switch result[0] {
+			case 0:
+				pendingRow, pendingCol = row, col
+			case 1:
+				setCell(row, col, xlsBoolErrCell(result[2], false))
+			case 2:
+				setCell(row, col, xlsBoolErrCell(result[2], true))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := flushString(); err != nil {
+		return nil, nil, err
+	}
+
+	// 与 excelize 的 GetRows 保持一致，去掉行尾的空单元格以及末尾的空行
+	end := 0
+	for i := range rows {
+		if rows[i] = trimRow(rows[i]); len(rows[i]) > 0 {
+			end = i + 1
+		}
+	}
+
+	layout.sort()
+	return rows[:end], layout, nil
+}
+
+// decodeRK decode the RK number, which is a compressed floating point number or integer
+func decodeRK(rk uint32) float64 {
+	var val float64
+	if rk&0x02 != 0 {
+		val = float64(int32(rk) >> 2)
+	} else {
+		val = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+
+	if rk&0x01 != 0 {
+		val /= 100
+	}
+
+	return val
+}
+
+func xlsBoolErrCell(val byte, isError bool) Cell {
+	if !isError {
+		return BoolCell(val != 0)
+	}
+
+	return Cell{Value: xlsErrorText(val), Type: CellTypeError}
+}
+
+func xlsErrorText(val byte) string {
+	switch val {
+	case 0x00:
+		return "#NULL!"
+	case 0x07:
+		return "#DIV/0!"
+	case 0x0F:
+		return "#VALUE!"
+	case 0x17:
+		return "#REF!"
+	case 0x1D:
+		return "#NAME?"
+	case 0x24:
+		return "#NUM!"
+	case 0x2A:
+		return "#N/A"
+	}
+
+	return "#ERROR!"
+}
+
+// numberCell create a number or date cell according to the number format of the cell, dates are formatted as 2006-01-02 15:04:05
+func (wb *xlsWorkbook) numberCell(xf uint16, val float64) Cell {
+	var formatID uint16
+	if int(xf) < len(wb.xfs) {
+		formatID = wb.xfs[xf]
+	}
+
+	return excelSerialCell(val, excelDateLayout(formatID, wb.formats[formatID]), wb.date1904, "")
+}
+
+// excelDateLayout return the layout for formatting date if the number format is a date or time format, otherwise return empty
+func excelDateLayout(formatID uint16, format string) string {
+	switch {
+	case formatID >= 14 && formatID <= 17, formatID >= 27 && formatID <= 31, formatID == 36, formatID >= 50 && formatID <= 54, formatID == 57, formatID == 58:
+		return "2006-01-02"
+	case formatID >= 18 && formatID <= 21, formatID >= 32 && formatID <= 35, formatID >= 45 && formatID <= 47, formatID == 55, formatID == 56:
+		return "15:04:05"
+	case formatID == 22:
+		return "2006-01-02 15:04:05"
+	case formatID < 164 || format == "":
+		return ""
+	}
+
+	// 去掉引号中的文本、转义字符以及 [Red] 等方括号中的内容之后，根据年月日时分秒的占位符来判断
+	var cleaned strings.Builder
+	var quoted, bracket bool
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		switch {
+		case ch == '"':
+			quoted = !quoted
+		case quoted:
+		case ch == '\\' || ch == '_' || ch == '*':
+			i++
+		case ch == '[':
+			bracket = true
+		case ch == ']':
+			bracket = false
+		case bracket:
+		default:
+			cleaned.WriteByte(ch)
+		}
+	}
+
+	lower := strings.ToLower(cleaned.String())
+	if lower == "general" || strings.ContainsAny(lower, "0#?") {
+		return ""
+	}
+
+	hasDate := strings.ContainsAny(lower, "yd") || strings.Contains(lower, "m") && !strings.ContainsAny(lower, "hs")
+	hasTime := strings.ContainsAny(lower, "hs")
+	switch {
+	case hasDate && hasTime:
+		return "2006-01-02 15:04:05"
+	case hasDate:
+		return "2006-01-02"
+	case hasTime:
+		return "15:04:05"
+	}
+
+	return ""
+}
+
+// xlsDataReader read data from a record and its CONTINUE records, the characters of strings split into CONTINUE records
+// start with a new option flags byte
+type xlsDataReader struct {
+	chunks [][]byte
+	index  int
+	pos    int
+}
+
+func newXLSDataReader(chunks ...[]byte) *xlsDataReader {
+	return &xlsDataReader{chunks: chunks}
+}
+
+// next move to the next chunk if the current chunk is finished
+func (r *xlsDataReader) next() bool {
+	for r.index < len(r.chunks) && r.pos >= len(r.chunks[r.index]) {
+		r.index, r.pos = r.index+1, 0
+	}
+
+	return r.index < len(r.chunks)
+}
+
+// remaining return the number of bytes not read yet
+func (r *xlsDataReader) remaining() int {
+	if r.index >= len(r.chunks) {
+		return 0
+	}
+
+	n := len(r.chunks[r.index]) - r.pos
+	for _, chunk := range r.chunks[r.index+1:] {
+		n += len(chunk)
+	}
+
+	return n
+}
+
+func (r *xlsDataReader) bytes(n int) ([]byte, error) {
+	// n 可能来自损坏的文件，分配内存前先检查剩余的数据是否足够
+	if n < 0 || n > r.remaining() {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	buf := make([]byte, 0, n)
+	for len(buf) < n {
+		if !r.next() {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		chunk := r.chunks[r.index][r.pos:]
+		size := n - len(buf)
+		if size > len(chunk) {
+			size = len(chunk)
+		}
+
+		buf = append(buf, chunk[:size]...)
+		r.pos += size
+	}
+
+	return buf, nil
+}
+
+func (r *xlsDataReader) byte() (byte, error) {
+	buf, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+func (r *xlsDataReader) uint16() (uint16, error) {
+	buf, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint16(buf), nil
+}
+
+func (r *xlsDataReader) uint32() (uint32, error) {
+	buf, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+// shortString read a ShortXLUnicodeString, whose length is 1 byte
+func (r *xlsDataReader) shortString() (string, error) {
+	cch, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+
+	flags, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+
+	return r.chars(int(cch), flags)
+}
+
+// string read a XLUnicodeRichExtendedString (or XLUnicodeString without rich text and extended data)
+func (r *xlsDataReader) string() (string, error) {
+	cch, err := r.uint16()
+	if err != nil {
+		return "", err
+	}
+
+	flags, err := r.byte()
+	if err != nil {
+		return "", err
+	}
+
+	var runs uint16
+	var extSize uint32
+	if flags&0x08 != 0 {
+		if runs, err = r.uint16(); err != nil {
+			return "", err
+		}
+	}
+
+	if flags&0x04 != 0 {
+		if extSize, err = r.uint32(); err != nil {
+			return "", err
+		}
+	}
+
+	str, err := r.chars(int(cch), flags)
+	if err != nil {
+		return "", err
+	}
+
+	// 跳过富文本格式以及扩展数据
+	if skip := int64(runs)*4 + int64(extSize); skip > int64(r.remaining()) {
+		return "", io.ErrUnexpectedEOF
+	} else if _, err := r.bytes(int(skip)); err != nil {
+		return "", err
+	}
+
+	return str, nil
+}
+
+// chars read n characters, the characters are 1 byte if the fHighByte bit of flags is not set, otherwise UTF-16LE
+func (r *xlsDataReader) chars(n int, flags byte) (string, error) {
+	if n > r.remaining() {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	runes := make([]uint16, 0, n)
+	for len(runes) < n {
+		if !r.next() {
+			return "", io.ErrUnexpectedEOF
+		}
+
+		// 字符跨越 CONTINUE 记录时，新记录的第一个字节为新的选项标记
+		if r.pos == 0 && len(runes) > 0 {
+			flags = r.chunks[r.index][0]
+			r.pos = 1
+			continue
+		}
+
+		if flags&0x01 == 0 {
+			b, err := r.byte()
+			if err != nil {
+				return "", err
+			}
+
+			runes = append(runes, uint16(b))
+		} else {
+			ch, err := r.uint16()
+			if err != nil {
+				return "", err
+			}
+
+			runes = append(runes, ch)
+		}
+	}
+
+	return string(utf16.Decode(runes)), nil
+}
@@ -0,0 +1,140 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+type xlsTestStream struct {
+	bytes.Buffer
+}
+
+func (s *xlsTestStream) record(typ uint16, data ...interface{}) {
+	var body bytes.Buffer
+	for _, d := range data {
+		_ = binary.Write(&body, binary.LittleEndian, d)
+	}
+
+	_ = binary.Write(&s.Buffer, binary.LittleEndian, typ)
+	_ = binary.Write(&s.Buffer, binary.LittleEndian, uint16(body.Len()))
+	s.Write(body.Bytes())
+}
+
+func TestParseXLSWorkbook(t *testing.T) {
+	var sheet xlsTestStream
+	sheet.record(xlsRecordBOF, uint16(xlsBIFF8Version), uint16(0x10), make([]byte, 12))
+	sheet.record(xlsRecordLabelSST, uint16(0), uint16(0), uint16(0), uint32(0))
+	sheet.record(xlsRecordLabelSST, uint16(0), uint16(1), uint16(0), uint32(1))
+	sheet.record(xlsRecordLabelSST, uint16(0), uint16(2), uint16(0), uint32(2))
+	sheet.record(xlsRecordNumber, uint16(2), uint16(0), uint16(0), math.Float64bits(1.5))
+	sheet.record(xlsRecordRK, uint16(2), uint16(1), uint16(1), uint32(43831<<2|0x02))
+	sheet.record(xlsRecordMulRK, uint16(2), uint16(2), uint16(0), uint32(150<<2|0x03), uint16(2), uint32(0x3FE00000), uint16(3))
+	sheet.record(xlsRecordFormula, uint16(3), uint16(0), uint16(0), []byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF}, make([]byte, 6))
+	sheet.record(xlsRecordString, uint16(5), byte(0), []byte("he"))
+	sheet.record(xlsRecordContinue, byte(1), []byte{'l', 0, 'l', 0, 'o', 0})
+	sheet.record(xlsRecordBoolErr, uint16(3), uint16(1), uint16(0), byte(1), byte(0))
+	sheet.record(xlsRecordBoolErr, uint16(3), uint16(2), uint16(0), byte(0x07), byte(1))
+	sheet.record(xlsRecordRow, uint16(3), uint16(0), uint16(3), uint16(255), uint16(0), uint16(0), uint32(0x20))
+	sheet.record(xlsRecordColInfo, uint16(2), uint16(3), uint16(2048), uint16(0), uint16(1), uint16(0))
+	sheet.record(xlsRecordMergedCells, uint16(1), uint16(2), uint16(3), uint16(0), uint16(1))
+	sheet.record(xlsRecordEOF)
+
+	var globals xlsTestStream
+	globals.record(xlsRecordBOF, uint16(xlsBIFF8Version), uint16(0x05), make([]byte, 12))
+	globals.record(xlsRecordDateMode, uint16(0))
+	globals.record(xlsRecordFormat, uint16(164), uint16(10), byte(0), []byte("yyyy/mm/dd"))
+	globals.record(xlsRecordXF, uint16(0), uint16(0), make([]byte, 16))
+	globals.record(xlsRecordXF, uint16(0), uint16(164), make([]byte, 16))
+	globals.record(xlsRecordXF, uint16(0), uint16(20), make([]byte, 16))
+	// 第三个字符串跨越 CONTINUE 记录，并且在新记录中使用 UTF-16 编码
+	globals.record(xlsRecordSST, uint32(3), uint32(3),
+		uint16(2), byte(0), []byte("id"),
+		uint16(4), byte(0x08), uint16(1), []byte("name"), make([]byte, 4),
+		uint16(4), byte(0), []byte("da"))
+	globals.record(xlsRecordContinue, byte(1), []byte{'t', 0, 0xE5, 0x65})
+
+	boundSheetSize := 4 + 8 + len("data")
+	eofSize := 4
+	offset := globals.Len() + boundSheetSize + eofSize
+	globals.record(xlsRecordBoundSheet, uint32(offset), byte(0), byte(xlsSheetTypeWorksheet), byte(4), byte(0), []byte("data"))
+	globals.record(xlsRecordEOF)
+
+	wb, err := parseXLSWorkbook(append(globals.Bytes(), sheet.Bytes()...))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data"}, wb.Sheets())
+	assert.Equal(t, []string{"id", "name", "dat日"}, wb.sst)
+
+	rows := make([][]string, 0)
+	cells := make([][]Cell, 0)
+	assert.NoError(t, wb.Rows("data", func(rowNum int, row []Cell) (bool, error) {
+		rows = append(rows, CellValues(row))
+		cells = append(cells, row)
+		return false, nil
+	}))
+	assert.Equal(t, [][]string{
+		{"id", "name", "dat日"},
+		{},
+		{"1.5", "2020-01-01", "1.5", "12:00:00"},
+		{"hello", "TRUE", "#DIV/0!"},
+	}, rows)
+
+	assert.Equal(t, []CellType{CellTypeNumber, CellTypeDate, CellTypeNumber, CellTypeDate}, []CellType{cells[2][0].Type, cells[2][1].Type, cells[2][2].Type, cells[2][3].Type})
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), cells[2][1].Interface())
+	assert.Equal(t, []interface{}{"hello", true, "#DIV/0!"}, []interface{}{cells[3][0].Interface(), cells[3][1].Interface(), cells[3][2].Interface()})
+	assert.Equal(t, CellTypeError, cells[3][2].Type)
+
+	layout, err := wb.layout("data")
+	assert.NoError(t, err)
+	assert.Equal(t, []cellRange{{startCol: 1, startRow: 3, endCol: 2, endRow: 4}}, layout.merged)
+	assert.Equal(t, map[int]bool{4: true}, layout.hiddenRows)
+	assert.Equal(t, map[int]bool{3: true, 4: true}, layout.hiddenCols)
+}
+
+func TestExcelDateLayout(t *testing.T) {
+	assert.Equal(t, "2006-01-02", excelDateLayout(14, ""))
+	assert.Equal(t, "15:04:05", excelDateLayout(20, ""))
+	assert.Equal(t, "2006-01-02 15:04:05", excelDateLayout(22, ""))
+	assert.Equal(t, "", excelDateLayout(2, ""))
+	assert.Equal(t, "2006-01-02", excelDateLayout(170, `yyyy"年"m"月"d"日"`))
+	assert.Equal(t, "2006-01-02 15:04:05", excelDateLayout(170, "yyyy-mm-dd hh:mm"))
+	assert.Equal(t, "15:04:05", excelDateLayout(170, "[h]:mm:ss"))
+	assert.Equal(t, "", excelDateLayout(170, `[Red]#,##0.00"days"`))
+	assert.Equal(t, "", excelDateLayout(170, "General"))
+}
+
+func TestParseXLSSSTCorrupted(t *testing.T) {
+	// 字符串数量超过剩余的数据时，不能按照数量预先分配内存
+	var sst bytes.Buffer
+	for _, d := range []interface{}{uint32(1), uint32(0xFFFFFFFF), uint16(2), byte(0), []byte("ab")} {
+		_ = binary.Write(&sst, binary.LittleEndian, d)
+	}
+
+	_, err := parseXLSSST([][]byte{sst.Bytes()})
+	assert.True(t, err != nil)
+
+	strs, err := parseXLSSST([][]byte{sst.Bytes()[:8], sst.Bytes()[8:]})
+	assert.True(t, err != nil)
+	assert.Equal(t, 0, len(strs))
+
+	// 扩展数据的长度超过剩余的数据
+	var str bytes.Buffer
+	for _, d := range []interface{}{uint16(2), byte(0x0C), uint16(0xFFFF), uint32(0xFFFFFFFF), []byte("ab")} {
+		_ = binary.Write(&str, binary.LittleEndian, d)
+	}
+
+	_, err = newXLSDataReader(str.Bytes()).string()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = newXLSDataReader([]byte{0x01}).bytes(1 << 40)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	val, err := newXLSDataReader([]byte{0x02, 0x00, 0x00, 'a', 'b'}).string()
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", val)
+}
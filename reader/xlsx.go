@@ -0,0 +1,367 @@
+package reader
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// zipEntryReader is a file in zip, the zip file is closed with it
+type zipEntryReader struct {
+	io.ReadCloser
+	zr *zip.ReadCloser
+}
+
+func (r *zipEntryReader) Close() error {
+	_ = r.ReadCloser.Close()
+	return r.zr.Close()
+}
+
+// OpenXLSXSheet open the worksheet part of the sheet in xlsx file, so that the xml content can be read in streaming mode
+func OpenXLSXSheet(filePath string, sheet string) (io.ReadCloser, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := xlsxSheetPart(&zr.Reader, sheet)
+	if err != nil {
+		_ = zr.Close()
+		return nil, err
+	}
+
+	r, err := openZipFile(&zr.Reader, name)
+	if err != nil {
+		_ = zr.Close()
+		return nil, err
+	}
+
+	return &zipEntryReader{ReadCloser: r, zr: zr}, nil
+}
+
+// xlsxSheetPart return the path of the worksheet part in xlsx file
+func xlsxSheetPart(zr *zip.Reader, sheet string) (string, error) {
+	type relationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Type   string `xml:"Type,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+
+	resolve := func(base string, target string) string {
+		if strings.HasPrefix(target, "/") {
+			return strings.TrimPrefix(target, "/")
+		}
+
+		return path.Join(path.Dir(base), target)
+	}
+
+	workbookPath := "xl/workbook.xml"
+	var rootRels relationships
+	if err := readZipXML(zr, "_rels/.rels", &rootRels); err == nil {
+		for _, rel := range rootRels.Relationships {
+			if strings.HasSuffix(rel.Type, "/officeDocument") {
+				workbookPath = resolve("", rel.Target)
+				break
+			}
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readZipXML(zr, workbookPath, &workbook); err != nil {
+		return "", err
+	}
+
+	var rels relationships
+	if err := readZipXML(zr, path.Join(path.Dir(workbookPath), "_rels", path.Base(workbookPath)+".rels"), &rels); err != nil {
+		return "", err
+	}
+
+	for _, s := range workbook.Sheets {
+		if s.Name != sheet {
+			continue
+		}
+
+		for _, rel := range rels.Relationships {
+			if rel.ID == s.ID {
+				return resolve(workbookPath, rel.Target), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("sheet %s not found", sheet)
+}
+
+// openZipFile open the file in zip, the name is case-insensitive
+func openZipFile(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if strings.EqualFold(f.Name, name) {
+			return f.Open()
+		}
+	}
+
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+// readZipXML decode the xml file in zip into v
+func readZipXML(zr *zip.Reader, name string, v interface{}) error {
+	r, err := openZipFile(zr, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("read %s failed: %w", name, err)
+	}
+
+	return nil
+}
+
+// xlsxCellInfo is the type, the style and the formula of a cell in sheetData of xlsx file
+type xlsxCellInfo struct {
+	typ     string
+	style   int
+	formula string
+}
+
+// xlsxCellInfoRow is a row in sheetData of xlsx file, the cells are indexed by column number (start from 1)
+type xlsxCellInfoRow struct {
+	num   int
+	cells map[int]xlsxCellInfo
+}
+
+// xlsxSharedFormula is the master cell of a shared formula
+type xlsxSharedFormula struct {
+	col     int
+	row     int
+	formula string
+}
+
+// xlsxCellInfoReader read the types, the styles and the formulas of cells in a sheet of xlsx file in one streaming pass,
+// the rows must be read in order. The lookups by cell of excelize scan all rows of the sheet for each cell, which is
+// too slow for large sheets
+type xlsxCellInfoReader struct {
+	file    io.ReadCloser
+	decoder *xml.Decoder
+	// next is the row read but not returned yet
+	next   *xlsxCellInfoRow
+	rowNum int
+	done   bool
+	// shared is the master cells of shared formulas by the shared index
+	shared map[string]xlsxSharedFormula
+}
+
+func newXLSXCellInfoReader(filePath string, sheet string) (*xlsxCellInfoReader, error) {
+	r, err := OpenXLSXSheet(filePath, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxCellInfoReader{
+		file:    r,
+		decoder: xml.NewDecoder(bufio.NewReaderSize(r, 64*1024)),
+		shared:  make(map[string]xlsxSharedFormula),
+	}, nil
+}
+
+// Row return the cells of row rowNum, it returns nil if the row does not exist in sheetData
+func (r *xlsxCellInfoReader) Row(rowNum int) (map[int]xlsxCellInfo, error) {
+	for !r.done && (r.next == nil || r.next.num < rowNum) {
+		row, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+
+		if row == nil {
+			r.done = true
+			break
+		}
+
+		r.next = row
+	}
+
+	if r.next == nil || r.next.num != rowNum {
+		return nil, nil
+	}
+
+	row := r.next
+	r.next = nil
+	return row.cells, nil
+}
+
+// read return the next row in sheetData, it returns nil at the end of sheetData
+func (r *xlsxCellInfoReader) read() (*xlsxCellInfoRow, error) {
+	var row *xlsxCellInfoRow
+	col := 0
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = &xlsxCellInfoRow{num: r.rowNum + 1, cells: make(map[int]xlsxCellInfo)}
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "r" {
+						if num, err := strconv.Atoi(attr.Value); err == nil {
+							row.num = num
+						}
+					}
+				}
+				r.rowNum, col = row.num, 0
+			case "c":
+				if row == nil {
+					continue
+				}
+
+				col++
+				var cell xlsxCellInfo
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "r":
+						if c, _, err := excelize.CellNameToCoordinates(attr.Value); err == nil {
+							col = c
+						}
+					case "s":
+						cell.style, _ = strconv.Atoi(attr.Value)
+					case "t":
+						cell.typ = attr.Value
+					}
+				}
+				row.cells[col] = cell
+			case "f":
+				if row == nil {
+					continue
+				}
+
+				var formula struct {
+					Type    string `xml:"t,attr"`
+					Index   string `xml:"si,attr"`
+					Ref     string `xml:"ref,attr"`
+					Content string `xml:",chardata"`
+				}
+				if err := r.decoder.DecodeElement(&formula, &t); err != nil {
+					return nil, err
+				}
+
+				cell := row.cells[col]
+				cell.formula = formula.Content
+				if formula.Type == "shared" && formula.Index != "" {
+					// 共享公式只有主单元格中保存了公式内容，其它单元格的公式根据与主单元格的相对位置计算
+					if formula.Ref != "" && formula.Content != "" {
+						r.shared[formula.Index] = xlsxSharedFormula{col: col, row: row.num, formula: formula.Content}
+					} else if master, ok := r.shared[formula.Index]; ok {
+						cell.formula = shiftFormula(master.formula, col-master.col, row.num-master.row)
+					}
+				}
+				row.cells[col] = cell
+			case "v", "is":
+				if err := r.decoder.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" && row != nil {
+				return row, nil
+			}
+		}
+	}
+}
+
+func (r *xlsxCellInfoReader) Close() error {
+	return r.file.Close()
+}
+
+// shiftFormula shift the relative cell references such as A1 in formula by dCol columns and dRow rows, the absolute
+// references such as $A$1 and the text in quotes are kept
+func shiftFormula(formula string, dCol, dRow int) string {
+	var sb strings.Builder
+	inQuotes := false
+	for i := 0; i < len(formula); {
+		c := formula[i]
+		if c == '"' {
+			inQuotes = !inQuotes
+		}
+
+		if inQuotes || !(c >= 'A' && c <= 'Z' || c == '$') {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+
+		// 单元格引用为大写字母（可能有 $ 前缀）后跟数字，例如 A1、$A$1，其它如函数名则原样保留
+		end, hasNum := i+1, false
+		for ; end < len(formula); end++ {
+			ch := formula[end]
+			if ch >= '0' && ch <= '9' || ch == '$' {
+				hasNum = true
+			} else if !(ch >= 'A' && ch <= 'Z') || hasNum {
+				break
+			}
+		}
+
+		if hasNum {
+			sb.WriteString(shiftCellRef(formula[i:end], dCol, dRow))
+		} else {
+			sb.WriteString(formula[i:end])
+		}
+
+		i = end
+	}
+
+	return sb.String()
+}
+
+// shiftCellRef shift the cell reference by dCol columns and dRow rows, the column or row with $ prefix is kept
+func shiftCellRef(ref string, dCol, dRow int) string {
+	col, row, err := excelize.CellNameToCoordinates(ref)
+	if err != nil {
+		return ref
+	}
+
+	absCol, absRow := strings.HasPrefix(ref, "$"), strings.LastIndex(ref, "$") > 0
+	if !absCol {
+		col += dCol
+	}
+
+	if !absRow {
+		row += dRow
+	}
+
+	name, err := excelize.ColumnNumberToName(col)
+	if err != nil || row < 1 {
+		return ref
+	}
+
+	if absCol {
+		name = "$" + name
+	}
+
+	if absRow {
+		name += "$"
+	}
+
+	return name + strconv.Itoa(row)
+}
@@ -1,15 +1,29 @@
 package render
 
 import (
+	"bufio"
 	"encoding/csv"
 	"io"
+	"strings"
 
 	"github.com/mylxsw/go-utils/array"
 	"github.com/mylxsw/heimdall/extracter"
 )
 
-func streamRenderCSV(output io.Writer, stream <-chan map[string]interface{}, noHeader bool, cols []extracter.Column) (int, error) {
-	return renderCSV(output, noHeader, cols, func(cb func(item map[string]interface{}) error) error {
+// CSVOptions is the options for rendering csv, the zero value means comma separated fields with utf-8 BOM
+type CSVOptions struct {
+	// Comma is the field delimiter
+	Comma rune
+	// QuoteAll quote all fields, otherwise only the fields containing special characters are quoted
+	QuoteAll bool
+	// UseCRLF use \r\n as the line terminator
+	UseCRLF bool
+	// NoBOM do not write utf-8 BOM at the beginning
+	NoBOM bool
+}
+
+func streamRenderCSV(output io.Writer, stream <-chan map[string]interface{}, noHeader bool, cols []extracter.Column, opt CSVOptions) (int, error) {
+	return renderCSV(output, noHeader, cols, opt, func(cb func(item map[string]interface{}) error) error {
 		for item := range stream {
 			if err := cb(item); err != nil {
 				return err
@@ -19,8 +33,8 @@ func streamRenderCSV(output io.Writer, stream <-chan map[string]interface{}, noH
 	})
 }
 
-func renderCSVAll(output io.Writer, kvs []map[string]interface{}, noHeader bool, cols []extracter.Column) (int, error) {
-	return renderCSV(output, noHeader, cols, func(cb func(item map[string]interface{}) error) error {
+func renderCSVAll(output io.Writer, kvs []map[string]interface{}, noHeader bool, cols []extracter.Column, opt CSVOptions) (int, error) {
+	return renderCSV(output, noHeader, cols, opt, func(cb func(item map[string]interface{}) error) error {
 		for _, item := range kvs {
 			if err := cb(item); err != nil {
 				return err
@@ -31,14 +45,16 @@ func renderCSVAll(output io.Writer, kvs []map[string]interface{}, noHeader bool,
 	})
 }
 
-func renderCSV(output io.Writer, noHeader bool, cols []extracter.Column, cb func(cb func(item map[string]interface{}) error) error) (int, error) {
+func renderCSV(output io.Writer, noHeader bool, cols []extracter.Column, opt CSVOptions, cb func(cb func(item map[string]interface{}) error) error) (int, error) {
 	var total int
-	// Write BOM header for UTF-8
-	if _, err := output.Write([]byte("\xEF\xBB\xBF")); err != nil {
-		return 0, err
+	if !opt.NoBOM {
+		// Write BOM header for UTF-8
+		if _, err := output.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return 0, err
+		}
 	}
 
-	csvWriter := csv.NewWriter(output)
+	csvWriter := NewCSVWriter(output, opt)
 	defer csvWriter.Flush()
 
 	if !noHeader {
@@ -66,3 +82,61 @@ func renderCSV(output io.Writer, noHeader bool, cols []extracter.Column, cb func
 
 	return total, nil
 }
+
+// CSVWriter is the writer of csv rows, encoding/csv is used unless all fields need to be quoted
+type CSVWriter interface {
+	Write(record []string) error
+	Flush()
+}
+
+// NewCSVWriter create a csv writer with options, the utf-8 BOM is not written by it
+func NewCSVWriter(output io.Writer, opt CSVOptions) CSVWriter {
+	if opt.Comma == 0 {
+		opt.Comma = ','
+	}
+
+	if opt.QuoteAll {
+		return &quoteAllCSVWriter{w: bufio.NewWriter(output), opt: opt}
+	}
+
+	w := csv.NewWriter(output)
+	w.Comma = opt.Comma
+	w.UseCRLF = opt.UseCRLF
+
+	return w
+}
+
+// quoteAllCSVWriter write csv rows with all fields quoted
+type quoteAllCSVWriter struct {
+	w   *bufio.Writer
+	opt CSVOptions
+}
+
+func (qw *quoteAllCSVWriter) Write(record []string) error {
+	for i, field := range record {
+		if i > 0 {
+			if _, err := qw.w.WriteRune(qw.opt.Comma); err != nil {
+				return err
+			}
+		}
+
+		if qw.opt.UseCRLF {
+			field = strings.ReplaceAll(strings.ReplaceAll(field, "\r\n", "\n"), "\n", "\r\n")
+		}
+
+		if _, err := qw.w.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`); err != nil {
+			return err
+		}
+	}
+
+	if qw.opt.UseCRLF {
+		_, err := qw.w.WriteString("\r\n")
+		return err
+	}
+
+	return qw.w.WriteByte('\n')
+}
+
+func (qw *quoteAllCSVWriter) Flush() {
+	_ = qw.w.Flush()
+}
@@ -22,7 +22,7 @@ type Writer interface {
 	Close() error
 }
 
-func StreamingRender(output io.Writer, format string, noHeader bool, cols []extracter.Column, stream <-chan map[string]interface{}, targetTableForSQLFormat string) (int, error) {
+func StreamingRender(output io.Writer, format string, noHeader bool, cols []extracter.Column, stream <-chan map[string]interface{}, targetTableForSQLFormat string, csvOpt CSVOptions) (int, error) {
 	switch format {
 	case "xlsx":
 		return streamRenderXlsx(output, noHeader, cols, stream)
@@ -38,7 +38,7 @@ func StreamingRender(output io.Writer, format string, noHeader bool, cols []extr
 			}
 		}
 	case "csv":
-		return streamRenderCSV(output, stream, noHeader, cols)
+		return streamRenderCSV(output, stream, noHeader, cols, csvOpt)
 	case "sql":
 		var total int
 		for item := range stream {
@@ -103,7 +103,7 @@ func resolveValue(col extracter.Column, value interface{}) string {
 	return fmt.Sprintf("%v", value)
 }
 
-func Render(format string, noHeader bool, cols []extracter.Column, kvs []map[string]interface{}, sqlStr string, targetTableForSQLFormat string) (*bytes.Buffer, error) {
+func Render(format string, noHeader bool, cols []extracter.Column, kvs []map[string]interface{}, sqlStr string, targetTableForSQLFormat string, csvOpt CSVOptions) (*bytes.Buffer, error) {
 	writer := bytes.NewBuffer(nil)
 
 	switch format {
@@ -123,7 +123,7 @@ func Render(format string, noHeader bool, cols []extracter.Column, kvs []map[str
 	case "markdown":
 		return writer, Markdown(writer, noHeader, cols, kvs)
 	case "csv":
-		_, err := renderCSVAll(writer, kvs, noHeader, cols)
+		_, err := renderCSVAll(writer, kvs, noHeader, cols, csvOpt)
 		return writer, err
 	case "html":
 		return writer, HTML(writer, noHeader, cols, kvs)
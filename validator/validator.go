@@ -0,0 +1,342 @@
+package validator
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/mylxsw/go-utils/ternary"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity of violations
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rules is the declarative validation rules for input files
+//
+//	columns:
+//	  - column: ID
+//	    required: true
+//	    unique: true
+//	  - column: 手机号
+//	    regex: '^1\d{10}$'
+//	  - column: 状态
+//	    enum: [active, disabled]
+//	  - column: 年龄
+//	    min: 0
+//	    max: 150
+//	    severity: warning
+//	  - column: 生日
+//	    date_format: YYYY-MM-DD
+//	checks:
+//	  - name: birthday_not_in_future
+//	    expr: '生日 == "" || date(生日) <= now()'
+//	    message: birthday can not be in the future
+type Rules struct {
+	Columns []ColumnRule `yaml:"columns"`
+	Checks  []CheckRule  `yaml:"checks"`
+}
+
+// ColumnRule is the constraints for a column, empty values are only checked by required
+type ColumnRule struct {
+	Column     string   `yaml:"column"`
+	Required   bool     `yaml:"required"`
+	Regex      string   `yaml:"regex"`
+	Enum       []string `yaml:"enum"`
+	Min        *float64 `yaml:"min"`
+	Max        *float64 `yaml:"max"`
+	DateFormat string   `yaml:"date_format"`
+	Unique     bool     `yaml:"unique"`
+	Severity   string   `yaml:"severity"`
+	Message    string   `yaml:"message"`
+}
+
+// CheckRule is a cross-column expression, which should be evaluated as true for valid rows,
+// columns are accessed by their names, or by row["column name"] for names that are not valid identifiers
+type CheckRule struct {
+	Name     string `yaml:"name"`
+	Expr     string `yaml:"expr"`
+	Severity string `yaml:"severity"`
+	Message  string `yaml:"message"`
+}
+
+// Violation is a rule violation found in input file
+type Violation struct {
+	File     string
+	Line     string
+	Column   string
+	Value    string
+	Rule     string
+	Severity string
+	Message  string
+}
+
+type columnValidator struct {
+	rule       ColumnRule
+	regex      *regexp.Regexp
+	enum       map[string]bool
+	dateLayout string
+}
+
+type checkValidator struct {
+	rule    CheckRule
+	program *vm.Program
+}
+
+// Validator validate the rows of input files
+type Validator struct {
+	columns []columnValidator
+	checks  []checkValidator
+
+	// 当前文件的表头以及唯一性校验状态，每个文件单独校验
+	headers     []string
+	indexes     map[string]int
+	uniqueSeens map[string]map[string]string
+}
+
+// Load load rules from yaml file
+func Load(rulesFile string) (*Validator, error) {
+	data, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("read rules file failed: %w", err)
+	}
+
+	var rules Rules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse rules file %s failed: %w", rulesFile, err)
+	}
+
+	return New(rules)
+}
+
+// New create a Validator from rules
+func New(rules Rules) (*Validator, error) {
+	v := &Validator{}
+	for _, rule := range rules.Columns {
+		if rule.Column == "" {
+			return nil, fmt.Errorf("column is required for column rules")
+		}
+
+		if err := checkSeverity(rule.Severity); err != nil {
+			return nil, fmt.Errorf("invalid rule for column %s: %w", rule.Column, err)
+		}
+
+		cv := columnValidator{rule: rule}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex for column %s: %w", rule.Column, err)
+			}
+
+			cv.regex = re
+		}
+
+		if len(rule.Enum) > 0 {
+			cv.enum = make(map[string]bool)
+			for _, e := range rule.Enum {
+				cv.enum[e] = true
+			}
+		}
+
+		if rule.DateFormat != "" {
+			cv.dateLayout = toGoLayout(rule.DateFormat)
+		}
+
+		v.columns = append(v.columns, cv)
+	}
+
+	for i, rule := range rules.Checks {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("check_%d", i)
+		}
+
+		if err := checkSeverity(rule.Severity); err != nil {
+			return nil, fmt.Errorf("invalid check %s: %w", rule.Name, err)
+		}
+
+		program, err := expr.Compile(rule.Expr, expr.AllowUndefinedVariables(), expr.AsBool())
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression for check %s: %w", rule.Name, err)
+		}
+
+		v.checks = append(v.checks, checkValidator{rule: rule, program: program})
+	}
+
+	return v, nil
+}
+
+func checkSeverity(severity string) error {
+	switch severity {
+	case "", SeverityError, SeverityWarning:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported severity %s, should be error or warning", severity)
+}
+
+// toGoLayout convert date format such as YYYY-MM-DD HH:mm:ss to go layout,
+// the go layout such as 2006-01-02 is also supported
+func toGoLayout(format string) string {
+	return strings.NewReplacer(
+		"YYYY", "2006",
+		"MM", "01",
+		"DD", "02",
+		"HH", "15",
+		"mm", "04",
+		"ss", "05",
+	).Replace(format)
+}
+
+// Header start validating a new file, it returns violations for missing required columns
+func (v *Validator) Header(file string, headers []string) []Violation {
+	v.headers = headers
+	v.indexes = make(map[string]int)
+	v.uniqueSeens = make(map[string]map[string]string)
+	for i, h := range headers {
+		if _, ok := v.indexes[h]; !ok {
+			v.indexes[h] = i
+		}
+	}
+
+	violations := make([]Violation, 0)
+	for _, cv := range v.columns {
+		if _, ok := v.indexes[cv.rule.Column]; !ok && cv.rule.Required {
+			violations = append(violations, cv.violation(file, "header", "", "required", "required column is missing"))
+		}
+	}
+
+	return violations
+}
+
+// Row validate a data row
+func (v *Validator) Row(file string, line string, data []string) []Violation {
+	violations := make([]Violation, 0)
+	for _, cv := range v.columns {
+		index, ok := v.indexes[cv.rule.Column]
+		if !ok {
+			continue
+		}
+
+		var value string
+		if index < len(data) {
+			value = strings.TrimSpace(data[index])
+		}
+
+		if value == "" {
+			if cv.rule.Required {
+				violations = append(violations, cv.violation(file, line, value, "required", "value is required"))
+			}
+
+			continue
+		}
+
+		if cv.regex != nil && !cv.regex.MatchString(value) {
+			violations = append(violations, cv.violation(file, line, value, "regex", fmt.Sprintf("value does not match %s", cv.rule.Regex)))
+		}
+
+		if cv.enum != nil && !cv.enum[value] {
+			violations = append(violations, cv.violation(file, line, value, "enum", fmt.Sprintf("value should be one of %s", strings.Join(cv.rule.Enum, ", "))))
+		}
+
+		if cv.rule.Min != nil || cv.rule.Max != nil {
+			num, err := strconv.ParseFloat(value, 64)
+			switch {
+			case err != nil:
+				violations = append(violations, cv.violation(file, line, value, "range", "value is not a number"))
+			case cv.rule.Min != nil && num < *cv.rule.Min:
+				violations = append(violations, cv.violation(file, line, value, "range", fmt.Sprintf("value is less than %v", *cv.rule.Min)))
+			case cv.rule.Max != nil && num > *cv.rule.Max:
+				violations = append(violations, cv.violation(file, line, value, "range", fmt.Sprintf("value is greater than %v", *cv.rule.Max)))
+			}
+		}
+
+		if cv.dateLayout != "" {
+			if _, err := time.Parse(cv.dateLayout, value); err != nil {
+				violations = append(violations, cv.violation(file, line, value, "date_format", fmt.Sprintf("value is not a date in format %s", cv.rule.DateFormat)))
+			}
+		}
+
+		if cv.rule.Unique {
+			seens, ok := v.uniqueSeens[cv.rule.Column]
+			if !ok {
+				seens = make(map[string]string)
+				v.uniqueSeens[cv.rule.Column] = seens
+			}
+
+			if first, ok := seens[value]; ok {
+				violations = append(violations, cv.violation(file, line, value, "unique", fmt.Sprintf("value is duplicated with line %s", first)))
+			} else {
+				seens[value] = line
+			}
+		}
+	}
+
+	if len(v.checks) > 0 {
+		env := make(map[string]interface{}, len(v.headers)+1)
+		row := make(map[string]interface{}, len(v.headers))
+		for i, h := range v.headers {
+			if _, ok := row[h]; ok {
+				continue
+			}
+
+			var value string
+			if i < len(data) {
+				value = strings.TrimSpace(data[i])
+			}
+
+			row[h] = value
+			env[h] = value
+		}
+		env["row"] = row
+
+		for _, cv := range v.checks {
+			violations = append(violations, cv.check(file, line, env)...)
+		}
+	}
+
+	return violations
+}
+
+func (cv columnValidator) violation(file, line, value, rule, message string) Violation {
+	return Violation{
+		File:     file,
+		Line:     line,
+		Column:   cv.rule.Column,
+		Value:    value,
+		Rule:     rule,
+		Severity: ternary.If(cv.rule.Severity == "", SeverityError, cv.rule.Severity),
+		Message:  ternary.If(cv.rule.Message == "", message, cv.rule.Message),
+	}
+}
+
+func (cv checkValidator) check(file, line string, env map[string]interface{}) []Violation {
+	violation := Violation{
+		File:     file,
+		Line:     line,
+		Rule:     cv.rule.Name,
+		Value:    cv.rule.Expr,
+		Severity: ternary.If(cv.rule.Severity == "", SeverityError, cv.rule.Severity),
+		Message:  ternary.If(cv.rule.Message == "", "expression is not satisfied", cv.rule.Message),
+	}
+
+	res, err := expr.Run(cv.program, env)
+	if err != nil {
+		// expr 的错误信息中包含多行的表达式位置提示，报告中只保留第一行
+		violation.Message = fmt.Sprintf("evaluate expression failed: %s", strings.SplitN(err.Error(), "\n", 2)[0])
+		return []Violation{violation}
+	}
+
+	if ok, _ := res.(bool); !ok {
+		return []Violation{violation}
+	}
+
+	return nil
+}
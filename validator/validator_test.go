@@ -0,0 +1,51 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/mylxsw/go-utils/assert"
+)
+
+func TestValidator(t *testing.T) {
+	min, max := 0.0, 150.0
+	v, err := New(Rules{
+		Columns: []ColumnRule{
+			{Column: "id", Required: true, Unique: true},
+			{Column: "phone", Regex: `^1\d{10}$`},
+			{Column: "status", Enum: []string{"active", "disabled"}},
+			{Column: "age", Min: &min, Max: &max, Severity: SeverityWarning},
+			{Column: "birthday", DateFormat: "YYYY-MM-DD"},
+			{Column: "email", Required: true},
+		},
+		Checks: []CheckRule{
+			{Name: "age_match_status", Expr: `status != "disabled" || float(age) > 18`},
+		},
+	})
+	assert.NoError(t, err)
+
+	violations := v.Header("data.csv", []string{"id", "phone", "status", "age", "birthday"})
+	assert.Equal(t, 1, len(violations))
+	assert.Equal(t, "email", violations[0].Column)
+
+	assert.Equal(t, 0, len(v.Row("data.csv", "2", []string{"1", "13800000000", "active", "20", "2000-01-02"})))
+
+	violations = v.Row("data.csv", "3", []string{"1", "123", "unknown", "200", "2000/01/02"})
+	assert.Equal(t, []string{"unique", "regex", "enum", "range", "date_format"}, rulesOf(violations))
+	assert.Equal(t, SeverityWarning, violations[3].Severity)
+
+	violations = v.Row("data.csv", "4", []string{"", "", "disabled", "10"})
+	assert.Equal(t, []string{"required", "age_match_status"}, rulesOf(violations))
+
+	// 不同文件之间不检查唯一性
+	v.Header("data2.csv", []string{"id"})
+	assert.Equal(t, 0, len(v.Row("data2.csv", "2", []string{"1"})))
+}
+
+func rulesOf(violations []Violation) []string {
+	rules := make([]string, 0)
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+
+	return rules
+}